@@ -0,0 +1,117 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main is a sidecar serving the APIKeyProvider service over a Unix
+// domain socket, e.g.
+//
+//	go run ./cmd/apikey-provider -endpoint /csi/provider.sock -source file -config /etc/storage_ibmc/slclient.toml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/IBM/ibm-csi-common/pkg/apikeyprovider"
+	"github.com/IBM/ibm-csi-common/pkg/ibmcloudprovider"
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// options are the command line flags
+type options struct {
+	endpoint        string
+	source          string
+	configPath      string
+	secretNamespace string
+	secretName      string
+	secretKey       string
+}
+
+// parseOptions parses args, errors are printed to stderr
+func parseOptions(args []string, stderr io.Writer) (*options, error) {
+	o := &options{}
+	flags := flag.NewFlagSet("apikey-provider", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&o.endpoint, "endpoint", "/csi/provider.sock", "Path of the Unix domain socket to serve on")
+	flags.StringVar(&o.source, "source", "file", "Where the API keys are read from: file, secret or env")
+	flags.StringVar(&o.configPath, "config", "/etc/storage_ibmc/"+utils.ConfigFileName, "Path of the configuration for the file source")
+	flags.StringVar(&o.secretNamespace, "secret-namespace", "kube-system", "Namespace of the secret for the secret source")
+	flags.StringVar(&o.secretName, "secret-name", "storage-secret-store", "Name of the secret for the secret source")
+	flags.StringVar(&o.secretKey, "secret-key", utils.ConfigFileName, "Key of the configuration in the secret for the secret source")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// keySource returns the source selected by o, newClient is only called for
+// the secret source
+func (o *options) keySource(logger *zap.Logger, newClient func() (kubernetes.Interface, error)) (apikeyprovider.KeySource, error) {
+	switch o.source {
+	case "file":
+		return apikeyprovider.NewConfigKeySource(logger, ibmcloudprovider.NewFileConfigSource(o.configPath)), nil
+	case "secret":
+		client, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		return apikeyprovider.NewConfigKeySource(logger, ibmcloudprovider.NewSecretConfigSource(client, o.secretNamespace, o.secretName, o.secretKey)), nil
+	case "env":
+		return apikeyprovider.NewEnvKeySource(), nil
+	}
+	return nil, fmt.Errorf("unknown key source %q, expected file, secret or env", o.source)
+}
+
+// inClusterClient returns a client of the cluster the pod runs in
+func inClusterClient() (kubernetes.Interface, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+func main() {
+	// Nothing else runs yet, so the umask can make the socket private from
+	// the start
+	restrictUmask()
+	logger := utils.DefaultLoggerFactory().Logger()
+	o, err := parseOptions(os.Args[1:], os.Stderr)
+	if err != nil {
+		os.Exit(2)
+	}
+	source, err := o.keySource(logger, inClusterClient)
+	if err != nil {
+		logger.Fatal("Failed to create key source", zap.Error(err))
+	}
+	listener, err := apikeyprovider.ListenUnix(o.endpoint)
+	if err != nil {
+		logger.Fatal("Failed to listen", zap.String("endpoint", o.endpoint), zap.Error(err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := apikeyprovider.NewServer(logger, source).Serve(ctx, listener); err != nil {
+		logger.Fatal("Failed to serve API keys", zap.Error(err))
+	}
+}
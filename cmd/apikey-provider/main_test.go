@@ -0,0 +1,70 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main ...
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKeySource(t *testing.T) {
+	fakeClient := func() (kubernetes.Interface, error) { return fake.NewSimpleClientset(), nil }
+	failingClient := func() (kubernetes.Interface, error) { return nil, errors.New("not in cluster") }
+
+	testCases := []struct {
+		testCaseName string
+		args         []string
+		newClient    func() (kubernetes.Interface, error)
+		expectedName string
+		expectedErr  bool
+	}{
+		{testCaseName: "Default file", expectedName: "file:/etc/storage_ibmc/slclient.toml"},
+		{testCaseName: "Secret", args: []string{"-source", "secret", "-secret-namespace", "ibm-system"}, newClient: fakeClient, expectedName: "secret:ibm-system/storage-secret-store/slclient.toml"},
+		{testCaseName: "Secret outside cluster", args: []string{"-source", "secret"}, newClient: failingClient, expectedErr: true},
+		{testCaseName: "Environment", args: []string{"-source", "env"}, expectedName: "env:VPC_API_KEY,CONTAINER_API_KEY"},
+		{testCaseName: "Unknown source", args: []string{"-source", "vault"}, expectedErr: true},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			o, err := parseOptions(testcase.args, &bytes.Buffer{})
+			assert.Nil(t, err)
+			assert.Equal(t, "/csi/provider.sock", o.endpoint)
+			source, err := o.keySource(zap.NewNop(), testcase.newClient)
+			if testcase.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.expectedName, source.Name())
+		})
+	}
+}
+
+func TestParseOptionsUnknownFlag(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	_, err := parseOptions([]string{"-verbose"}, stderr)
+	assert.NotNil(t, err)
+	assert.Contains(t, stderr.String(), "-verbose")
+}
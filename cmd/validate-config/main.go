@@ -0,0 +1,77 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main is a command validating a provider configuration, e.g.
+//
+//	go run ./cmd/validate-config -config test-fixtures/slconfig.toml
+//
+// It prints one line per issue and exits with 1 if there are issues, or with
+// 2 if the configuration cannot be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/IBM/ibm-csi-common/pkg/configvalidation"
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+// run validates the configuration named by args and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := flags.String("config", utils.ConfigFileName, "Path of the configuration to validate, - reads standard input")
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	var data []byte
+	var err error
+	if *path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(*path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", *path, err)
+		return exitError
+	}
+
+	_, issues := configvalidation.ValidateData(zap.NewNop(), string(data))
+	if len(issues) == 0 {
+		fmt.Fprintf(stdout, "%s is valid\n", *path)
+		return exitValid
+	}
+	for _, issue := range issues {
+		fmt.Fprintln(stdout, issue.String())
+	}
+	fmt.Fprintf(stdout, "%s has %d issue(s)\n", *path, len(issues))
+	return exitInvalid
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
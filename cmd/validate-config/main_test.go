@@ -0,0 +1,77 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main ...
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		testCaseName   string
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedOutput []string
+	}{
+		{
+			testCaseName:   "Valid fixture",
+			args:           []string{"-config", filepath.Join("..", "..", "test-fixtures", "slconfig.toml")},
+			expectedCode:   exitValid,
+			expectedOutput: []string{"slconfig.toml is valid"},
+		},
+		{
+			testCaseName: "Invalid standard input",
+			args:         []string{"-config", "-"},
+			stdin:        "[vpc]\n  vpc_enabled = true\n  provider_type = \"g2\"\n  vpc_api_timeout = \"2 minutes\"\n",
+			expectedCode: exitInvalid,
+			expectedOutput: []string{
+				"{Code: InvalidConfigDuration, Field: vpc.vpc_api_timeout,",
+				"{Code: MissingConfigField, Field: vpc.g2_riaas_endpoint_url,",
+				"- has 4 issue(s)",
+			},
+		},
+		{
+			testCaseName:   "Missing file",
+			args:           []string{"-config", filepath.Join(t.TempDir(), "slclient.toml")},
+			expectedCode:   exitError,
+			expectedOutput: []string{"Failed to read"},
+		},
+		{
+			testCaseName: "Unknown flag",
+			args:         []string{"-verbose"},
+			expectedCode: exitError,
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(testcase.args, strings.NewReader(testcase.stdin), &stdout, &stderr)
+			assert.Equal(t, testcase.expectedCode, code, stdout.String()+stderr.String())
+			for _, expected := range testcase.expectedOutput {
+				assert.Contains(t, stdout.String()+stderr.String(), expected)
+			}
+		})
+	}
+}
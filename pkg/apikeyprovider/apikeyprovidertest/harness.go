@@ -0,0 +1,115 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package apikeyprovidertest runs an apikeyprovider.Server in process, for
+// the tests of the drivers and of the provider itself
+package apikeyprovidertest
+
+import (
+	"net"
+	"sync"
+
+	"github.com/IBM/ibm-csi-common/pkg/apikeyprovider"
+	grpcClient "github.com/IBM/ibm-csi-common/pkg/utils/grpc-client"
+	"github.com/IBM/ibm-csi-common/provider"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// harnessBufferSize is the buffer of the in-memory connections of a Harness
+const harnessBufferSize = 1024 * 1024
+
+// Harness runs a Server in process over an in-memory listener, so that tests
+// fetch keys through gRPC like the drivers do, without sidecar or socket.
+// It implements grpcClient.ClientConn, the target passed to Connect is
+// ignored.
+type Harness struct {
+	// Server is the server behind the harness
+	Server *apikeyprovider.Server
+
+	grpcServer *grpc.Server
+	listener   *bufconn.Listener
+	done       chan error
+
+	mux   sync.Mutex
+	conns []*grpc.ClientConn
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+var _ grpcClient.ClientConn = &Harness{}
+
+// NewHarness starts a server of the keys of source, call Close to stop it
+func NewHarness(logger *zap.Logger, source apikeyprovider.KeySource) *Harness {
+	h := &Harness{
+		Server:   apikeyprovider.NewServer(logger, source),
+		listener: bufconn.Listen(harnessBufferSize),
+		done:     make(chan error, 1),
+	}
+	h.grpcServer = h.Server.NewGRPCServer()
+	go func() {
+		h.done <- h.grpcServer.Serve(h.listener)
+	}()
+	return h
+}
+
+// Connect returns a new connection to the server. The harness closes it on
+// Close.
+func (h *Harness) Connect(target string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return h.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, opts...)
+	conn, err := grpc.NewClient("passthrough:///apikeyprovider", opts...)
+	if err != nil {
+		return nil, err
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.conns = append(h.conns, conn)
+	return conn, nil
+}
+
+// Client returns a client of the server
+func (h *Harness) Client() (provider.APIKeyProviderClient, error) {
+	conn, err := h.Connect("")
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewAPIKeyProviderClient(conn), nil
+}
+
+// Close closes the connections and stops the server
+func (h *Harness) Close() error {
+	h.closeOnce.Do(func() {
+		h.mux.Lock()
+		conns := h.conns
+		h.conns = nil
+		h.mux.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		h.grpcServer.Stop()
+		h.closeErr = <-h.done
+	})
+	return h.closeErr
+}
@@ -0,0 +1,45 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package apikeyprovidertest ...
+package apikeyprovidertest
+
+import (
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/apikeyprovider"
+	grpcClient "github.com/IBM/ibm-csi-common/pkg/utils/grpc-client"
+	"github.com/IBM/ibm-csi-common/provider"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+func TestHarnessGrpcSession(t *testing.T) {
+	h := NewHarness(zap.NewNop(), apikeyprovider.StaticKeySource{VPCAPIKey: "vpc-key"})
+
+	// Drivers dial through the grpc-client package, the harness stands in for
+	// the connection to the sidecar socket
+	factory := &grpcClient.ConnObjFactory{}
+	conn, err := factory.NewGrpcSession().GrpcDial(h, "unix:///csi/provider.sock")
+	assert.Nil(t, err)
+	apiKey, err := provider.NewAPIKeyProviderClient(conn).GetVPCAPIKey(context.Background(), &provider.Provider{})
+	assert.Nil(t, err)
+	assert.Equal(t, "vpc-key", apiKey.GetApikey())
+
+	assert.Nil(t, h.Close())
+	assert.Nil(t, h.Close())
+}
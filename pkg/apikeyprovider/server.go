@@ -0,0 +1,248 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package apikeyprovider is a reference implementation of the APIKeyProvider
+// gRPC service of package provider. It serves the API keys of a KeySource,
+// e.g. slclient.toml in a file or Kubernetes secret or environment variables,
+// over a Unix domain socket to the drivers running next to it.
+package apikeyprovider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/IBM/ibm-csi-common/pkg/ibmcloudprovider"
+	"github.com/IBM/ibm-csi-common/pkg/tracing"
+	"github.com/IBM/ibm-csi-common/provider"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// VPCAPIKeyEnvVar is the environment variable of the VPC API key
+	VPCAPIKeyEnvVar = "VPC_API_KEY"
+
+	// ContainerAPIKeyEnvVar is the environment variable of the container
+	// API key
+	ContainerAPIKeyEnvVar = "CONTAINER_API_KEY"
+)
+
+// ErrNoAPIKey is returned by a KeySource which has no key of a kind
+var ErrNoAPIKey = errors.New("no API key")
+
+// Keys are the API keys served by a Server
+type Keys struct {
+	// VPCAPIKey is returned by GetVPCAPIKey
+	VPCAPIKey string
+	// ContainerAPIKey is returned by GetContainerAPIKey
+	ContainerAPIKey string
+}
+
+// KeySource returns the current API keys. It is asked on every call, so that
+// rotated keys are served without restart.
+type KeySource interface {
+	// Name identifies the source in logs, it must not contain secrets
+	Name() string
+	Keys(ctx context.Context) (Keys, error)
+}
+
+// StaticKeySource serves fixed keys, e.g. in tests
+type StaticKeySource Keys
+
+// Name ...
+func (s StaticKeySource) Name() string {
+	return "static"
+}
+
+// Keys ...
+func (s StaticKeySource) Keys(ctx context.Context) (Keys, error) {
+	return Keys(s), nil
+}
+
+// EnvKeySource reads the keys from environment variables
+type EnvKeySource struct {
+	VPCAPIKeyVar       string
+	ContainerAPIKeyVar string
+}
+
+// NewEnvKeySource reads VPCAPIKeyEnvVar and ContainerAPIKeyEnvVar
+func NewEnvKeySource() *EnvKeySource {
+	return &EnvKeySource{VPCAPIKeyVar: VPCAPIKeyEnvVar, ContainerAPIKeyVar: ContainerAPIKeyEnvVar}
+}
+
+// Name ...
+func (s *EnvKeySource) Name() string {
+	return "env:" + s.VPCAPIKeyVar + "," + s.ContainerAPIKeyVar
+}
+
+// Keys ...
+func (s *EnvKeySource) Keys(ctx context.Context) (Keys, error) {
+	return Keys{VPCAPIKey: os.Getenv(s.VPCAPIKeyVar), ContainerAPIKey: os.Getenv(s.ContainerAPIKeyVar)}, nil
+}
+
+// ConfigKeySource reads the keys from a provider configuration, in a file or
+// a Kubernetes secret. The VPC key is g2_api_key, or gc_api_key for the
+// classic generation, the container key is iam_api_key of the bluemix
+// section and defaults to the VPC key.
+type ConfigKeySource struct {
+	logger *zap.Logger
+	source ibmcloudprovider.ConfigSource
+}
+
+// NewConfigKeySource ...
+func NewConfigKeySource(logger *zap.Logger, source ibmcloudprovider.ConfigSource) *ConfigKeySource {
+	return &ConfigKeySource{logger: logger, source: source}
+}
+
+// Name ...
+func (s *ConfigKeySource) Name() string {
+	return s.source.Name()
+}
+
+// Keys ...
+func (s *ConfigKeySource) Keys(ctx context.Context) (Keys, error) {
+	data, err := s.source.Read(ctx)
+	if err != nil {
+		return Keys{}, err
+	}
+	conf, err := config.ParseConfig(s.logger, string(data))
+	if err != nil {
+		return Keys{}, err
+	}
+	keys := Keys{}
+	if conf.VPC != nil {
+		keys.VPCAPIKey = conf.VPC.G2APIKey
+		if keys.VPCAPIKey == "" {
+			keys.VPCAPIKey = conf.VPC.APIKey
+		}
+	}
+	if conf.Bluemix != nil {
+		keys.ContainerAPIKey = conf.Bluemix.IamAPIKey
+	}
+	if keys.ContainerAPIKey == "" {
+		keys.ContainerAPIKey = keys.VPCAPIKey
+	}
+	return keys, nil
+}
+
+// Server implements provider.APIKeyProviderServer with the keys of a
+// KeySource. Keys are never logged.
+type Server struct {
+	provider.UnimplementedAPIKeyProviderServer
+
+	logger *zap.Logger
+	source KeySource
+}
+
+var _ provider.APIKeyProviderServer = &Server{}
+
+// NewServer returns a server of the keys of source
+func NewServer(logger *zap.Logger, source KeySource) *Server {
+	return &Server{logger: logger, source: source}
+}
+
+// GetVPCAPIKey ...
+func (s *Server) GetVPCAPIKey(ctx context.Context, req *provider.Provider) (*provider.APIKey, error) {
+	return s.getAPIKey(ctx, "VPC", func(keys Keys) string { return keys.VPCAPIKey })
+}
+
+// GetContainerAPIKey ...
+func (s *Server) GetContainerAPIKey(ctx context.Context, req *provider.Provider) (*provider.APIKey, error) {
+	return s.getAPIKey(ctx, "container", func(keys Keys) string { return keys.ContainerAPIKey })
+}
+
+// getAPIKey returns the key of kind selected by key. A source which cannot be
+// read is Unavailable, so that clients retry, a missing key is NotFound.
+func (s *Server) getAPIKey(ctx context.Context, kind string, key func(keys Keys) string) (*provider.APIKey, error) {
+	keys, err := s.source.Keys(ctx)
+	if err != nil {
+		s.logger.Error("Failed to read API keys", zap.String("source", s.source.Name()), zap.String("kind", kind), zap.Error(err))
+		return nil, status.Errorf(codes.Unavailable, "failed to read API keys from %s: %v", s.source.Name(), err)
+	}
+	apiKey := key(keys)
+	if apiKey == "" {
+		s.logger.Warn("API key not found", zap.String("source", s.source.Name()), zap.String("kind", kind))
+		return nil, status.Errorf(codes.NotFound, "%v: %s API key in %s", ErrNoAPIKey, kind, s.source.Name())
+	}
+	s.logger.Debug("Serving API key", zap.String("source", s.source.Name()), zap.String("kind", kind))
+	return &provider.APIKey{Apikey: apiKey}, nil
+}
+
+// NewGRPCServer returns a gRPC server serving s, traced like the drivers
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.UnaryInterceptor(tracing.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(tracing.StreamServerInterceptor()),
+	}, opts...)
+	grpcServer := grpc.NewServer(opts...)
+	provider.RegisterAPIKeyProviderServer(grpcServer, s)
+	return grpcServer
+}
+
+// ListenUnix listens on the Unix domain socket path, replacing the socket a
+// previous server left behind. It fails if path is anything but a socket.
+// Only the owner can connect to the socket once ListenUnix returns, callers
+// which cannot allow a window before that set a umask of 0177 at startup.
+func ListenUnix(path string) (net.Listener, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case info.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("%s exists and is not a socket", path)
+	default:
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+		}
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve serves s on listener until ctx is done, then stops gracefully
+func (s *Server) Serve(ctx context.Context, listener net.Listener, opts ...grpc.ServerOption) error {
+	grpcServer := s.NewGRPCServer(opts...)
+	served := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			grpcServer.GracefulStop()
+		case <-served:
+		}
+	}()
+
+	s.logger.Info("Serving API keys", zap.String("source", s.source.Name()), zap.String("address", listener.Addr().String()))
+	err := grpcServer.Serve(listener)
+	close(served)
+	<-stopped
+	return err
+}
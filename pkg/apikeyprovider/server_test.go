@@ -0,0 +1,208 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package apikeyprovider_test ...
+package apikeyprovider_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/apikeyprovider"
+	"github.com/IBM/ibm-csi-common/pkg/apikeyprovider/apikeyprovidertest"
+	"github.com/IBM/ibm-csi-common/pkg/ibmcloudprovider"
+	"github.com/IBM/ibm-csi-common/provider"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testConfig = `[vpc]
+  vpc_enabled = true
+  g2_api_key = "vpc-key"
+[bluemix]
+  iam_api_key = "container-key"
+`
+
+// failingKeySource fails to read its keys
+type failingKeySource struct{}
+
+func (failingKeySource) Name() string { return "failing" }
+
+func (failingKeySource) Keys(ctx context.Context) (apikeyprovider.Keys, error) {
+	return apikeyprovider.Keys{}, errors.New("secret not found")
+}
+
+// fetchKeys returns the keys served by h, or the gRPC codes of the errors
+func fetchKeys(t *testing.T, h *apikeyprovidertest.Harness) (string, string, codes.Code, codes.Code) {
+	client, err := h.Client()
+	assert.Nil(t, err)
+	vpcKey, vpcErr := client.GetVPCAPIKey(context.Background(), &provider.Provider{})
+	containerKey, containerErr := client.GetContainerAPIKey(context.Background(), &provider.Provider{})
+	return vpcKey.GetApikey(), containerKey.GetApikey(), status.Code(vpcErr), status.Code(containerErr)
+}
+
+func TestServerKeySources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfig), 0600))
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage-secret-store", Namespace: "kube-system"},
+		Data:       map[string][]byte{"slclient.toml": []byte("[vpc]\n  g2_api_key = \"secret-vpc-key\"\n")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	t.Setenv(apikeyprovider.VPCAPIKeyEnvVar, "env-vpc-key")
+	t.Setenv(apikeyprovider.ContainerAPIKeyEnvVar, "")
+
+	testCases := []struct {
+		testCaseName          string
+		source                apikeyprovider.KeySource
+		expectedVPCKey        string
+		expectedContainerKey  string
+		expectedVPCCode       codes.Code
+		expectedContainerCode codes.Code
+	}{
+		{
+			testCaseName:         "Static",
+			source:               apikeyprovider.StaticKeySource{VPCAPIKey: "vpc-key", ContainerAPIKey: "container-key"},
+			expectedVPCKey:       "vpc-key",
+			expectedContainerKey: "container-key",
+		},
+		{
+			testCaseName:         "File",
+			source:               apikeyprovider.NewConfigKeySource(zap.NewNop(), ibmcloudprovider.NewFileConfigSource(path)),
+			expectedVPCKey:       "vpc-key",
+			expectedContainerKey: "container-key",
+		},
+		{
+			testCaseName:         "Secret without container key",
+			source:               apikeyprovider.NewConfigKeySource(zap.NewNop(), ibmcloudprovider.NewSecretConfigSource(client, "kube-system", "storage-secret-store", "slclient.toml")),
+			expectedVPCKey:       "secret-vpc-key",
+			expectedContainerKey: "secret-vpc-key",
+		},
+		{
+			testCaseName:          "Environment without container key",
+			source:                apikeyprovider.NewEnvKeySource(),
+			expectedVPCKey:        "env-vpc-key",
+			expectedContainerCode: codes.NotFound,
+		},
+		{
+			testCaseName:          "Missing file",
+			source:                apikeyprovider.NewConfigKeySource(zap.NewNop(), ibmcloudprovider.NewFileConfigSource(path+".missing")),
+			expectedVPCCode:       codes.Unavailable,
+			expectedContainerCode: codes.Unavailable,
+		},
+		{
+			testCaseName:          "Failing source",
+			source:                failingKeySource{},
+			expectedVPCCode:       codes.Unavailable,
+			expectedContainerCode: codes.Unavailable,
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			h := apikeyprovidertest.NewHarness(zap.NewNop(), testcase.source)
+			defer h.Close()
+			vpcKey, containerKey, vpcCode, containerCode := fetchKeys(t, h)
+			assert.Equal(t, testcase.expectedVPCKey, vpcKey)
+			assert.Equal(t, testcase.expectedContainerKey, containerKey)
+			assert.Equal(t, testcase.expectedVPCCode, vpcCode)
+			assert.Equal(t, testcase.expectedContainerCode, containerCode)
+		})
+	}
+}
+
+func TestServerServesRotatedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfig), 0600))
+	h := apikeyprovidertest.NewHarness(zap.NewNop(), apikeyprovider.NewConfigKeySource(zap.NewNop(), ibmcloudprovider.NewFileConfigSource(path)))
+	defer h.Close()
+
+	vpcKey, _, _, _ := fetchKeys(t, h)
+	assert.Equal(t, "vpc-key", vpcKey)
+	assert.Nil(t, os.WriteFile(path, []byte("[vpc]\n  g2_api_key = \"rotated-key\"\n"), 0600))
+	vpcKey, containerKey, _, _ := fetchKeys(t, h)
+	assert.Equal(t, "rotated-key", vpcKey)
+	assert.Equal(t, "rotated-key", containerKey)
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "provider.sock")
+	// A socket left behind by a previous server is replaced
+	stale, err := net.Listen("unix", socket)
+	assert.Nil(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	assert.Nil(t, stale.Close())
+	listener, err := apikeyprovider.ListenUnix(socket)
+	assert.Nil(t, err)
+	info, err := os.Stat(socket)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- apikeyprovider.NewServer(zap.NewNop(), apikeyprovider.StaticKeySource{ContainerAPIKey: "container-key"}).Serve(ctx, listener)
+	}()
+
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	defer conn.Close()
+	apiKey, err := provider.NewAPIKeyProviderClient(conn).GetContainerAPIKey(context.Background(), &provider.Provider{})
+	assert.Nil(t, err)
+	assert.Equal(t, "container-key", apiKey.GetApikey())
+
+	cancel()
+	assert.Nil(t, <-served)
+	_, err = os.Stat(socket)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestListenUnixKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "provider.sock")
+	assert.Nil(t, os.WriteFile(file, []byte("data"), 0600))
+
+	testCases := []struct {
+		testCaseName string
+		path         string
+	}{
+		{testCaseName: "Regular file", path: file},
+		{testCaseName: "Directory", path: dir},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			_, err := apikeyprovider.ListenUnix(testcase.path)
+			assert.NotNil(t, err)
+			_, err = os.Lstat(testcase.path)
+			assert.Nil(t, err)
+		})
+	}
+	data, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+}
@@ -0,0 +1,358 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package configvalidation checks a provider configuration (slclient.toml)
+// before it is used, so that a wrong provider type, a missing endpoint or a
+// bad timeout is reported when the config is loaded rather than deep in a
+// CSI call.
+package configvalidation
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+// Reason codes of the issues, in the style of the messages catalogue
+const (
+	// ConfigParseFailed ...
+	ConfigParseFailed = "ConfigParseFailed"
+
+	// NoProviderEnabled ...
+	NoProviderEnabled = "NoProviderEnabled"
+
+	// MissingConfigField ...
+	MissingConfigField = "MissingConfigField"
+
+	// InvalidConfigURL ...
+	InvalidConfigURL = "InvalidConfigURL"
+
+	// InvalidConfigDuration ...
+	InvalidConfigDuration = "InvalidConfigDuration"
+
+	// InvalidConfigValue ...
+	InvalidConfigValue = "InvalidConfigValue"
+
+	// ConflictingConfigFields ...
+	ConflictingConfigFields = "ConflictingConfigFields"
+)
+
+// issueMessages describe the reason codes. Descriptions and actions take the
+// arguments of the issue by index, the field name is the first one.
+var issueMessages = map[string]messages.Message{
+	ConfigParseFailed: {
+		Code:        ConfigParseFailed,
+		Description: "The configuration could not be parsed: %[2]s",
+		Type:        codes.InvalidArgument,
+		Action:      "Please fix the TOML syntax of slclient.toml",
+	},
+	NoProviderEnabled: {
+		Code:        NoProviderEnabled,
+		Description: "None of the VPC, IKS and Softlayer providers is enabled",
+		Type:        codes.InvalidArgument,
+		Action:      "Please set 'vpc.vpc_enabled', 'IKS.iks_enabled', 'softlayer.softlayer_block_enabled' or 'softlayer.softlayer_file_enabled' to true",
+	},
+	MissingConfigField: {
+		Code:        MissingConfigField,
+		Description: "The field '%[1]s' is required by the %[2]s provider",
+		Type:        codes.InvalidArgument,
+		Action:      "Please set '%[1]s' in slclient.toml",
+	},
+	InvalidConfigURL: {
+		Code:        InvalidConfigURL,
+		Description: "The field '%[1]s' is not a valid https URL: '%[2]s'",
+		Type:        codes.InvalidArgument,
+		Action:      "Please set '%[1]s' to an https URL such as 'https://us-south.iaas.cloud.ibm.com'",
+	},
+	InvalidConfigDuration: {
+		Code:        InvalidConfigDuration,
+		Description: "The field '%[1]s' is not a valid duration: '%[2]s'",
+		Type:        codes.InvalidArgument,
+		Action:      "Please set '%[1]s' to a positive duration such as '120s' or '2m'",
+	},
+	InvalidConfigValue: {
+		Code:        InvalidConfigValue,
+		Description: "The field '%[1]s' has the invalid value '%[2]s'",
+		Type:        codes.InvalidArgument,
+		Action:      "Please set '%[1]s' to %[3]s",
+	},
+	ConflictingConfigFields: {
+		Code:        ConflictingConfigFields,
+		Description: "The fields '%[1]s' and '%[2]s' cannot be used together",
+		Type:        codes.InvalidArgument,
+		Action:      "Please remove '%[1]s' or '%[2]s' from slclient.toml",
+	},
+}
+
+// Issue is a problem of a configuration field
+type Issue struct {
+	// Field is the TOML path of the field, e.g. vpc.g2_api_key
+	Field string
+	messages.Message
+}
+
+// newIssue returns the issue code of field, args fill the description and
+// action after the field name
+func newIssue(code string, field string, args ...interface{}) Issue {
+	msg := issueMessages[code]
+	args = append([]interface{}{field}, args...)
+	msg.Description = format(msg.Description, args)
+	msg.Action = format(msg.Action, args)
+	return Issue{Field: field, Message: msg}
+}
+
+// format fills the indexed verbs of s with args, s may use only some of them
+func format(s string, args []interface{}) string {
+	if !strings.Contains(s, "%[") {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// String ...
+func (i Issue) String() string {
+	return fmt.Sprintf("{Code: %s, Field: %s, Description: %s, Action: %s}", i.Code, i.Field, i.Description, i.Action)
+}
+
+// Issues are the problems of a configuration, sorted by field
+type Issues []Issue
+
+// Error ...
+func (issues Issues) Error() string {
+	lines := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	return strings.Join(lines, "; ")
+}
+
+// Err returns issues as error, or nil if there are none
+func (issues Issues) Err() error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return issues
+}
+
+// Codes returns the reason codes of issues
+func (issues Issues) Codes() []string {
+	codes := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		codes = append(codes, issue.Code)
+	}
+	return codes
+}
+
+// ValidateData parses data like the drivers do, including the environment
+// variable overrides, and validates the result
+func ValidateData(logger *zap.Logger, data string) (*config.Config, Issues) {
+	conf, err := config.ParseConfig(logger, data)
+	if err != nil {
+		return nil, Issues{newIssue(ConfigParseFailed, "", err.Error())}
+	}
+	return conf, Validate(conf)
+}
+
+// Validate checks the required fields of each enabled provider, the format of
+// URLs, durations and enumerations and settings which exclude each other
+func Validate(conf *config.Config) Issues {
+	v := &validator{}
+	vpcEnabled := conf.VPC != nil && conf.VPC.Enabled
+	iksEnabled := conf.IKS != nil && conf.IKS.Enabled
+	softlayerEnabled := conf.Softlayer != nil && (conf.Softlayer.SoftlayerBlockEnabled || conf.Softlayer.SoftlayerFileEnabled)
+	if !vpcEnabled && !iksEnabled && !softlayerEnabled {
+		v.add(newIssue(NoProviderEnabled, ""))
+	}
+	if vpcEnabled {
+		v.validateVPC(conf.VPC)
+	}
+	if iksEnabled {
+		v.validateIKS(conf.IKS)
+	}
+	if softlayerEnabled {
+		v.validateSoftlayer(conf.Softlayer)
+	}
+	if vpcEnabled && iksEnabled && conf.VPC.VPCBlockProviderName != "" && conf.VPC.VPCBlockProviderName == conf.IKS.IKSBlockProviderName {
+		v.add(newIssue(ConflictingConfigFields, "vpc.vpc_block_provider_name", "IKS.iks_block_provider_name"))
+	}
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		return v.issues[i].Field < v.issues[j].Field
+	})
+	return v.issues
+}
+
+// validator collects the issues of a configuration
+type validator struct {
+	issues Issues
+}
+
+func (v *validator) add(issue Issue) {
+	v.issues = append(v.issues, issue)
+}
+
+// required reports field if value is empty
+func (v *validator) required(providerName string, field string, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(newIssue(MissingConfigField, field, providerName))
+		return false
+	}
+	return true
+}
+
+// url reports field if value is set but not an absolute https URL
+func (v *validator) url(field string, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		v.add(newIssue(InvalidConfigURL, field, value))
+	}
+}
+
+// oneOf reports field if value is set but not one of allowed
+func (v *validator) oneOf(field string, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(newIssue(InvalidConfigValue, field, value, "one of '"+strings.Join(allowed, "', '")+"'"))
+}
+
+// nonNegative reports field if value is negative
+func (v *validator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.add(newIssue(InvalidConfigValue, field, fmt.Sprint(value), "zero or a positive number"))
+	}
+}
+
+// duration reports field if value is set but not a positive duration
+func (v *validator) duration(field string, value string) {
+	if value == "" {
+		return
+	}
+	if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+		v.add(newIssue(InvalidConfigDuration, field, value))
+	}
+}
+
+// apiVersion reports field if value is set but not a date like 2020-07-02
+func (v *validator) apiVersion(field string, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		v.add(newIssue(InvalidConfigValue, field, value, "a date such as '2020-07-02'"))
+	}
+}
+
+func (v *validator) validateVPC(vpc *config.VPCProviderConfig) {
+	const providerName = "VPC"
+	// vpc_block_provider_name is optional, the drivers fall back to their
+	// default provider name
+	v.oneOf("vpc.vpc_type_enabled", vpc.VPCTypeEnabled, "g2", "gc")
+
+	// Like the VPC library, the generation of vpc_type_enabled wins over
+	// provider_type
+	generation := vpc.VPCTypeEnabled
+	if v.required(providerName, "vpc.provider_type", vpc.VPCBlockProviderType) {
+		v.oneOf("vpc.provider_type", vpc.VPCBlockProviderType, "g2", "gc")
+		if generation == "" {
+			generation = vpc.VPCBlockProviderType
+		}
+	}
+
+	switch generation {
+	case "g2":
+		v.required(providerName, "vpc.g2_riaas_endpoint_url", vpc.G2EndpointURL)
+		v.required(providerName, "vpc.g2_token_exchange_endpoint_url", vpc.G2TokenExchangeURL)
+		v.credentials(providerName, "vpc.g2_api_key", vpc.G2APIKey, vpc)
+		v.oneOf("vpc.g2_vpc_api_generation", generationValue(vpc.G2VPCAPIGeneration), "1", "2")
+	case "gc":
+		v.required(providerName, "vpc.gc_riaas_endpoint_url", vpc.EndpointURL)
+		v.required(providerName, "vpc.gc_token_exchange_endpoint_url", vpc.TokenExchangeURL)
+		v.credentials(providerName, "vpc.gc_api_key", vpc.APIKey, vpc)
+	}
+
+	v.url("vpc.g2_riaas_endpoint_url", vpc.G2EndpointURL)
+	v.url("vpc.g2_riaas_endpoint_private_url", vpc.G2EndpointPrivateURL)
+	v.url("vpc.g2_token_exchange_endpoint_url", vpc.G2TokenExchangeURL)
+	v.url("vpc.gc_riaas_endpoint_url", vpc.EndpointURL)
+	v.url("vpc.gc_riaas_endpoint_private_url", vpc.PrivateEndpointURL)
+	v.url("vpc.gc_token_exchange_endpoint_url", vpc.TokenExchangeURL)
+	v.url("vpc.iks_token_exchange_endpoint_private_url", vpc.IKSTokenExchangePrivateURL)
+
+	v.apiVersion("vpc.api_version", vpc.APIVersion)
+	v.apiVersion("vpc.g2_api_version", vpc.G2APIVersion)
+	v.oneOf("vpc.vpc_api_generation", generationValue(vpc.VPCAPIGeneration), "1", "2")
+
+	v.duration("vpc.vpc_api_timeout", vpc.VPCTimeout)
+	v.nonNegative("vpc.max_retry_attempt", vpc.MaxRetryAttempt)
+	v.nonNegative("vpc.max_retry_gap", vpc.MaxRetryGap)
+	v.nonNegative("vpc.max_vpc_retry_attempt", vpc.MaxVPCRetryAttempt)
+	v.nonNegative("vpc.min_vpc_retry_gap", vpc.MinVPCRetryGap)
+	v.nonNegative("vpc.min_vpc_retry_gap_attempt", vpc.MinVPCRetryGapAttempt)
+}
+
+// credentials checks that the VPC provider authenticates either with the API
+// key apiKeyField or with an IAM client ID and secret, but not both
+func (v *validator) credentials(providerName string, apiKeyField string, apiKey string, vpc *config.VPCProviderConfig) {
+	hasClient := vpc.IamClientID != "" || vpc.IamClientSecret != ""
+	switch {
+	case apiKey != "" && hasClient:
+		v.add(newIssue(ConflictingConfigFields, apiKeyField, "vpc.iam_client_id"))
+	case hasClient:
+		v.required(providerName, "vpc.iam_client_id", vpc.IamClientID)
+		v.required(providerName, "vpc.iam_client_secret", vpc.IamClientSecret)
+	default:
+		v.required(providerName, apiKeyField, apiKey)
+	}
+}
+
+func (v *validator) validateIKS(iks *config.IKSConfig) {
+	if iks.IKSBlockProviderName == "" && iks.IKSFileProviderName == "" {
+		v.add(newIssue(MissingConfigField, "IKS.iks_block_provider_name", "IKS"))
+	}
+}
+
+// validateSoftlayer checks the format of the Softlayer settings, the classic
+// drivers default the rest
+func (v *validator) validateSoftlayer(softlayer *config.SoftlayerConfig) {
+	v.url("softlayer.softlayer_endpoint_url", softlayer.SoftlayerEndpointURL)
+	v.url("softlayer.softlayer_iam_endpoint_url", softlayer.SoftlayerIMSEndpointURL)
+	v.duration("softlayer.softlayer_api_timeout", softlayer.SoftlayerTimeout)
+	v.duration("softlayer.softlayer_vol_provision_timeout", softlayer.SoftlayerVolProvisionTimeout)
+	v.duration("softlayer.softlayer_api_retry_interval", softlayer.SoftlayerRetryInterval)
+}
+
+// generationValue returns the text of an API generation, empty if unset
+func generationValue(generation int) string {
+	if generation == 0 {
+		return ""
+	}
+	return fmt.Sprint(generation)
+}
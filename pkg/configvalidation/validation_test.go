@@ -0,0 +1,249 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package configvalidation ...
+package configvalidation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+const softlayerConfig = `[server]
+  debug_trace = false
+[softlayer]
+  softlayer_block_enabled = true
+  softlayer_block_provider_name = "SOFTLAYER-BLOCK"
+  softlayer_endpoint_url = "https://api.service.softlayer.com/rest/v3"
+  softlayer_api_timeout = "20s"
+  softlayer_vol_provision_timeout = "30m"
+  softlayer_api_retry_interval = "5s"
+`
+
+const validConfig = `[server]
+  debug_trace = false
+[vpc]
+  vpc_enabled = true
+  vpc_block_provider_name = "vpc"
+  provider_type = "g2"
+  g2_riaas_endpoint_url = "https://us-south.iaas.cloud.ibm.com"
+  g2_riaas_endpoint_private_url = "https://us-south.private.iaas.cloud.ibm.com"
+  g2_token_exchange_endpoint_url = "https://iam.cloud.ibm.com"
+  g2_api_key = "api-key"
+  api_version = "2020-07-02"
+  vpc_api_generation = 2
+  vpc_api_timeout = "120s"
+  max_retry_attempt = 10
+  max_retry_gap = 60
+[IKS]
+  iks_enabled = false
+  iks_block_provider_name = "iks-vpc-classic"
+`
+
+// withValues returns validConfig with the lines of the given keys replaced
+// by value, an empty value removes the line
+func withValues(values map[string]string) string {
+	lines := strings.Split(validConfig, "\n")
+	for i, line := range lines {
+		key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+		if value, ok := values[key]; ok {
+			lines[i] = ""
+			if value != "" {
+				lines[i] = "  " + key + " = " + value
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestValidateFixture(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "test-fixtures", "slconfig.toml"))
+	assert.Nil(t, err)
+	conf, issues := ValidateData(zap.NewNop(), string(data))
+	assert.Empty(t, issues)
+	assert.NotNil(t, conf)
+	assert.Nil(t, issues.Err())
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		testCaseName   string
+		data           string
+		expectedCodes  []string
+		expectedFields []string
+	}{
+		{
+			testCaseName: "Valid",
+			data:         validConfig,
+		},
+		{
+			testCaseName:   "Broken toml",
+			data:           "[vpc\n",
+			expectedCodes:  []string{ConfigParseFailed},
+			expectedFields: []string{""},
+		},
+		{
+			testCaseName:   "No provider enabled",
+			data:           withValues(map[string]string{"vpc_enabled": "false"}),
+			expectedCodes:  []string{NoProviderEnabled},
+			expectedFields: []string{""},
+		},
+		{
+			testCaseName:   "Wrong provider type",
+			data:           withValues(map[string]string{"provider_type": `"g3"`}),
+			expectedCodes:  []string{InvalidConfigValue},
+			expectedFields: []string{"vpc.provider_type"},
+		},
+		{
+			testCaseName:   "Missing endpoint",
+			data:           withValues(map[string]string{"g2_riaas_endpoint_url": ""}),
+			expectedCodes:  []string{MissingConfigField},
+			expectedFields: []string{"vpc.g2_riaas_endpoint_url"},
+		},
+		{
+			testCaseName:   "Missing API key",
+			data:           withValues(map[string]string{"g2_api_key": ""}),
+			expectedCodes:  []string{MissingConfigField},
+			expectedFields: []string{"vpc.g2_api_key"},
+		},
+		{
+			testCaseName:   "Bad timeout",
+			data:           withValues(map[string]string{"vpc_api_timeout": `"120"`}),
+			expectedCodes:  []string{InvalidConfigDuration},
+			expectedFields: []string{"vpc.vpc_api_timeout"},
+		},
+		{
+			testCaseName:   "Negative timeout",
+			data:           withValues(map[string]string{"vpc_api_timeout": `"-1m"`}),
+			expectedCodes:  []string{InvalidConfigDuration},
+			expectedFields: []string{"vpc.vpc_api_timeout"},
+		},
+		{
+			testCaseName:   "Plain http endpoints",
+			data:           withValues(map[string]string{"g2_riaas_endpoint_url": `"http://us-south.iaas.cloud.ibm.com"`, "g2_token_exchange_endpoint_url": `"iam.cloud.ibm.com"`}),
+			expectedCodes:  []string{InvalidConfigURL, InvalidConfigURL},
+			expectedFields: []string{"vpc.g2_riaas_endpoint_url", "vpc.g2_token_exchange_endpoint_url"},
+		},
+		{
+			testCaseName:   "Bad API version and generation",
+			data:           withValues(map[string]string{"api_version": `"v1"`, "vpc_api_generation": "3"}),
+			expectedCodes:  []string{InvalidConfigValue, InvalidConfigValue},
+			expectedFields: []string{"vpc.api_version", "vpc.vpc_api_generation"},
+		},
+		{
+			testCaseName:   "Negative retries",
+			data:           withValues(map[string]string{"max_retry_attempt": "-1"}),
+			expectedCodes:  []string{InvalidConfigValue},
+			expectedFields: []string{"vpc.max_retry_attempt"},
+		},
+		{
+			testCaseName:   "API key and IAM client",
+			data:           strings.Replace(validConfig, `g2_api_key = "api-key"`, `g2_api_key = "api-key"`+"\n  iam_client_id = \"client\"", 1),
+			expectedCodes:  []string{ConflictingConfigFields},
+			expectedFields: []string{"vpc.g2_api_key"},
+		},
+		{
+			testCaseName: "IAM client instead of API key",
+			data:         strings.Replace(validConfig, `g2_api_key = "api-key"`, "iam_client_id = \"client\"\n  iam_client_secret = \"secret\"", 1),
+		},
+		{
+			testCaseName:   "IAM client without secret",
+			data:           strings.Replace(validConfig, `g2_api_key = "api-key"`, `iam_client_id = "client"`, 1),
+			expectedCodes:  []string{MissingConfigField},
+			expectedFields: []string{"vpc.iam_client_secret"},
+		},
+		{
+			testCaseName:   "IKS without provider name",
+			data:           withValues(map[string]string{"iks_enabled": "true", "iks_block_provider_name": ""}),
+			expectedCodes:  []string{MissingConfigField},
+			expectedFields: []string{"IKS.iks_block_provider_name"},
+		},
+		{
+			testCaseName:   "Same provider name for VPC and IKS",
+			data:           withValues(map[string]string{"iks_enabled": "true", "iks_block_provider_name": `"vpc"`}),
+			expectedCodes:  []string{ConflictingConfigFields},
+			expectedFields: []string{"vpc.vpc_block_provider_name"},
+		},
+		{
+			testCaseName: "No VPC provider name",
+			data:         withValues(map[string]string{"vpc_block_provider_name": ""}),
+		},
+		{
+			testCaseName: "Softlayer block only",
+			data:         softlayerConfig,
+		},
+		{
+			testCaseName: "Softlayer file only",
+			data:         strings.Replace(softlayerConfig, "softlayer_block_enabled", "softlayer_file_enabled", 1),
+		},
+		{
+			testCaseName:   "Softlayer bad timeout and endpoint",
+			data:           strings.Replace(strings.Replace(softlayerConfig, `"20s"`, `"20"`, 1), "https://api", "http://api", 1),
+			expectedCodes:  []string{InvalidConfigDuration, InvalidConfigURL},
+			expectedFields: []string{"softlayer.softlayer_api_timeout", "softlayer.softlayer_endpoint_url"},
+		},
+		{
+			testCaseName:   "Softlayer disabled",
+			data:           strings.Replace(softlayerConfig, "softlayer_block_enabled = true", "softlayer_block_enabled = false", 1),
+			expectedCodes:  []string{NoProviderEnabled},
+			expectedFields: []string{""},
+		},
+		{
+			testCaseName:   "Classic generation",
+			data:           withValues(map[string]string{"provider_type": `"gc"`}),
+			expectedCodes:  []string{MissingConfigField, MissingConfigField, MissingConfigField},
+			expectedFields: []string{"vpc.gc_api_key", "vpc.gc_riaas_endpoint_url", "vpc.gc_token_exchange_endpoint_url"},
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			_, issues := ValidateData(zap.NewNop(), testcase.data)
+			fields := []string{}
+			for _, issue := range issues {
+				fields = append(fields, issue.Field)
+				assert.Equal(t, codes.InvalidArgument, issue.Type)
+				assert.NotEmpty(t, issue.Action)
+				assert.NotContains(t, issue.Description, "%!")
+				assert.NotContains(t, issue.Action, "%!")
+			}
+			if len(testcase.expectedCodes) == 0 {
+				assert.Empty(t, issues, issues.Error())
+				return
+			}
+			assert.Equal(t, testcase.expectedCodes, issues.Codes())
+			assert.Equal(t, testcase.expectedFields, fields)
+			assert.NotNil(t, issues.Err())
+		})
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	issue := newIssue(InvalidConfigDuration, "vpc.vpc_api_timeout", "120")
+	assert.Equal(t, "{Code: InvalidConfigDuration, Field: vpc.vpc_api_timeout, Description: The field 'vpc.vpc_api_timeout' is not a valid duration: '120', Action: Please set 'vpc.vpc_api_timeout' to a positive duration such as '120s' or '2m'}", issue.String())
+
+	// Secrets are never part of the issues
+	data := strings.Replace(withValues(map[string]string{"g2_riaas_endpoint_url": `"ftp://host"`}), `"api-key"`, `"secret-api-key"`+"\n  iam_client_id = \"client\"", 1)
+	_, issues := ValidateData(zap.NewNop(), data)
+	assert.Len(t, issues, 2)
+	assert.NotContains(t, issues.Error(), "secret-api-key")
+}
@@ -0,0 +1,207 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package events reports CSI messages as Kubernetes events of the PVC, PV or
+// Pod they concern.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/golang/glog"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// DefaultInterval during which identical events of an object are reported once
+	DefaultInterval = 5 * time.Minute
+
+	// DefaultQPS of events reported to the API server
+	DefaultQPS = 1.0
+
+	// DefaultBurst of events reported to the API server
+	DefaultBurst = 10
+
+	// maxTrackedEvents bounds the events remembered for deduplication
+	maxTrackedEvents = 4096
+)
+
+// NewEventRecorder returns a recorder sending events of component to the API
+// server through client
+func NewEventRecorder(client kubernetes.Interface, component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(glog.Infof)
+	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
+}
+
+// eventKey identifies identical events of an object
+type eventKey struct {
+	object string
+	reason string
+}
+
+// reportedEvent tracks the events of a key
+type reportedEvent struct {
+	reportedAt time.Time
+	suppressed int
+}
+
+// EventReporter records CSI messages as events with the reason code as event
+// reason. An event identical to one reported within Interval for the same
+// object is suppressed, and counted in the next reported one. Events beyond
+// QPS and Burst are dropped so that failure loops do not flood the API server.
+type EventReporter struct {
+	logger   *zap.Logger
+	recorder record.EventRecorder
+
+	// Interval during which identical events of an object are reported once
+	Interval time.Duration
+
+	// QPS and Burst of events reported to the API server
+	QPS   float64
+	Burst int
+
+	once    sync.Once
+	limiter *rate.Limiter
+	mux     sync.Mutex
+	events  map[eventKey]*reportedEvent
+	now     func() time.Time
+}
+
+// NewEventReporter creates an EventReporter recording events with recorder
+func NewEventReporter(logger *zap.Logger, recorder record.EventRecorder) *EventReporter {
+	return &EventReporter{
+		logger:   logger,
+		recorder: recorder,
+		Interval: DefaultInterval,
+		QPS:      DefaultQPS,
+		Burst:    DefaultBurst,
+		events:   map[eventKey]*reportedEvent{},
+		now:      time.Now,
+	}
+}
+
+// objectKey identifies object, a PVC, PV or Pod
+func objectKey(object runtime.Object) string {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return fmt.Sprintf("%T", object)
+	}
+	if uid := accessor.GetUID(); uid != "" {
+		return string(uid)
+	}
+	return fmt.Sprintf("%s/%s/%s", reflect.TypeOf(object).String(), accessor.GetNamespace(), accessor.GetName())
+}
+
+// eventMessage returns the event note of msg: what failed, why, and the
+// action the user can take
+func eventMessage(msg messages.Message, suppressed int) string {
+	parts := []string{msg.Description}
+	if msg.CSIError != "" {
+		parts = append(parts, "Error: "+msg.CSIError)
+	}
+	if msg.BackendError != "" {
+		parts = append(parts, "BackendError: "+msg.BackendError)
+	}
+	if msg.Action != "" {
+		parts = append(parts, "Action: "+msg.Action)
+	}
+	if msg.RequestID != "" {
+		parts = append(parts, "RequestID: "+msg.RequestID)
+	}
+	note := strings.Join(parts, ", ")
+	if suppressed > 0 {
+		note = fmt.Sprintf("%s (%d similar events suppressed)", note, suppressed)
+	}
+	return note
+}
+
+// Report records msg as an event of object, a PVC, PV or Pod, and returns
+// whether it was sent rather than deduplicated or rate limited. Messages of
+// the OK gRPC code are Normal events, others Warning events.
+func (r *EventReporter) Report(object runtime.Object, msg messages.Message) bool {
+	r.once.Do(func() {
+		r.limiter = rate.NewLimiter(rate.Limit(r.QPS), r.Burst)
+	})
+	key := eventKey{object: objectKey(object), reason: msg.Code}
+
+	r.mux.Lock()
+	now := r.now()
+	event, ok := r.events[key]
+	if ok && now.Sub(event.reportedAt) < r.Interval {
+		event.suppressed++
+		r.mux.Unlock()
+		r.logger.Debug("Suppressed duplicate event", zap.String("object", key.object), zap.String("reason", key.reason))
+		return false
+	}
+	if !r.limiter.AllowN(now, 1) {
+		r.mux.Unlock()
+		r.logger.Warn("Dropped event, rate limit exceeded", zap.String("object", key.object), zap.String("reason", key.reason))
+		return false
+	}
+	suppressed := 0
+	if ok {
+		suppressed = event.suppressed
+	}
+	r.events[key] = &reportedEvent{reportedAt: now}
+	r.prune(now)
+	r.mux.Unlock()
+
+	eventType := v1.EventTypeWarning
+	if msg.Type == codes.OK {
+		eventType = v1.EventTypeNormal
+	}
+	r.recorder.Event(object, eventType, msg.Code, eventMessage(msg, suppressed))
+	return true
+}
+
+// ReportError records err as an event of object if it is a messages.Message,
+// see Report
+func (r *EventReporter) ReportError(object runtime.Object, err error) bool {
+	var msg messages.Message
+	if !errors.As(err, &msg) {
+		return false
+	}
+	return r.Report(object, msg)
+}
+
+// prune forgets events older than Interval once too many are tracked
+func (r *EventReporter) prune(now time.Time) {
+	if len(r.events) <= maxTrackedEvents {
+		return
+	}
+	for key, event := range r.events {
+		if now.Sub(event.reportedAt) >= r.Interval {
+			delete(r.events, key)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package events ...
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestPod(name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID("uid-" + name)}}
+}
+
+// newTestReporter returns a reporter with a controllable clock
+func newTestReporter() (*EventReporter, *record.FakeRecorder, *time.Time) {
+	recorder := record.NewFakeRecorder(100)
+	reporter := NewEventReporter(zap.NewNop(), recorder)
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	reporter.now = func() time.Time { return now }
+	return reporter, recorder, &now
+}
+
+// recorded returns the events recorded so far
+func recorded(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	reporter, recorder, _ := newTestReporter()
+	msg := messages.MountFailedMessage("/dev/vdb", "/mnt")
+	msg.CSIError = "exit status 32"
+	msg.RequestID = "req-1"
+
+	assert.True(t, reporter.Report(newTestPod("app"), msg))
+	events := recorded(recorder)
+	assert.Equal(t, 1, len(events))
+	assert.True(t, strings.HasPrefix(events[0], "Warning MountFailed Failed to mount"), events[0])
+	assert.Contains(t, events[0], "Error: exit status 32")
+	assert.Contains(t, events[0], "Action: "+msg.Action)
+	assert.Contains(t, events[0], "RequestID: req-1")
+
+	assert.True(t, reporter.Report(newTestPod("other"), messages.Message{Code: "VolumeMetaDataSaved", Type: codes.OK, Description: "Success"}))
+	assert.Equal(t, []string{"Normal VolumeMetaDataSaved Success"}, recorded(recorder))
+}
+
+func TestReportDeduplicates(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	reporter, recorder, now := newTestReporter()
+	pod := newTestPod("app")
+	msg := messages.TargetPathCreateFailedMessage("/var/lib/kubelet/pods/1")
+
+	assert.True(t, reporter.Report(pod, msg))
+	assert.False(t, reporter.Report(pod, msg))
+	assert.False(t, reporter.Report(pod, msg))
+	// Other reasons and objects are not duplicates
+	assert.True(t, reporter.Report(pod, messages.EmptyVolumeIDMessage()))
+	assert.True(t, reporter.Report(newTestPod("other"), msg))
+	assert.Equal(t, 3, len(recorded(recorder)))
+
+	*now = now.Add(DefaultInterval)
+	assert.True(t, reporter.Report(pod, msg))
+	events := recorded(recorder)
+	assert.Equal(t, 1, len(events))
+	assert.Contains(t, events[0], "(2 similar events suppressed)")
+
+	assert.False(t, reporter.Report(pod, msg))
+}
+
+func TestReportRateLimited(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	reporter, recorder, now := newTestReporter()
+	reporter.QPS = 1
+	reporter.Burst = 2
+	msg := messages.EmptyVolumeIDMessage()
+
+	assert.True(t, reporter.Report(newTestPod("pod-1"), msg))
+	assert.True(t, reporter.Report(newTestPod("pod-2"), msg))
+	assert.False(t, reporter.Report(newTestPod("pod-3"), msg))
+	assert.Equal(t, 2, len(recorded(recorder)))
+
+	// Dropped events are not deduplicated
+	*now = now.Add(time.Second)
+	assert.True(t, reporter.Report(newTestPod("pod-3"), msg))
+}
+
+func TestReportError(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	reporter, recorder, _ := newTestReporter()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pvc-1"}}
+
+	err := fmt.Errorf("create: %w", messages.GetCSIError(ctxLog, messages.VolumeCreationFailed, reqID, errors.New("quota exceeded")))
+	assert.True(t, reporter.ReportError(pvc, err))
+	assert.False(t, reporter.ReportError(pvc, errors.New("plain")))
+
+	events := recorded(recorder)
+	assert.Equal(t, 1, len(events))
+	assert.True(t, strings.HasPrefix(events[0], "Warning VolumeCreationFailed"), events[0])
+}
+
+func TestPrune(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	reporter, _, now := newTestReporter()
+	reporter.recorder = &record.FakeRecorder{} // discards events
+	reporter.QPS = 1e6
+	reporter.Burst = 1e6
+	for i := 0; i <= maxTrackedEvents; i++ {
+		reporter.Report(newTestPod(fmt.Sprintf("pod-%d", i)), messages.EmptyVolumeIDMessage())
+	}
+	*now = now.Add(DefaultInterval)
+	reporter.Report(newTestPod("last"), messages.EmptyVolumeIDMessage())
+	assert.Equal(t, 1, len(reporter.events))
+}
+
+func TestNewEventRecorder(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	recorder := NewEventRecorder(clientset, "vpc-block-csi-controller")
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+	recorder.Event(pv, v1.EventTypeNormal, "VolumeMetaDataSaved", "Success")
+
+	assert.Eventually(t, func() bool {
+		list, err := clientset.CoreV1().Events("").List(context.Background(), metav1.ListOptions{})
+		return err == nil && len(list.Items) == 1 && list.Items[0].Source.Component == "vpc-block-csi-controller"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,278 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/IBM/ibm-csi-common/pkg/metadata"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+const (
+	// AccountIDParameter is the storage class parameter, and so the volume
+	// attribute, naming the account of a volume
+	AccountIDParameter = "accountID"
+
+	// ResourceGroupParameter is the storage class parameter, and so the
+	// volume attribute, naming the resource group of a volume
+	ResourceGroupParameter = "resourceGroup"
+)
+
+// Account is the IBM Cloud account and resource group a volume lives in
+type Account struct {
+	ID              string
+	ResourceGroupID string
+}
+
+// String ...
+func (a Account) String() string {
+	return a.ID + "/" + a.ResourceGroupID
+}
+
+// AccountFromParameters returns the account named by the storage class
+// parameters or volume attributes params, empty fields stand for the home
+// account
+func AccountFromParameters(params map[string]string) Account {
+	return Account{ID: params[AccountIDParameter], ResourceGroupID: params[ResourceGroupParameter]}
+}
+
+// HomeAccount returns the account of the cluster, from the node metadata and
+// the resource group of conf
+func HomeAccount(nodeMetadata metadata.NodeMetadata, conf *config.Config) Account {
+	account := Account{ID: nodeMetadata.GetAccountID()}
+	if conf != nil && conf.VPC != nil {
+		account.ResourceGroupID = conf.VPC.G2ResourceGroupID
+		if account.ResourceGroupID == "" {
+			account.ResourceGroupID = conf.VPC.ResourceGroupID
+		}
+	}
+	return account
+}
+
+type accountKey struct{}
+
+// ContextWithAccount returns a copy of ctx which makes GetProviderSession of
+// an AccountCloudProvider return a session for account
+func ContextWithAccount(ctx context.Context, account Account) context.Context {
+	return context.WithValue(ctx, accountKey{}, account)
+}
+
+// AccountFromContext returns the account set by ContextWithAccount
+func AccountFromContext(ctx context.Context) (Account, bool) {
+	account, ok := ctx.Value(accountKey{}).(Account)
+	return account, ok
+}
+
+// CredentialSource resolves the credentials of an account, e.g. from a
+// secret per account or a trusted profile
+type CredentialSource interface {
+	GetCredentials(ctx context.Context, account Account) (provider.ContextCredentials, error)
+}
+
+// CredentialSourceFunc is a CredentialSource implemented by a function
+type CredentialSourceFunc func(ctx context.Context, account Account) (provider.ContextCredentials, error)
+
+// GetCredentials ...
+func (f CredentialSourceFunc) GetCredentials(ctx context.Context, account Account) (provider.ContextCredentials, error) {
+	return f(ctx, account)
+}
+
+// ErrNoCredentials is returned by a CredentialSource which has no
+// credentials for an account
+var ErrNoCredentials = errors.New("no credentials for account")
+
+// StaticCredentialSource maps account IDs to IAM API keys
+type StaticCredentialSource map[string]string
+
+// GetCredentials ...
+func (s StaticCredentialSource) GetCredentials(ctx context.Context, account Account) (provider.ContextCredentials, error) {
+	apiKey, ok := s[account.ID]
+	if !ok {
+		return provider.ContextCredentials{}, fmt.Errorf("%w %s", ErrNoCredentials, account.ID)
+	}
+	return provider.ContextCredentials{
+		AuthType:     provider.IAMAPIKey,
+		IAMAccountID: account.ID,
+		Credential:   apiKey,
+	}, nil
+}
+
+// SessionFactory opens a session with credentials, conf is the config of the
+// home account set to the resource group of the session
+type SessionFactory func(ctx context.Context, logger *zap.Logger, conf *config.Config, credentials provider.ContextCredentials) (provider.Session, error)
+
+// AccountCloudProvider returns sessions of the home provider, unless the
+// context names another account or resource group. Then the credentials of
+// that account are resolved and a session opened for it. Sessions of other
+// accounts are cached like by CachingCloudProvider.
+type AccountCloudProvider struct {
+	CloudProviderInterface
+
+	logger      *zap.Logger
+	home        Account
+	credentials CredentialSource
+	newSession  SessionFactory
+
+	mux      sync.Mutex
+	accounts map[Account]*CachingCloudProvider
+}
+
+var _ CloudProviderInterface = &AccountCloudProvider{}
+var _ SessionInvalidator = &AccountCloudProvider{}
+var _ SessionFlusher = &AccountCloudProvider{}
+
+// NewAccountCloudProvider wraps the provider of the home account. Call Close
+// on shutdown to close the sessions of other accounts.
+func NewAccountCloudProvider(logger *zap.Logger, home CloudProviderInterface, homeAccount Account, credentials CredentialSource, newSession SessionFactory) *AccountCloudProvider {
+	return &AccountCloudProvider{
+		CloudProviderInterface: home,
+		logger:                 logger,
+		home:                   homeAccount,
+		credentials:            credentials,
+		newSession:             newSession,
+		accounts:               map[Account]*CachingCloudProvider{},
+	}
+}
+
+// HomeAccount ...
+func (acp *AccountCloudProvider) HomeAccount() Account {
+	return acp.home
+}
+
+// GetProviderSession returns a session for the account of ctx, see
+// ContextWithAccount, or for the home account
+func (acp *AccountCloudProvider) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
+	account, _ := AccountFromContext(ctx)
+	account = acp.resolve(account)
+	if account == acp.home {
+		return acp.CloudProviderInterface.GetProviderSession(ctx, logger)
+	}
+	logger.Info("Getting session for account", zap.String("account", account.ID), zap.String("resourceGroup", account.ResourceGroupID))
+	return acp.accountProvider(account).GetProviderSession(ctx, logger)
+}
+
+// InvalidateSession drops session from the cache of the account it belongs to
+func (acp *AccountCloudProvider) InvalidateSession(session provider.Session, err error) bool {
+	if invalidator, ok := acp.CloudProviderInterface.(SessionInvalidator); ok && invalidator.InvalidateSession(session, err) {
+		return true
+	}
+	acp.mux.Lock()
+	defer acp.mux.Unlock()
+	for _, cloudProvider := range acp.accounts {
+		if cloudProvider.InvalidateSession(session, err) {
+			return true
+		}
+	}
+	return false
+}
+
+// FlushSessions drops the cached sessions of the home provider and of the
+// other accounts
+func (acp *AccountCloudProvider) FlushSessions() {
+	if flusher, ok := acp.CloudProviderInterface.(SessionFlusher); ok {
+		flusher.FlushSessions()
+	}
+	acp.mux.Lock()
+	defer acp.mux.Unlock()
+	for _, cloudProvider := range acp.accounts {
+		cloudProvider.FlushSessions()
+	}
+}
+
+// Close closes the sessions of other accounts, the home provider is left to
+// its owner
+func (acp *AccountCloudProvider) Close() {
+	acp.mux.Lock()
+	accounts := acp.accounts
+	acp.accounts = map[Account]*CachingCloudProvider{}
+	acp.mux.Unlock()
+	for _, cloudProvider := range accounts {
+		cloudProvider.Close()
+	}
+}
+
+// resolve fills the empty fields of account from the home account. An
+// account without resource group uses the home one only in the home account.
+func (acp *AccountCloudProvider) resolve(account Account) Account {
+	if account.ID == "" {
+		account.ID = acp.home.ID
+	}
+	if account.ResourceGroupID == "" && account.ID == acp.home.ID {
+		account.ResourceGroupID = acp.home.ResourceGroupID
+	}
+	return account
+}
+
+// accountProvider returns the session cache of account
+func (acp *AccountCloudProvider) accountProvider(account Account) *CachingCloudProvider {
+	acp.mux.Lock()
+	defer acp.mux.Unlock()
+	cloudProvider, ok := acp.accounts[account]
+	if !ok {
+		cloudProvider = NewCachingCloudProvider(acp.logger, &accountSessionProvider{
+			CloudProviderInterface: acp.CloudProviderInterface,
+			account:                account,
+			credentials:            acp.credentials,
+			newSession:             acp.newSession,
+		})
+		acp.accounts[account] = cloudProvider
+	}
+	return cloudProvider
+}
+
+// accountSessionProvider opens sessions for one account
+type accountSessionProvider struct {
+	CloudProviderInterface
+	account     Account
+	credentials CredentialSource
+	newSession  SessionFactory
+}
+
+// GetProviderSession ...
+func (asp *accountSessionProvider) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
+	credentials, err := asp.credentials.GetCredentials(ctx, asp.account)
+	if err != nil {
+		return nil, err
+	}
+	if credentials.IAMAccountID == "" {
+		credentials.IAMAccountID = asp.account.ID
+	}
+	return asp.newSession(ctx, logger, asp.GetConfig(), credentials)
+}
+
+// GetConfig returns the home config set to the resource group of the account
+func (asp *accountSessionProvider) GetConfig() *config.Config {
+	homeConfig := asp.CloudProviderInterface.GetConfig()
+	if homeConfig == nil {
+		return nil
+	}
+	conf := *homeConfig
+	if homeConfig.VPC != nil && asp.account.ResourceGroupID != "" {
+		vpc := *homeConfig.VPC
+		vpc.ResourceGroupID = asp.account.ResourceGroupID
+		vpc.G2ResourceGroupID = asp.account.ResourceGroupID
+		conf.VPC = &vpc
+	}
+	return &conf
+}
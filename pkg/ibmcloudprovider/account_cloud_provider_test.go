@@ -0,0 +1,174 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/metadata"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// openedSession is a session opened by the test SessionFactory
+type openedSession struct {
+	session     *fake.FakeSession
+	conf        *config.Config
+	credentials provider.ContextCredentials
+}
+
+// sessionRecorder is a SessionFactory recording the sessions it opens
+type sessionRecorder struct {
+	mux    sync.Mutex
+	opened []openedSession
+}
+
+func (sr *sessionRecorder) newSession(ctx context.Context, logger *zap.Logger, conf *config.Config, credentials provider.ContextCredentials) (provider.Session, error) {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	session := &fake.FakeSession{}
+	sr.opened = append(sr.opened, openedSession{session: session, conf: conf, credentials: credentials})
+	return session, nil
+}
+
+func (sr *sessionRecorder) find(session provider.Session) (openedSession, bool) {
+	sr.mux.Lock()
+	defer sr.mux.Unlock()
+	for _, opened := range sr.opened {
+		if opened.session == session {
+			return opened, true
+		}
+	}
+	return openedSession{}, false
+}
+
+func newTestAccountProvider(t *testing.T) (*AccountCloudProvider, *FakeIBMCloudStorageProvider, *sessionRecorder) {
+	logger, teardown := GetTestLogger(t)
+	t.Cleanup(teardown)
+	home := NewFakeProviderBuilder().WithConfig(&config.Config{VPC: &config.VPCProviderConfig{
+		VPCBlockProviderType: "g2",
+		G2ResourceGroupID:    "home-rg",
+		G2APIKey:             "home-key",
+	}}).Build()
+	nodeMetadata := &metadata.FakeNodeMetadata{}
+	nodeMetadata.GetAccountIDReturns("home-account")
+	recorder := &sessionRecorder{}
+	credentials := StaticCredentialSource{"account-a": "key-a", "account-b": "key-b", "home-account": "home-key"}
+
+	acp := NewAccountCloudProvider(logger, home, HomeAccount(nodeMetadata, home.GetConfig()), credentials, recorder.newSession)
+	t.Cleanup(acp.Close)
+	return acp, home, recorder
+}
+
+func TestAccountCloudProvider(t *testing.T) {
+	acp, home, recorder := newTestAccountProvider(t)
+	assert.Equal(t, Account{ID: "home-account", ResourceGroupID: "home-rg"}, acp.HomeAccount())
+
+	testCases := []struct {
+		testCaseName          string
+		params                map[string]string
+		expectHome            bool
+		expectedAccountID     string
+		expectedResourceGroup string
+		expectedCredential    string
+	}{
+		{testCaseName: "no account", params: map[string]string{}, expectHome: true},
+		{testCaseName: "home account", params: map[string]string{AccountIDParameter: "home-account", ResourceGroupParameter: "home-rg"}, expectHome: true},
+		{testCaseName: "account a", params: map[string]string{AccountIDParameter: "account-a"}, expectedAccountID: "account-a", expectedResourceGroup: "home-rg", expectedCredential: "key-a"},
+		{testCaseName: "account b resource group", params: map[string]string{AccountIDParameter: "account-b", ResourceGroupParameter: "rg-b"}, expectedAccountID: "account-b", expectedResourceGroup: "rg-b", expectedCredential: "key-b"},
+		{testCaseName: "home account other resource group", params: map[string]string{ResourceGroupParameter: "rg-2"}, expectedAccountID: "home-account", expectedResourceGroup: "rg-2", expectedCredential: "home-key"},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			ctx := ContextWithAccount(context.Background(), AccountFromParameters(testcase.params))
+			session, err := acp.GetProviderSession(ctx, zap.NewNop())
+			assert.Nil(t, err)
+			if testcase.expectHome {
+				assert.Same(t, home.Session(), session)
+				return
+			}
+			opened, ok := recorder.find(unwrapSession(session))
+			assert.True(t, ok)
+			assert.Equal(t, testcase.expectedAccountID, opened.credentials.IAMAccountID)
+			assert.Equal(t, testcase.expectedCredential, opened.credentials.Credential)
+			assert.Equal(t, provider.IAMAPIKey, opened.credentials.AuthType)
+			assert.Equal(t, testcase.expectedResourceGroup, opened.conf.VPC.G2ResourceGroupID)
+			assert.Equal(t, "g2", opened.conf.VPC.VPCBlockProviderType)
+		})
+	}
+	// The home config is left alone
+	assert.Equal(t, "home-rg", home.GetConfig().VPC.G2ResourceGroupID)
+	assert.Equal(t, 3, len(recorder.opened))
+}
+
+func TestAccountCloudProviderCachesSessions(t *testing.T) {
+	acp, _, recorder := newTestAccountProvider(t)
+	ctxA := ContextWithAccount(context.Background(), Account{ID: "account-a"})
+	ctxB := ContextWithAccount(context.Background(), Account{ID: "account-b"})
+
+	first, err := acp.GetProviderSession(ctxA, zap.NewNop())
+	assert.Nil(t, err)
+	second, err := acp.GetProviderSession(ctxA, zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+	other, err := acp.GetProviderSession(ctxB, zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, other)
+	assert.Equal(t, 2, len(recorder.opened))
+
+	// A rejected session of account a is replaced, account b keeps its own
+	assert.True(t, acp.InvalidateSession(first, status.Error(codes.Unauthenticated, "token expired")))
+	third, err := acp.GetProviderSession(ctxA, zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, third)
+	again, _ := acp.GetProviderSession(ctxB, zap.NewNop())
+	assert.Same(t, other, again)
+
+	acp.Close()
+	assert.Equal(t, 1, unwrapSession(third).(*fake.FakeSession).CloseCallCount())
+	assert.Equal(t, 1, unwrapSession(other).(*fake.FakeSession).CloseCallCount())
+}
+
+func TestAccountCloudProviderUnknownAccount(t *testing.T) {
+	acp, _, recorder := newTestAccountProvider(t)
+
+	ctx := ContextWithAccount(context.Background(), Account{ID: "account-c"})
+	session, err := acp.GetProviderSession(ctx, zap.NewNop())
+	assert.Nil(t, session)
+	assert.True(t, errors.Is(err, ErrNoCredentials))
+	assert.Empty(t, recorder.opened)
+}
+
+func TestCredentialSourceFunc(t *testing.T) {
+	source := CredentialSourceFunc(func(ctx context.Context, account Account) (provider.ContextCredentials, error) {
+		return provider.ContextCredentials{AuthType: provider.IAMAccessToken, Credential: "token-" + account.ID}, nil
+	})
+	credentials, err := source.GetCredentials(context.Background(), Account{ID: "account-a"})
+	assert.Nil(t, err)
+	assert.Equal(t, "token-account-a", credentials.Credential)
+	assert.Equal(t, "account-a/rg", Account{ID: "account-a", ResourceGroupID: "rg"}.String())
+}
@@ -0,0 +1,249 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"net/http"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+)
+
+// authCheckedSession is the session handed out by CachingCloudProvider. It
+// reports the error of every operation to the provider, which drops the
+// session from its cache once the backend rejects its token.
+type authCheckedSession struct {
+	provider.Session
+	ccp *CachingCloudProvider
+}
+
+var _ provider.Session = &authCheckedSession{}
+
+// unwrapSession returns the provider session wrapped by session
+func unwrapSession(session provider.Session) provider.Session {
+	if checked, ok := session.(*authCheckedSession); ok {
+		return checked.Session
+	}
+	return session
+}
+
+// check reports err of an operation of s to the caching provider
+func (s *authCheckedSession) check(err error) {
+	if err != nil {
+		s.ccp.InvalidateSession(s, err)
+	}
+}
+
+// Close does nothing, the session is shared and CachingCloudProvider closes
+// it once it is replaced or the provider is closed
+func (s *authCheckedSession) Close() {}
+
+// GetVolumeProfileByName ...
+func (s *authCheckedSession) GetVolumeProfileByName(name string) (*provider.Profile, error) {
+	profile, err := s.Session.GetVolumeProfileByName(name)
+	s.check(err)
+	return profile, err
+}
+
+// CreateVolume ...
+func (s *authCheckedSession) CreateVolume(volumeRequest provider.Volume) (*provider.Volume, error) {
+	volume, err := s.Session.CreateVolume(volumeRequest)
+	s.check(err)
+	return volume, err
+}
+
+// CreateVolumeFromSnapshot ...
+func (s *authCheckedSession) CreateVolumeFromSnapshot(snapshot provider.Snapshot, tags map[string]string) (*provider.Volume, error) {
+	volume, err := s.Session.CreateVolumeFromSnapshot(snapshot, tags)
+	s.check(err)
+	return volume, err
+}
+
+// UpdateVolume ...
+func (s *authCheckedSession) UpdateVolume(volume provider.Volume) error {
+	err := s.Session.UpdateVolume(volume)
+	s.check(err)
+	return err
+}
+
+// DeleteVolume ...
+func (s *authCheckedSession) DeleteVolume(volume *provider.Volume) error {
+	err := s.Session.DeleteVolume(volume)
+	s.check(err)
+	return err
+}
+
+// GetVolume ...
+func (s *authCheckedSession) GetVolume(id string) (*provider.Volume, error) {
+	volume, err := s.Session.GetVolume(id)
+	s.check(err)
+	return volume, err
+}
+
+// GetVolumeByName ...
+func (s *authCheckedSession) GetVolumeByName(name string) (*provider.Volume, error) {
+	volume, err := s.Session.GetVolumeByName(name)
+	s.check(err)
+	return volume, err
+}
+
+// ListVolumes ...
+func (s *authCheckedSession) ListVolumes(limit int, start string, tags map[string]string) (*provider.VolumeList, error) {
+	volumes, err := s.Session.ListVolumes(limit, start, tags)
+	s.check(err)
+	return volumes, err
+}
+
+// GetVolumeByRequestID ...
+func (s *authCheckedSession) GetVolumeByRequestID(requestID string) (*provider.Volume, error) {
+	volume, err := s.Session.GetVolumeByRequestID(requestID)
+	s.check(err)
+	return volume, err
+}
+
+// AuthorizeVolume ...
+func (s *authCheckedSession) AuthorizeVolume(volumeAuthorization provider.VolumeAuthorization) error {
+	err := s.Session.AuthorizeVolume(volumeAuthorization)
+	s.check(err)
+	return err
+}
+
+// ExpandVolume ...
+func (s *authCheckedSession) ExpandVolume(expandVolumeRequest provider.ExpandVolumeRequest) (int64, error) {
+	capacity, err := s.Session.ExpandVolume(expandVolumeRequest)
+	s.check(err)
+	return capacity, err
+}
+
+// AttachVolume ...
+func (s *authCheckedSession) AttachVolume(attachRequest provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	response, err := s.Session.AttachVolume(attachRequest)
+	s.check(err)
+	return response, err
+}
+
+// DetachVolume ...
+func (s *authCheckedSession) DetachVolume(detachRequest provider.VolumeAttachmentRequest) (*http.Response, error) {
+	response, err := s.Session.DetachVolume(detachRequest)
+	s.check(err)
+	return response, err
+}
+
+// WaitForAttachVolume ...
+func (s *authCheckedSession) WaitForAttachVolume(attachRequest provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	response, err := s.Session.WaitForAttachVolume(attachRequest)
+	s.check(err)
+	return response, err
+}
+
+// WaitForDetachVolume ...
+func (s *authCheckedSession) WaitForDetachVolume(detachRequest provider.VolumeAttachmentRequest) error {
+	err := s.Session.WaitForDetachVolume(detachRequest)
+	s.check(err)
+	return err
+}
+
+// GetVolumeAttachment ...
+func (s *authCheckedSession) GetVolumeAttachment(attachRequest provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	response, err := s.Session.GetVolumeAttachment(attachRequest)
+	s.check(err)
+	return response, err
+}
+
+// CreateSnapshot ...
+func (s *authCheckedSession) CreateSnapshot(sourceVolumeID string, snapshotParameters provider.SnapshotParameters) (*provider.Snapshot, error) {
+	snapshot, err := s.Session.CreateSnapshot(sourceVolumeID, snapshotParameters)
+	s.check(err)
+	return snapshot, err
+}
+
+// DeleteSnapshot ...
+func (s *authCheckedSession) DeleteSnapshot(snapshot *provider.Snapshot) error {
+	err := s.Session.DeleteSnapshot(snapshot)
+	s.check(err)
+	return err
+}
+
+// GetSnapshot ...
+func (s *authCheckedSession) GetSnapshot(snapshotID string, sourceVolumeID ...string) (*provider.Snapshot, error) {
+	snapshot, err := s.Session.GetSnapshot(snapshotID, sourceVolumeID...)
+	s.check(err)
+	return snapshot, err
+}
+
+// GetSnapshotByName ...
+func (s *authCheckedSession) GetSnapshotByName(snapshotName string, scopeID ...string) (*provider.Snapshot, error) {
+	snapshot, err := s.Session.GetSnapshotByName(snapshotName, scopeID...)
+	s.check(err)
+	return snapshot, err
+}
+
+// ListSnapshots ...
+func (s *authCheckedSession) ListSnapshots(limit int, start string, tags map[string]string) (*provider.SnapshotList, error) {
+	snapshots, err := s.Session.ListSnapshots(limit, start, tags)
+	s.check(err)
+	return snapshots, err
+}
+
+// CreateVolumeAccessPoint ...
+func (s *authCheckedSession) CreateVolumeAccessPoint(accessPointRequest provider.VolumeAccessPointRequest) (*provider.VolumeAccessPointResponse, error) {
+	response, err := s.Session.CreateVolumeAccessPoint(accessPointRequest)
+	s.check(err)
+	return response, err
+}
+
+// DeleteVolumeAccessPoint ...
+func (s *authCheckedSession) DeleteVolumeAccessPoint(deleteAccessPointRequest provider.VolumeAccessPointRequest) (*http.Response, error) {
+	response, err := s.Session.DeleteVolumeAccessPoint(deleteAccessPointRequest)
+	s.check(err)
+	return response, err
+}
+
+// WaitForCreateVolumeAccessPoint ...
+func (s *authCheckedSession) WaitForCreateVolumeAccessPoint(accessPointRequest provider.VolumeAccessPointRequest) (*provider.VolumeAccessPointResponse, error) {
+	response, err := s.Session.WaitForCreateVolumeAccessPoint(accessPointRequest)
+	s.check(err)
+	return response, err
+}
+
+// WaitForDeleteVolumeAccessPoint ...
+func (s *authCheckedSession) WaitForDeleteVolumeAccessPoint(deleteAccessPointRequest provider.VolumeAccessPointRequest) error {
+	err := s.Session.WaitForDeleteVolumeAccessPoint(deleteAccessPointRequest)
+	s.check(err)
+	return err
+}
+
+// GetVolumeAccessPoint ...
+func (s *authCheckedSession) GetVolumeAccessPoint(accessPointRequest provider.VolumeAccessPointRequest) (*provider.VolumeAccessPointResponse, error) {
+	response, err := s.Session.GetVolumeAccessPoint(accessPointRequest)
+	s.check(err)
+	return response, err
+}
+
+// GetSubnetForVolumeAccessPoint ...
+func (s *authCheckedSession) GetSubnetForVolumeAccessPoint(subnetRequest provider.SubnetRequest) (string, error) {
+	subnet, err := s.Session.GetSubnetForVolumeAccessPoint(subnetRequest)
+	s.check(err)
+	return subnet, err
+}
+
+// GetSecurityGroupForVolumeAccessPoint ...
+func (s *authCheckedSession) GetSecurityGroupForVolumeAccessPoint(securityGroupRequest provider.SecurityGroupRequest) (string, error) {
+	securityGroup, err := s.Session.GetSecurityGroupForVolumeAccessPoint(securityGroupRequest)
+	s.check(err)
+	return securityGroup, err
+}
@@ -0,0 +1,295 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// DefaultSessionTTL is how long a session is reused. IAM access tokens
+	// expire after an hour, so sessions are renewed well before that.
+	DefaultSessionTTL = 50 * time.Minute
+
+	// DefaultSessionRefreshBefore is how long before expiry a session is
+	// renewed in the background
+	DefaultSessionRefreshBefore = 5 * time.Minute
+
+	// DefaultSessionCloseDelay is how long a replaced session stays open for
+	// the operations still using it
+	DefaultSessionCloseDelay = time.Minute
+)
+
+// ErrProviderClosed is returned by GetProviderSession after Close
+var ErrProviderClosed = errors.New("cloud provider is closed")
+
+// SessionInvalidator is implemented by cloud providers which cache sessions.
+// Callers report the errors of session operations, so that a session whose
+// token was rejected is not handed out again.
+type SessionInvalidator interface {
+	InvalidateSession(session provider.Session, err error) bool
+}
+
+// SessionFlusher is implemented by cloud providers which cache sessions, to
+// drop all of them, e.g. after the credentials changed
+type SessionFlusher interface {
+	FlushSessions()
+}
+
+// CachingCloudProvider reuses the sessions of a CloudProviderInterface until
+// their token expires, renews them in the background shortly before that
+// and drops them when the backend rejects their token.
+type CachingCloudProvider struct {
+	CloudProviderInterface
+
+	// TTL is how long a session is reused
+	TTL time.Duration
+	// RefreshBefore is how long before expiry a session is renewed
+	RefreshBefore time.Duration
+	// CloseDelay is how long a replaced session stays open
+	CloseDelay time.Duration
+
+	logger *zap.Logger
+
+	// fetchMux serialises the calls to the wrapped provider
+	fetchMux sync.Mutex
+
+	mux        sync.Mutex
+	session    provider.Session
+	expiresAt  time.Time
+	refreshing bool
+	closed     bool
+	retired    map[provider.Session]*time.Timer
+
+	// wg tracks background refreshes
+	wg  sync.WaitGroup
+	now func() time.Time
+}
+
+var _ CloudProviderInterface = &CachingCloudProvider{}
+var _ SessionInvalidator = &CachingCloudProvider{}
+var _ SessionFlusher = &CachingCloudProvider{}
+var _ ConfigUpdater = &CachingCloudProvider{}
+
+// NewCachingCloudProvider wraps cloudProvider so that GetProviderSession
+// returns a cached session. Call Close on shutdown to close it.
+func NewCachingCloudProvider(logger *zap.Logger, cloudProvider CloudProviderInterface) *CachingCloudProvider {
+	return &CachingCloudProvider{
+		CloudProviderInterface: cloudProvider,
+		TTL:                    DefaultSessionTTL,
+		RefreshBefore:          DefaultSessionRefreshBefore,
+		CloseDelay:             DefaultSessionCloseDelay,
+		logger:                 logger,
+		retired:                map[provider.Session]*time.Timer{},
+		now:                    time.Now,
+	}
+}
+
+// GetProviderSession returns the cached session, or a new one if there is
+// none or it has expired
+func (ccp *CachingCloudProvider) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
+	ccp.mux.Lock()
+	if ccp.closed {
+		ccp.mux.Unlock()
+		return nil, ErrProviderClosed
+	}
+	if session, ok := ccp.cachedSession(); ok {
+		if !ccp.refreshing && !ccp.now().Before(ccp.expiresAt.Add(-ccp.RefreshBefore)) {
+			ccp.refreshing = true
+			ccp.wg.Add(1)
+			go ccp.refresh()
+		}
+		ccp.mux.Unlock()
+		return session, nil
+	}
+	ccp.mux.Unlock()
+
+	ccp.fetchMux.Lock()
+	defer ccp.fetchMux.Unlock()
+	// Another caller may have fetched a session in the meantime
+	ccp.mux.Lock()
+	session, ok := ccp.cachedSession()
+	ccp.mux.Unlock()
+	if ok {
+		return session, nil
+	}
+
+	// The session outlives the request, so it logs with the provider logger
+	ccp.logger.Info("Creating new provider session")
+	session, err := ccp.CloudProviderInterface.GetProviderSession(ctx, ccp.logger)
+	if err != nil {
+		return nil, err
+	}
+	return ccp.store(&authCheckedSession{Session: session, ccp: ccp})
+}
+
+// InvalidateSession drops session from the cache if err shows that the
+// backend rejected its token. It reports whether the session was dropped.
+// The sessions handed out by GetProviderSession call it themselves.
+func (ccp *CachingCloudProvider) InvalidateSession(session provider.Session, err error) bool {
+	if session == nil || !IsAuthError(err) {
+		return false
+	}
+	ccp.mux.Lock()
+	defer ccp.mux.Unlock()
+	if unwrapSession(ccp.session) != unwrapSession(session) {
+		return false
+	}
+	ccp.logger.Warn("Provider session rejected by the backend, dropping it", zap.Error(err))
+	ccp.retire(ccp.session)
+	ccp.session = nil
+	return true
+}
+
+// FlushSessions drops the cached session, the next GetProviderSession opens a
+// new one. The dropped session is closed after CloseDelay.
+func (ccp *CachingCloudProvider) FlushSessions() {
+	ccp.mux.Lock()
+	defer ccp.mux.Unlock()
+	if ccp.session == nil {
+		return
+	}
+	ccp.logger.Info("Flushing provider session")
+	ccp.retire(ccp.session)
+	ccp.session = nil
+}
+
+// UpdateConfig hands conf to the wrapped provider if it can switch configs.
+// The cached session keeps the old config until it is flushed.
+func (ccp *CachingCloudProvider) UpdateConfig(conf *config.Config) bool {
+	if updater, ok := ccp.CloudProviderInterface.(ConfigUpdater); ok {
+		return updater.UpdateConfig(conf)
+	}
+	return false
+}
+
+// Close closes the cached and replaced sessions. GetProviderSession fails
+// with ErrProviderClosed afterwards.
+func (ccp *CachingCloudProvider) Close() {
+	ccp.mux.Lock()
+	if ccp.closed {
+		ccp.mux.Unlock()
+		return
+	}
+	ccp.closed = true
+	ccp.mux.Unlock()
+
+	// Let a background refresh finish, it closes the session it gets
+	ccp.wg.Wait()
+
+	ccp.mux.Lock()
+	defer ccp.mux.Unlock()
+	for session, timer := range ccp.retired {
+		if timer.Stop() && session != unwrapSession(ccp.session) {
+			session.Close()
+		}
+	}
+	ccp.retired = map[provider.Session]*time.Timer{}
+	if ccp.session != nil {
+		unwrapSession(ccp.session).Close()
+		ccp.session = nil
+	}
+}
+
+// cachedSession returns the cached session unless it expired, ccp.mux must
+// be held
+func (ccp *CachingCloudProvider) cachedSession() (provider.Session, bool) {
+	if ccp.session == nil || !ccp.now().Before(ccp.expiresAt) {
+		return nil, false
+	}
+	return ccp.session, true
+}
+
+// refresh replaces the cached session by a new one. The current session
+// stays in use if that fails.
+func (ccp *CachingCloudProvider) refresh() {
+	defer ccp.wg.Done()
+	defer func() {
+		ccp.mux.Lock()
+		ccp.refreshing = false
+		ccp.mux.Unlock()
+	}()
+
+	ccp.fetchMux.Lock()
+	defer ccp.fetchMux.Unlock()
+	ccp.logger.Info("Refreshing provider session")
+	// The session outlives the request which triggered the refresh
+	session, err := ccp.CloudProviderInterface.GetProviderSession(context.Background(), ccp.logger)
+	if err != nil {
+		ccp.logger.Warn("Failed to refresh provider session", zap.Error(err))
+		return
+	}
+	_, _ = ccp.store(&authCheckedSession{Session: session, ccp: ccp})
+}
+
+// store caches session and retires the session it replaces
+func (ccp *CachingCloudProvider) store(session provider.Session) (provider.Session, error) {
+	ccp.mux.Lock()
+	defer ccp.mux.Unlock()
+	if ccp.closed {
+		unwrapSession(session).Close()
+		return nil, ErrProviderClosed
+	}
+	if ccp.session != nil && unwrapSession(ccp.session) != unwrapSession(session) {
+		ccp.retire(ccp.session)
+	}
+	ccp.session = session
+	ccp.expiresAt = ccp.now().Add(ccp.TTL)
+	return session, nil
+}
+
+// retire closes session once the operations using it had time to finish,
+// ccp.mux must be held
+func (ccp *CachingCloudProvider) retire(session provider.Session) {
+	session = unwrapSession(session)
+	if _, ok := ccp.retired[session]; ok {
+		return
+	}
+	ccp.retired[session] = time.AfterFunc(ccp.CloseDelay, func() {
+		ccp.mux.Lock()
+		delete(ccp.retired, session)
+		// Providers may hand out the same session again
+		current := unwrapSession(ccp.session) == session
+		ccp.mux.Unlock()
+		if !current {
+			session.Close()
+		}
+	})
+}
+
+// IsAuthError reports whether err shows that the backend rejected the token
+// of a session
+func IsAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if status.Code(err) == codes.Unauthenticated {
+		return true
+	}
+	return messages.BackendErrorCode(err) == codes.Unauthenticated
+}
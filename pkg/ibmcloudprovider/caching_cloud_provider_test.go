@@ -0,0 +1,297 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestCachingProvider(t *testing.T, newSessions bool) (*CachingCloudProvider, *FakeIBMCloudStorageProvider, *time.Time) {
+	logger, teardown := GetTestLogger(t)
+	t.Cleanup(teardown)
+	builder := NewFakeProviderBuilder()
+	if newSessions {
+		builder.WithNewSessionPerCall()
+	}
+	fakeProvider := builder.Build()
+
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	ccp := NewCachingCloudProvider(logger, fakeProvider)
+	ccp.TTL = time.Hour
+	ccp.RefreshBefore = 10 * time.Minute
+	ccp.CloseDelay = time.Hour
+	ccp.now = func() time.Time { return now }
+	return ccp, fakeProvider, &now
+}
+
+func TestCachingProviderReusesSession(t *testing.T) {
+	ccp, fakeProvider, now := newTestCachingProvider(t, true)
+	defer ccp.Close()
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	*now = now.Add(30 * time.Minute)
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, fakeProvider.GetProviderSessionCallCount())
+
+	// The wrapped provider is still reachable
+	assert.Equal(t, "fake-clusterID", ccp.GetClusterID())
+	assert.NotNil(t, ccp.GetConfig())
+}
+
+func TestCachingProviderExpiry(t *testing.T) {
+	ccp, fakeProvider, now := newTestCachingProvider(t, true)
+	defer ccp.Close()
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	*now = now.Add(time.Hour)
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 2, fakeProvider.GetProviderSessionCallCount())
+	// The expired session is closed after CloseDelay only
+	assert.Equal(t, 0, unwrapSession(first).(*fake.FakeSession).CloseCallCount())
+}
+
+func TestCachingProviderRefresh(t *testing.T) {
+	ccp, fakeProvider, now := newTestCachingProvider(t, true)
+	defer ccp.Close()
+	ccp.CloseDelay = time.Millisecond
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+
+	// Within RefreshBefore of expiry the current session is returned and a
+	// new one fetched in the background
+	*now = now.Add(55 * time.Minute)
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+	ccp.wg.Wait()
+	assert.Equal(t, 2, fakeProvider.GetProviderSessionCallCount())
+
+	third, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, third)
+	assert.Eventually(t, func() bool {
+		return unwrapSession(first).(*fake.FakeSession).CloseCallCount() == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, 0, unwrapSession(third).(*fake.FakeSession).CloseCallCount())
+
+	// The refreshed session lasts a full TTL
+	*now = now.Add(45 * time.Minute)
+	_, err = ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	ccp.wg.Wait()
+	assert.Equal(t, 2, fakeProvider.GetProviderSessionCallCount())
+}
+
+func TestCachingProviderRefreshFailure(t *testing.T) {
+	ccp, fakeProvider, now := newTestCachingProvider(t, true)
+	defer ccp.Close()
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	fakeProvider.SetDefaultResponse(FakeSessionResponse{Err: errors.New("iam unavailable")})
+
+	*now = now.Add(55 * time.Minute)
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	ccp.wg.Wait()
+	assert.Same(t, first, second)
+
+	// Once expired the error surfaces
+	*now = now.Add(5 * time.Minute)
+	_, err = ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.EqualError(t, err, "iam unavailable")
+}
+
+func TestCachingProviderInvalidateSession(t *testing.T) {
+	testCases := []struct {
+		testCaseName      string
+		err               error
+		expectInvalidated bool
+	}{
+		{
+			testCaseName:      "backend 401",
+			err:               errors.New("{Trace Code:abc, Code:Unauthorized, Description:Token expired, RC:401 Unauthorized}"),
+			expectInvalidated: true,
+		},
+		{
+			testCaseName:      "gRPC Unauthenticated",
+			err:               status.Error(codes.Unauthenticated, "token expired"),
+			expectInvalidated: true,
+		},
+		{
+			testCaseName:      "CSI message",
+			err:               messages.Message{Code: messages.InternalError, Type: codes.Unauthenticated},
+			expectInvalidated: true,
+		},
+		{
+			testCaseName:      "backend 404",
+			err:               errors.New("{Trace Code:abc, Code:volume_not_found, Description:Not found, RC:404 Not Found}"),
+			expectInvalidated: false,
+		},
+		{
+			testCaseName:      "no error",
+			err:               nil,
+			expectInvalidated: false,
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			ccp, fakeProvider, _ := newTestCachingProvider(t, true)
+			defer ccp.Close()
+
+			first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.expectInvalidated, ccp.InvalidateSession(first, testcase.err))
+
+			second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+			assert.Nil(t, err)
+			if testcase.expectInvalidated {
+				assert.NotSame(t, first, second)
+				assert.Equal(t, 2, fakeProvider.GetProviderSessionCallCount())
+			} else {
+				assert.Same(t, first, second)
+				assert.Equal(t, 1, fakeProvider.GetProviderSessionCallCount())
+			}
+		})
+	}
+}
+
+func TestCachingProviderSessionRejectedByBackend(t *testing.T) {
+	ccp, fakeProvider, _ := newTestCachingProvider(t, true)
+	defer ccp.Close()
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	unwrapSession(first).(*fake.FakeSession).GetVolumeReturns(nil, errors.New("{Trace Code:abc, Code:volume_not_found, Description:Not found, RC:404 Not Found}"))
+	_, err = first.GetVolume("vol-1")
+	assert.NotNil(t, err)
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+
+	// The session drops itself once the backend rejects its token
+	unwrapSession(first).(*fake.FakeSession).UpdateVolumeReturns(status.Error(codes.Unauthenticated, "token expired"))
+	err = first.UpdateVolume(provider.Volume{VolumeID: "vol-1"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	third, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, unwrapSession(first), unwrapSession(third))
+	assert.Equal(t, 2, fakeProvider.GetProviderSessionCallCount())
+}
+
+func TestCachingProviderSessionCloseIsIgnored(t *testing.T) {
+	ccp, fakeProvider, _ := newTestCachingProvider(t, true)
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	inner := unwrapSession(first).(*fake.FakeSession)
+	inner.GetVolumeReturns(&provider.Volume{VolumeID: "vol-1"}, nil)
+
+	// Callers used to close their session must not close the shared one
+	first.Close()
+	assert.Equal(t, 0, inner.CloseCallCount())
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+	volume, err := second.GetVolume("vol-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "vol-1", volume.VolumeID)
+	assert.Equal(t, 1, fakeProvider.GetProviderSessionCallCount())
+
+	ccp.Close()
+	assert.Equal(t, 1, inner.CloseCallCount())
+}
+
+func TestCachingProviderUsesProviderLogger(t *testing.T) {
+	ccp, fakeProvider, _ := newTestCachingProvider(t, true)
+	defer ccp.Close()
+
+	// The session outlives the request, so it must not log with its logger
+	requestLogger := zap.NewNop()
+	_, err := ccp.GetProviderSession(context.Background(), requestLogger)
+	assert.Nil(t, err)
+	calls := fakeProvider.Calls("GetProviderSession")
+	assert.Equal(t, 1, len(calls))
+	assert.Same(t, ccp.logger, calls[0].Args[1])
+}
+
+func TestCachingProviderInvalidateStaleSession(t *testing.T) {
+	ccp, _, _ := newTestCachingProvider(t, true)
+	defer ccp.Close()
+
+	// A session which is no longer cached is left alone
+	stale := &fake.FakeSession{}
+	assert.False(t, ccp.InvalidateSession(stale, status.Error(codes.Unauthenticated, "token expired")))
+}
+
+func TestCachingProviderSameSession(t *testing.T) {
+	// FakeIBMCloudStorageProvider hands out the same session every time
+	ccp, _, _ := newTestCachingProvider(t, false)
+	ccp.CloseDelay = time.Millisecond
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.True(t, ccp.InvalidateSession(first, status.Error(codes.Unauthenticated, "token expired")))
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, unwrapSession(first), unwrapSession(second))
+
+	// The session is in use again, so it must not be closed as retired
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, unwrapSession(second).(*fake.FakeSession).CloseCallCount())
+
+	ccp.Close()
+	assert.Equal(t, 1, unwrapSession(second).(*fake.FakeSession).CloseCallCount())
+}
+
+func TestCachingProviderClose(t *testing.T) {
+	ccp, _, now := newTestCachingProvider(t, true)
+
+	first, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	*now = now.Add(time.Hour)
+	second, err := ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+
+	ccp.Close()
+	ccp.Close()
+	assert.Equal(t, 1, unwrapSession(first).(*fake.FakeSession).CloseCallCount())
+	assert.Equal(t, 1, unwrapSession(second).(*fake.FakeSession).CloseCallCount())
+
+	_, err = ccp.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Equal(t, ErrProviderClosed, err)
+}
@@ -0,0 +1,427 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/configvalidation"
+	"github.com/IBM/ibm-csi-common/pkg/metrics"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// DefaultConfigPollInterval is how often a ConfigWatcher checks its
+	// source for changes
+	DefaultConfigPollInterval = 30 * time.Second
+
+	// ConfigReloadedReason is the reason of the event of a reload
+	ConfigReloadedReason = "ProviderConfigReloaded"
+
+	// ConfigReloadFailedReason is the reason of the event of a failed reload
+	ConfigReloadFailedReason = "ProviderConfigReloadFailed"
+)
+
+// ConfigSource is where a ConfigWatcher reads the slclient.toml from
+type ConfigSource interface {
+	// Name identifies the source in logs, metrics and events
+	Name() string
+	Read(ctx context.Context) ([]byte, error)
+}
+
+// WatchedConfigSource is implemented by config sources whose changes can be
+// watched on the filesystem. A ConfigWatcher then reloads as soon as one of
+// the paths changes, and keeps polling as a fallback.
+type WatchedConfigSource interface {
+	ConfigSource
+	WatchPaths() []string
+}
+
+// ConfigUpdater is implemented by cloud providers which can switch to a
+// reloaded config, the sessions they open afterwards use it. UpdateConfig
+// reports whether a provider took the config, wrappers return false if the
+// provider they wrap cannot switch configs.
+type ConfigUpdater interface {
+	UpdateConfig(conf *config.Config) bool
+}
+
+// FileConfigSource reads the config from a file, e.g. the mounted secret
+type FileConfigSource struct {
+	Path string
+}
+
+var _ WatchedConfigSource = &FileConfigSource{}
+
+// NewFileConfigSource ...
+func NewFileConfigSource(path string) *FileConfigSource {
+	return &FileConfigSource{Path: path}
+}
+
+// Name ...
+func (s *FileConfigSource) Name() string {
+	return "file:" + s.Path
+}
+
+// Read ...
+func (s *FileConfigSource) Read(ctx context.Context) ([]byte, error) {
+	return os.ReadFile(s.Path)
+}
+
+// WatchPaths returns the file and its directory. Kubernetes updates a
+// mounted secret by swapping the ..data symlink of the directory, which
+// only shows up as events of the directory.
+func (s *FileConfigSource) WatchPaths() []string {
+	return []string{s.Path, filepath.Dir(s.Path)}
+}
+
+// SecretConfigSource reads the config from a key of a Kubernetes secret, e.g.
+// slclient.toml of the storage-secret-store secret
+type SecretConfigSource struct {
+	Client     kubernetes.Interface
+	Namespace  string
+	SecretName string
+	Key        string
+}
+
+// NewSecretConfigSource ...
+func NewSecretConfigSource(client kubernetes.Interface, namespace string, secretName string, key string) *SecretConfigSource {
+	return &SecretConfigSource{Client: client, Namespace: namespace, SecretName: secretName, Key: key}
+}
+
+// Name ...
+func (s *SecretConfigSource) Name() string {
+	return "secret:" + s.Namespace + "/" + s.SecretName + "/" + s.Key
+}
+
+// Read ...
+func (s *SecretConfigSource) Read(ctx context.Context) ([]byte, error) {
+	secret, err := s.Client.CoreV1().Secrets(s.Namespace).Get(ctx, s.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[s.Key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret %s/%s", s.Key, s.Namespace, s.SecretName)
+	}
+	// Like secret-utils-lib, ignore the newline editors append
+	return []byte(strings.TrimSuffix(string(data), "\n")), nil
+}
+
+// ValidateConfig is the default validation of a ConfigWatcher, see
+// configvalidation.Validate
+func ValidateConfig(conf *config.Config) error {
+	return configvalidation.Validate(conf).Err()
+}
+
+// ConfigWatcher keeps the provider config in sync with its source. The
+// source is polled every Interval and reloaded when its checksum changes,
+// sources on the filesystem are reloaded as soon as they change as well. A
+// new config is validated before it replaces the current one, so a broken
+// edit of the secret leaves the driver running with the last good config.
+//
+// After a reload the OnReload handlers run, typically to hand the new config
+// to the providers and flush the cached sessions which still use the old
+// credentials, see WatchedCloudProvider and FlushSessionsOnReload.
+// Every reload attempt is recorded in Metrics and, if Recorder is set, as an
+// event of EventObject.
+type ConfigWatcher struct {
+	// Interval between two checks of the source
+	Interval time.Duration
+	// Validate rejects invalid configs, ValidateConfig by default
+	Validate func(conf *config.Config) error
+	// Metrics records the reloads, metrics.Default() if nil
+	Metrics *metrics.Metrics
+	// Recorder and EventObject report reloads as events, e.g. of the
+	// driver pod
+	Recorder    record.EventRecorder
+	EventObject runtime.Object
+
+	logger *zap.Logger
+	source ConfigSource
+	conf   atomic.Pointer[config.Config]
+
+	// mux serializes reloads and guards the fields below
+	mux      sync.Mutex
+	checksum [sha256.Size]byte
+	// rejected is the checksum of the last invalid config, which is not
+	// reported again until the source changes
+	rejected [sha256.Size]byte
+	// unreadable is the error of the last failed read, which is not reported
+	// again until the source can be read
+	unreadable string
+	handlers   []func(conf *config.Config)
+}
+
+// NewConfigWatcher returns a watcher of source. Call Load before using
+// GetConfig and Run to watch for changes.
+func NewConfigWatcher(logger *zap.Logger, source ConfigSource) *ConfigWatcher {
+	return &ConfigWatcher{
+		Interval: DefaultConfigPollInterval,
+		Validate: ValidateConfig,
+		logger:   logger,
+		source:   source,
+	}
+}
+
+// GetConfig returns the current config, nil before the first successful
+// load. Callers must not modify it.
+func (cw *ConfigWatcher) GetConfig() *config.Config {
+	return cw.conf.Load()
+}
+
+// OnReload registers handler to run with the new config after each reload.
+// Handlers run outside of the lock of the watcher, so they may use it.
+func (cw *ConfigWatcher) OnReload(handler func(conf *config.Config)) {
+	cw.mux.Lock()
+	defer cw.mux.Unlock()
+	cw.handlers = append(cw.handlers, handler)
+}
+
+// FlushSessionsOnReload flushes the sessions of flusher after each reload
+func (cw *ConfigWatcher) FlushSessionsOnReload(flusher SessionFlusher) {
+	cw.OnReload(func(conf *config.Config) {
+		flusher.FlushSessions()
+	})
+}
+
+// Load reads the config for the first time, it fails if the source cannot
+// be read or the config is invalid
+func (cw *ConfigWatcher) Load(ctx context.Context) error {
+	_, err := cw.Reload(ctx)
+	return err
+}
+
+// Reload reads the source and replaces the config if it changed. It reports
+// whether the config was replaced. On error the current config is kept, and
+// the same invalid config or read error is not reported again.
+func (cw *ConfigWatcher) Reload(ctx context.Context) (bool, error) {
+	conf, handlers, err := cw.reload(ctx)
+	if conf == nil {
+		return false, err
+	}
+	for _, handler := range handlers {
+		handler(conf)
+	}
+	return true, nil
+}
+
+// reload replaces the config if the source changed and returns the new
+// config with the handlers to run
+func (cw *ConfigWatcher) reload(ctx context.Context) (*config.Config, []func(conf *config.Config), error) {
+	cw.mux.Lock()
+	defer cw.mux.Unlock()
+
+	data, err := cw.source.Read(ctx)
+	if err != nil {
+		if cw.conf.Load() != nil && err.Error() == cw.unreadable {
+			return nil, nil, nil
+		}
+		cw.unreadable = err.Error()
+		err = fmt.Errorf("failed to read config: %w", err)
+		cw.reloaded(err)
+		return nil, nil, err
+	}
+	cw.unreadable = ""
+	checksum := sha256.Sum256(data)
+	if cw.conf.Load() != nil && (checksum == cw.checksum || checksum == cw.rejected) {
+		return nil, nil, nil
+	}
+
+	conf, err := config.ParseConfig(cw.logger, string(data))
+	if err == nil && cw.Validate != nil {
+		err = cw.Validate(conf)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid config: %w", err)
+		cw.rejected = checksum
+		cw.reloaded(err)
+		return nil, nil, err
+	}
+
+	cw.checksum = checksum
+	cw.conf.Store(conf)
+	cw.reloaded(nil)
+	return conf, append([]func(conf *config.Config){}, cw.handlers...), nil
+}
+
+// Run checks the source every Interval, and whenever a watched source
+// changes, until ctx is done
+func (cw *ConfigWatcher) Run(ctx context.Context) {
+	interval := cw.Interval
+	if interval <= 0 {
+		interval = DefaultConfigPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	changed := cw.watch(ctx)
+	for {
+		// Errors are logged and reported by Reload
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = cw.Reload(ctx)
+		case <-changed:
+			_, _ = cw.Reload(ctx)
+		}
+	}
+}
+
+// watch returns a channel which receives when a watched source changes,
+// until ctx is done. It returns nil if the source cannot be watched, polling
+// then has to find the changes.
+func (cw *ConfigWatcher) watch(ctx context.Context) <-chan struct{} {
+	source, ok := cw.source.(WatchedConfigSource)
+	if !ok {
+		return nil
+	}
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		cw.logger.Warn("Failed to watch provider config, polling for changes", zap.String("source", cw.source.Name()), zap.Error(err))
+		return nil
+	}
+	paths := source.WatchPaths()
+	for _, path := range paths {
+		if err := fsWatcher.Add(path); err != nil {
+			cw.logger.Warn("Failed to watch provider config path", zap.String("path", path), zap.Error(err))
+		}
+	}
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer fsWatcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-fsWatcher.Events:
+				if !ok {
+					return
+				}
+				// A replaced file loses its watch, watch the new one
+				for _, path := range paths {
+					_ = fsWatcher.Add(path)
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case err, ok := <-fsWatcher.Errors:
+				if !ok {
+					return
+				}
+				cw.logger.Warn("Error watching provider config", zap.String("source", cw.source.Name()), zap.Error(err))
+			}
+		}
+	}()
+	return changed
+}
+
+// reloaded logs and reports the result of a reload, cw.mux must be held
+func (cw *ConfigWatcher) reloaded(err error) {
+	m := cw.Metrics
+	if m == nil {
+		m = metrics.Default()
+	}
+	m.RegisterConfigReload(cw.source.Name(), err)
+
+	if err != nil {
+		cw.logger.Error("Failed to reload provider config, keeping the current one", zap.String("source", cw.source.Name()), zap.Error(err))
+	} else {
+		cw.logger.Info("Reloaded provider config", zap.String("source", cw.source.Name()))
+	}
+	if cw.Recorder == nil || cw.EventObject == nil {
+		return
+	}
+	if err != nil {
+		cw.Recorder.Eventf(cw.EventObject, v1.EventTypeWarning, ConfigReloadFailedReason, "Failed to reload provider config from %s: %v", cw.source.Name(), err)
+		return
+	}
+	cw.Recorder.Eventf(cw.EventObject, v1.EventTypeNormal, ConfigReloadedReason, "Reloaded provider config from %s", cw.source.Name())
+}
+
+// WatchedCloudProvider is a cloud provider which follows the config of a
+// ConfigWatcher. GetConfig returns the current config, and after a reload
+// the wrapped provider gets the new config and its cached sessions are
+// flushed. A provider which is no ConfigUpdater keeps opening sessions with
+// its old credentials, which is logged as error on every reload.
+type WatchedCloudProvider struct {
+	CloudProviderInterface
+
+	watcher *ConfigWatcher
+}
+
+var _ CloudProviderInterface = &WatchedCloudProvider{}
+var _ SessionInvalidator = &WatchedCloudProvider{}
+var _ SessionFlusher = &WatchedCloudProvider{}
+
+// NewWatchedCloudProvider wraps cloudProvider so that it follows the config
+// of watcher
+func NewWatchedCloudProvider(cloudProvider CloudProviderInterface, watcher *ConfigWatcher) *WatchedCloudProvider {
+	wcp := &WatchedCloudProvider{CloudProviderInterface: cloudProvider, watcher: watcher}
+	if _, ok := cloudProvider.(ConfigUpdater); !ok {
+		watcher.logger.Error("Cloud provider cannot apply reloaded configs, it keeps using its startup credentials")
+	}
+	watcher.OnReload(func(conf *config.Config) {
+		if updater, ok := cloudProvider.(ConfigUpdater); !ok || !updater.UpdateConfig(conf) {
+			watcher.logger.Error("Cloud provider cannot apply the reloaded config, new sessions use the old credentials", zap.String("source", watcher.source.Name()))
+		}
+		wcp.FlushSessions()
+	})
+	return wcp
+}
+
+// GetConfig returns the current config of the watcher, the config of the
+// wrapped provider before the first load
+func (wcp *WatchedCloudProvider) GetConfig() *config.Config {
+	if conf := wcp.watcher.GetConfig(); conf != nil {
+		return conf
+	}
+	return wcp.CloudProviderInterface.GetConfig()
+}
+
+// InvalidateSession ...
+func (wcp *WatchedCloudProvider) InvalidateSession(session provider.Session, err error) bool {
+	if invalidator, ok := wcp.CloudProviderInterface.(SessionInvalidator); ok {
+		return invalidator.InvalidateSession(session, err)
+	}
+	return false
+}
+
+// FlushSessions ...
+func (wcp *WatchedCloudProvider) FlushSessions() {
+	if flusher, ok := wcp.CloudProviderInterface.(SessionFlusher); ok {
+		flusher.FlushSessions()
+	}
+}
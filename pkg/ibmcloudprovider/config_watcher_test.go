@@ -0,0 +1,404 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/metrics"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/net/context"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func testConfigData(apiKey string) string {
+	return `[server]
+  debug_trace = false
+[vpc]
+  vpc_enabled = true
+  g2_token_exchange_endpoint_url = "https://iam.cloud.ibm.com"
+  g2_riaas_endpoint_url = "https://us-south.iaas.cloud.ibm.com"
+  g2_api_key = "` + apiKey + `"
+  provider_type = "g2"
+`
+}
+
+// configReloads returns the reload counter of result in registry
+func configReloads(t *testing.T, registry *prometheus.Registry, result string) float64 {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	total := float64(0)
+	for _, family := range families {
+		if family.GetName() != "driver_config_reloads_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "result" && label.GetValue() == result {
+					total += metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return total
+}
+
+func TestConfigWatcherFile(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+
+	registry := prometheus.NewRegistry()
+	recorder := record.NewFakeRecorder(10)
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", registry)
+	watcher.Recorder = recorder
+	watcher.EventObject = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ibm-vpc-block-csi-controller-0"}}
+	reloads := 0
+	watcher.OnReload(func(conf *config.Config) { reloads++ })
+
+	assert.Nil(t, watcher.GetConfig())
+	assert.Nil(t, watcher.Load(context.Background()))
+	assert.Equal(t, "old-key", watcher.GetConfig().VPC.G2APIKey)
+	assert.Equal(t, 1, reloads)
+	assert.Contains(t, <-recorder.Events, ConfigReloadedReason)
+
+	// An unchanged file is not reloaded
+	reloaded, err := watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, reloaded)
+	assert.Equal(t, 1, reloads)
+
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("new-key")), 0600))
+	reloaded, err = watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new-key", watcher.GetConfig().VPC.G2APIKey)
+	assert.Equal(t, 2, reloads)
+	assert.Equal(t, float64(2), configReloads(t, registry, "success"))
+	assert.Contains(t, <-recorder.Events, ConfigReloadedReason)
+}
+
+func TestConfigWatcherInvalidConfig(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+
+	registry := prometheus.NewRegistry()
+	recorder := record.NewFakeRecorder(10)
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", registry)
+	watcher.Recorder = recorder
+	watcher.EventObject = &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ibm-vpc-block-csi-controller-0"}}
+	assert.Nil(t, watcher.Load(context.Background()))
+	<-recorder.Events
+	current := watcher.GetConfig()
+
+	testCases := []struct {
+		testCaseName string
+		data         string
+	}{
+		{testCaseName: "Broken toml", data: "[vpc\n  g2_api_key = \"new-key\""},
+		{testCaseName: "No provider enabled", data: "[server]\n  debug_trace = true\n"},
+		{testCaseName: "No API key", data: testConfigData("")},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			assert.Nil(t, os.WriteFile(path, []byte(testcase.data), 0600))
+			reloaded, err := watcher.Reload(context.Background())
+			assert.NotNil(t, err)
+			assert.False(t, reloaded)
+			assert.Same(t, current, watcher.GetConfig())
+			assert.Contains(t, <-recorder.Events, ConfigReloadFailedReason)
+
+			// The same invalid config is reported once
+			_, err = watcher.Reload(context.Background())
+			assert.Nil(t, err)
+			assert.Empty(t, recorder.Events)
+		})
+	}
+	assert.Equal(t, float64(3), configReloads(t, registry, "failure"))
+
+	// A missing file keeps the config too, and is reported once
+	assert.Nil(t, os.Remove(path))
+	_, err := watcher.Reload(context.Background())
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Same(t, current, watcher.GetConfig())
+	assert.Contains(t, <-recorder.Events, ConfigReloadFailedReason)
+	_, err = watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.Empty(t, recorder.Events)
+	assert.Equal(t, float64(4), configReloads(t, registry, "failure"))
+
+	// The first load fails on an invalid config
+	watcher = NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", registry)
+	assert.NotNil(t, watcher.Load(context.Background()))
+	assert.Nil(t, watcher.GetConfig())
+}
+
+func TestConfigWatcherSoftlayerOnly(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	assert.Nil(t, watcher.Load(context.Background()))
+
+	// A classic config without VPC section is valid
+	softlayerOnly := "[softlayer]\n  softlayer_file_enabled = true\n  softlayer_file_provider_name = \"SOFTLAYER-FILE\"\n"
+	assert.Nil(t, os.WriteFile(path, []byte(softlayerOnly), 0600))
+	reloaded, err := watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.True(t, watcher.GetConfig().Softlayer.SoftlayerFileEnabled)
+}
+
+func TestConfigWatcherSecret(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "storage-secret-store", Namespace: "kube-system"},
+		Data:       map[string][]byte{"slclient.toml": []byte(testConfigData("old-key") + "\n")},
+	}
+	client := fake.NewSimpleClientset(secret)
+	source := NewSecretConfigSource(client, "kube-system", "storage-secret-store", "slclient.toml")
+	assert.Equal(t, "secret:kube-system/storage-secret-store/slclient.toml", source.Name())
+
+	watcher := NewConfigWatcher(logger, source)
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	assert.Nil(t, watcher.Load(context.Background()))
+	assert.Equal(t, "old-key", watcher.GetConfig().VPC.G2APIKey)
+
+	secret.Data["slclient.toml"] = []byte(testConfigData("new-key"))
+	_, err := client.CoreV1().Secrets("kube-system").Update(context.Background(), secret, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	reloaded, err := watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new-key", watcher.GetConfig().VPC.G2APIKey)
+
+	_, err = NewSecretConfigSource(client, "kube-system", "storage-secret-store", "missing.toml").Read(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestConfigWatcherFlushesSessions(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+
+	cloudProvider := NewCachingCloudProvider(logger, NewFakeProviderBuilder().WithNewSessionPerCall().Build())
+	defer cloudProvider.Close()
+	registry := NewProviderRegistry()
+	assert.Nil(t, registry.Register("vpc", cloudProvider))
+
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	watcher.Interval = 10 * time.Millisecond
+	assert.Nil(t, watcher.Load(context.Background()))
+	watcher.FlushSessionsOnReload(registry)
+
+	session, err := registry.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	again, err := registry.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.Same(t, session, again)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx)
+		close(done)
+	}()
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("new-key")), 0600))
+	assert.Eventually(t, func() bool {
+		conf := watcher.GetConfig()
+		return conf.VPC.G2APIKey == "new-key"
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	again, err = registry.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.NotSame(t, session, again)
+}
+
+// writeSecretVolume writes data like the kubelet updates a mounted secret:
+// into a new timestamped directory, which the ..data symlink is swapped to
+func writeSecretVolume(t *testing.T, dir string, version string, data string) {
+	dataDir := filepath.Join(dir, "..data_"+version)
+	assert.Nil(t, os.Mkdir(dataDir, 0700))
+	assert.Nil(t, os.WriteFile(filepath.Join(dataDir, "slclient.toml"), []byte(data), 0600))
+	assert.Nil(t, os.Symlink("..data_"+version, filepath.Join(dir, "..data_tmp")))
+	assert.Nil(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+}
+
+func TestConfigWatcherWatchesSecretVolume(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	dir := t.TempDir()
+	writeSecretVolume(t, dir, "1", testConfigData("old-key"))
+	path := filepath.Join(dir, "slclient.toml")
+	assert.Nil(t, os.Symlink(filepath.Join("..data", "slclient.toml"), path))
+
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	// Only the filesystem events can find the changes
+	watcher.Interval = time.Hour
+	assert.Nil(t, watcher.Load(context.Background()))
+	assert.Equal(t, "old-key", watcher.GetConfig().VPC.G2APIKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Give Run time to set up the watch before the first swap
+	time.Sleep(100 * time.Millisecond)
+	writeSecretVolume(t, dir, "2", testConfigData("new-key"))
+	assert.Eventually(t, func() bool {
+		return watcher.GetConfig().VPC.G2APIKey == "new-key"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The watch survives the swap
+	writeSecretVolume(t, dir, "3", testConfigData("newer-key"))
+	assert.Eventually(t, func() bool {
+		return watcher.GetConfig().VPC.G2APIKey == "newer-key"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConfigWatcherHandlersRunUnlocked(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	assert.Nil(t, watcher.Load(context.Background()))
+
+	// A handler may use the watcher, e.g. to register another handler
+	registered := false
+	watcher.OnReload(func(conf *config.Config) {
+		if !registered {
+			registered = true
+			watcher.OnReload(func(conf *config.Config) {})
+		}
+		reloaded, err := watcher.Reload(context.Background())
+		assert.Nil(t, err)
+		assert.False(t, reloaded)
+	})
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("new-key")), 0600))
+	reloaded, err := watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.True(t, registered)
+}
+
+func TestWatchedCloudProvider(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	fakeProvider := NewFakeProviderBuilder().WithNewSessionPerCall().Build()
+	cachingProvider := NewCachingCloudProvider(logger, fakeProvider)
+	defer cachingProvider.Close()
+	cloudProvider := NewWatchedCloudProvider(cachingProvider, watcher)
+
+	// Before the first load the config of the provider is used
+	assert.Equal(t, "VPCFakeProvider", cloudProvider.GetConfig().VPC.VPCBlockProviderName)
+	assert.Nil(t, watcher.Load(context.Background()))
+	assert.Equal(t, "old-key", cloudProvider.GetConfig().VPC.G2APIKey)
+
+	session, err := cloudProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("new-key")), 0600))
+	reloaded, err := watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "new-key", cloudProvider.GetConfig().VPC.G2APIKey)
+	// The wrapped provider opens its next session with the new config
+	assert.Equal(t, "new-key", fakeProvider.GetConfig().VPC.G2APIKey)
+	again, err := cloudProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.NotSame(t, session, again)
+}
+
+func TestWatchedCloudProviderWithoutConfigUpdater(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slclient.toml")
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("old-key")), 0600))
+	core, logs := observer.New(zapcore.ErrorLevel)
+	logger := zap.New(core)
+
+	watcher := NewConfigWatcher(logger, NewFileConfigSource(path))
+	watcher.Metrics = metrics.MustNew("driver", prometheus.NewRegistry())
+	assert.Nil(t, watcher.Load(context.Background()))
+	// The caching provider forwards the config, but the provider it wraps
+	// cannot take it
+	fakeProvider := NewFakeProviderBuilder().WithNewSessionPerCall().Build()
+	cachingProvider := NewCachingCloudProvider(logger, struct{ CloudProviderInterface }{fakeProvider})
+	defer cachingProvider.Close()
+	cloudProvider := NewWatchedCloudProvider(cachingProvider, watcher)
+	assert.Equal(t, 0, logs.Len())
+
+	session, err := cloudProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(path, []byte(testConfigData("new-key")), 0600))
+	reloaded, err := watcher.Reload(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, 1, logs.FilterMessage("Cloud provider cannot apply the reloaded config, new sessions use the old credentials").Len())
+	assert.Equal(t, "VPCFakeProvider", fakeProvider.GetConfig().VPC.VPCBlockProviderName)
+
+	// Sessions are flushed all the same
+	again, err := cloudProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.NotSame(t, session, again)
+
+	// A provider which is no ConfigUpdater at all is reported right away
+	NewWatchedCloudProvider(struct{ CloudProviderInterface }{fakeProvider}, watcher)
+	assert.Equal(t, 1, logs.FilterMessage("Cloud provider cannot apply reloaded configs, it keeps using its startup credentials").Len())
+}
@@ -19,8 +19,11 @@ package ibmcloudprovider
 
 import (
 	"bytes"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/IBM/ibm-csi-common/pkg/utils"
 	"github.com/IBM/ibmcloud-volume-interface/config"
 	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
 	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
@@ -93,34 +96,268 @@ func GetTestLogger(t *testing.T) (logger *zap.Logger, teardown func()) {
 	return
 }
 
+// FakeSessionResponse is a scripted result of GetProviderSession
+type FakeSessionResponse struct {
+	// Session is returned instead of the default session if set
+	Session provider.Session
+	// NilSession makes the call return no session
+	NilSession bool
+	// Err is returned as error
+	Err error
+	// Delay is waited before returning, unless the context is done first
+	Delay time.Duration
+}
+
+// FakeProviderCall is a recorded call of FakeIBMCloudStorageProvider
+type FakeProviderCall struct {
+	Method    string
+	RequestID string
+	Args      []interface{}
+	Session   provider.Session
+	Err       error
+}
+
 // FakeIBMCloudStorageProvider Provider
 type FakeIBMCloudStorageProvider struct {
 	ProviderName   string
 	ProviderConfig *config.Config
 	ClusterID      string
 	fakeSession    *fake.FakeSession
+
+	mux sync.Mutex
+	// responses are the scripted responses by call index, starting from 0
+	responses map[int]FakeSessionResponse
+	// defaultResponse is used for the calls without a scripted response
+	defaultResponse FakeSessionResponse
+	// newSessionPerCall makes every successful call return a new session
+	newSessionPerCall bool
+	sessionCalls      int
+	calls             []FakeProviderCall
 }
 
 var _ CloudProviderInterface = &FakeIBMCloudStorageProvider{}
+var _ ConfigUpdater = &FakeIBMCloudStorageProvider{}
 
 // NewFakeIBMCloudStorageProvider ...
 func NewFakeIBMCloudStorageProvider(configPath string, logger *zap.Logger) (*FakeIBMCloudStorageProvider, error) {
-	return &FakeIBMCloudStorageProvider{ProviderName: "FakeIBMCloudStorageProvider",
-		ProviderConfig: &config.Config{VPC: &config.VPCProviderConfig{VPCBlockProviderName: "VPCFakeProvider"}},
-		ClusterID:      "fake-clusterID", fakeSession: &fake.FakeSession{}}, nil
+	return NewFakeProviderBuilder().Build(), nil
 }
 
-// GetProviderSession ...
+// GetProviderSession returns the scripted response of the call, the default
+// session otherwise
 func (ficp *FakeIBMCloudStorageProvider) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
-	return ficp.fakeSession, nil
+	ficp.mux.Lock()
+	response, ok := ficp.responses[ficp.sessionCalls]
+	if !ok {
+		response = ficp.defaultResponse
+	}
+	ficp.sessionCalls++
+	ficp.mux.Unlock()
+
+	var session provider.Session
+	err := response.Err
+	if response.Delay > 0 {
+		timer := time.NewTimer(response.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = ctx.Err()
+		}
+	}
+
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	if err == nil && !response.NilSession {
+		switch {
+		case response.Session != nil:
+			session = response.Session
+		case ficp.newSessionPerCall:
+			ficp.fakeSession = &fake.FakeSession{}
+			session = ficp.fakeSession
+		default:
+			session = ficp.fakeSession
+		}
+	}
+	ficp.record(FakeProviderCall{
+		Method:    "GetProviderSession",
+		RequestID: utils.RequestIDFromContext(ctx),
+		Args:      []interface{}{ctx, logger},
+		Session:   session,
+		Err:       err,
+	})
+	return session, err
 }
 
 // GetConfig ...
 func (ficp *FakeIBMCloudStorageProvider) GetConfig() *config.Config {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	ficp.record(FakeProviderCall{Method: "GetConfig"})
 	return ficp.ProviderConfig
 }
 
+// UpdateConfig ...
+func (ficp *FakeIBMCloudStorageProvider) UpdateConfig(conf *config.Config) bool {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	ficp.record(FakeProviderCall{Method: "UpdateConfig", Args: []interface{}{conf}})
+	ficp.ProviderConfig = conf
+	return true
+}
+
 // GetClusterID ...
 func (ficp *FakeIBMCloudStorageProvider) GetClusterID() string {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	ficp.record(FakeProviderCall{Method: "GetClusterID"})
 	return ficp.ClusterID
 }
+
+// Session returns the session returned by the last successful call without a
+// scripted session
+func (ficp *FakeIBMCloudStorageProvider) Session() *fake.FakeSession {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	return ficp.fakeSession
+}
+
+// SetDefaultResponse replaces the response of the calls without a scripted
+// response, e.g. to start or end an outage in the middle of a test
+func (ficp *FakeIBMCloudStorageProvider) SetDefaultResponse(response FakeSessionResponse) {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	ficp.defaultResponse = response
+}
+
+// SetResponseOnCall scripts the response of the i-th GetProviderSession call,
+// starting from 0
+func (ficp *FakeIBMCloudStorageProvider) SetResponseOnCall(i int, response FakeSessionResponse) {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	ficp.responses[i] = response
+}
+
+// GetProviderSessionCallCount ...
+func (ficp *FakeIBMCloudStorageProvider) GetProviderSessionCallCount() int {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	return ficp.sessionCalls
+}
+
+// Calls returns the recorded calls, optionally only those of the methods
+func (ficp *FakeIBMCloudStorageProvider) Calls(methods ...string) []FakeProviderCall {
+	ficp.mux.Lock()
+	defer ficp.mux.Unlock()
+	calls := []FakeProviderCall{}
+	for _, call := range ficp.calls {
+		if len(methods) == 0 || contains(methods, call.Method) {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// record ...
+func (ficp *FakeIBMCloudStorageProvider) record(call FakeProviderCall) {
+	ficp.calls = append(ficp.calls, call)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FakeProviderBuilder builds a FakeIBMCloudStorageProvider with scripted
+// GetProviderSession responses
+type FakeProviderBuilder struct {
+	provider *FakeIBMCloudStorageProvider
+}
+
+// NewFakeProviderBuilder returns a builder of a provider which always returns
+// the same session
+func NewFakeProviderBuilder() *FakeProviderBuilder {
+	return &FakeProviderBuilder{provider: &FakeIBMCloudStorageProvider{
+		ProviderName:   "FakeIBMCloudStorageProvider",
+		ProviderConfig: &config.Config{VPC: &config.VPCProviderConfig{VPCBlockProviderName: "VPCFakeProvider"}},
+		ClusterID:      "fake-clusterID",
+		fakeSession:    &fake.FakeSession{},
+		responses:      map[int]FakeSessionResponse{},
+	}}
+}
+
+// WithConfig ...
+func (fpb *FakeProviderBuilder) WithConfig(conf *config.Config) *FakeProviderBuilder {
+	fpb.provider.ProviderConfig = conf
+	return fpb
+}
+
+// WithClusterID ...
+func (fpb *FakeProviderBuilder) WithClusterID(clusterID string) *FakeProviderBuilder {
+	fpb.provider.ClusterID = clusterID
+	return fpb
+}
+
+// WithSession sets the session returned by default
+func (fpb *FakeProviderBuilder) WithSession(session *fake.FakeSession) *FakeProviderBuilder {
+	fpb.provider.fakeSession = session
+	return fpb
+}
+
+// WithNewSessionPerCall makes every call return a new session, like a real
+// provider does
+func (fpb *FakeProviderBuilder) WithNewSessionPerCall() *FakeProviderBuilder {
+	fpb.provider.newSessionPerCall = true
+	return fpb
+}
+
+// WithDelay delays every call without a scripted response
+func (fpb *FakeProviderBuilder) WithDelay(delay time.Duration) *FakeProviderBuilder {
+	fpb.provider.defaultResponse.Delay = delay
+	return fpb
+}
+
+// WithError fails every call without a scripted response
+func (fpb *FakeProviderBuilder) WithError(err error) *FakeProviderBuilder {
+	fpb.provider.defaultResponse.Err = err
+	return fpb
+}
+
+// WithResponseOnCall scripts the response of the i-th call, starting from 0
+func (fpb *FakeProviderBuilder) WithResponseOnCall(i int, response FakeSessionResponse) *FakeProviderBuilder {
+	fpb.provider.responses[i] = response
+	return fpb
+}
+
+// WithErrorOnCall fails the i-th call, starting from 0
+func (fpb *FakeProviderBuilder) WithErrorOnCall(i int, err error) *FakeProviderBuilder {
+	return fpb.WithResponseOnCall(i, FakeSessionResponse{Err: err})
+}
+
+// WithDelayOnCall delays the i-th call, starting from 0
+func (fpb *FakeProviderBuilder) WithDelayOnCall(i int, delay time.Duration) *FakeProviderBuilder {
+	return fpb.WithResponseOnCall(i, FakeSessionResponse{Delay: delay})
+}
+
+// WithNilSessionOnCall makes the i-th call, starting from 0, return neither a
+// session nor an error
+func (fpb *FakeProviderBuilder) WithNilSessionOnCall(i int) *FakeProviderBuilder {
+	return fpb.WithResponseOnCall(i, FakeSessionResponse{NilSession: true})
+}
+
+// WithOutage fails count calls from the i-th call, starting from 0
+func (fpb *FakeProviderBuilder) WithOutage(i int, count int, err error) *FakeProviderBuilder {
+	for call := i; call < i+count; call++ {
+		fpb.WithErrorOnCall(call, err)
+	}
+	return fpb
+}
+
+// Build ...
+func (fpb *FakeProviderBuilder) Build() *FakeIBMCloudStorageProvider {
+	return fpb.provider
+}
@@ -0,0 +1,144 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+func TestFakeProviderDefaults(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+
+	fakeProvider, err := NewFakeIBMCloudStorageProvider("", logger)
+	assert.Nil(t, err)
+	first, err := fakeProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	second, err := fakeProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.Same(t, first, second)
+	assert.Same(t, fakeProvider.Session(), first)
+	assert.Equal(t, "fake-clusterID", fakeProvider.GetClusterID())
+	assert.Equal(t, "VPCFakeProvider", fakeProvider.GetConfig().VPC.VPCBlockProviderName)
+}
+
+func TestFakeProviderScriptedResponses(t *testing.T) {
+	outage := errors.New("backend unavailable")
+	scripted := &fake.FakeSession{}
+	fakeProvider := NewFakeProviderBuilder().
+		WithErrorOnCall(1, outage).
+		WithNilSessionOnCall(2).
+		WithResponseOnCall(3, FakeSessionResponse{Session: scripted}).
+		WithOutage(5, 2, outage).
+		Build()
+
+	testCases := []struct {
+		testCaseName    string
+		expectedSession interface{}
+		expectedErr     error
+	}{
+		{testCaseName: "default session", expectedSession: fakeProvider.Session()},
+		{testCaseName: "error on call", expectedErr: outage},
+		{testCaseName: "nil session", expectedSession: nil},
+		{testCaseName: "scripted session", expectedSession: scripted},
+		{testCaseName: "default session again", expectedSession: fakeProvider.Session()},
+		{testCaseName: "outage start", expectedErr: outage},
+		{testCaseName: "outage end", expectedErr: outage},
+		{testCaseName: "recovered", expectedSession: fakeProvider.Session()},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			session, err := fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+			assert.Equal(t, testcase.expectedErr, err)
+			if testcase.expectedSession == nil {
+				assert.Nil(t, session)
+			} else {
+				assert.Same(t, testcase.expectedSession, session)
+			}
+		})
+	}
+	assert.Equal(t, len(testCases), fakeProvider.GetProviderSessionCallCount())
+}
+
+func TestFakeProviderDelay(t *testing.T) {
+	fakeProvider := NewFakeProviderBuilder().WithDelayOnCall(0, time.Hour).WithDelay(time.Millisecond).Build()
+
+	// A delay ends early with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	session, err := fakeProvider.GetProviderSession(ctx, zap.NewNop())
+	assert.Nil(t, session)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	start := time.Now()
+	session, err = fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotNil(t, session)
+	assert.True(t, time.Since(start) >= time.Millisecond)
+}
+
+func TestFakeProviderSetDefaultResponse(t *testing.T) {
+	fakeProvider := NewFakeProviderBuilder().WithNewSessionPerCall().Build()
+
+	first, err := fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	fakeProvider.SetDefaultResponse(FakeSessionResponse{Err: errors.New("backend unavailable")})
+	_, err = fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.EqualError(t, err, "backend unavailable")
+	fakeProvider.SetDefaultResponse(FakeSessionResponse{})
+	fakeProvider.SetResponseOnCall(3, FakeSessionResponse{NilSession: true})
+
+	second, err := fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, second)
+	third, err := fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Nil(t, third)
+}
+
+func TestFakeProviderCalls(t *testing.T) {
+	outage := errors.New("backend unavailable")
+	fakeProvider := NewFakeProviderBuilder().WithClusterID("cluster-1").WithErrorOnCall(0, outage).Build()
+
+	ctx := utils.ContextWithRequestID(context.Background(), "request-1")
+	_, _ = fakeProvider.GetProviderSession(ctx, zap.NewNop())
+	assert.Equal(t, "cluster-1", fakeProvider.GetClusterID())
+	session, _ := fakeProvider.GetProviderSession(context.Background(), zap.NewNop())
+
+	calls := fakeProvider.Calls()
+	assert.Equal(t, 3, len(calls))
+	assert.Equal(t, "GetClusterID", calls[1].Method)
+
+	sessionCalls := fakeProvider.Calls("GetProviderSession")
+	assert.Equal(t, 2, len(sessionCalls))
+	assert.Equal(t, "request-1", sessionCalls[0].RequestID)
+	assert.Equal(t, ctx, sessionCalls[0].Args[0])
+	assert.Equal(t, outage, sessionCalls[0].Err)
+	assert.Nil(t, sessionCalls[0].Session)
+	assert.Same(t, session, sessionCalls[1].Session)
+	assert.Nil(t, sessionCalls[1].Err)
+}
@@ -0,0 +1,284 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// ProviderParameter is the storage class parameter, and so the volume
+// attribute, naming the provider of a volume
+const ProviderParameter = "provider"
+
+// ErrProviderNotFound is returned when no registered provider matches a
+// ProviderSelector
+var ErrProviderNotFound = errors.New("cloud provider not found")
+
+// ProviderSelector describes a volume, to select its provider. The first
+// field which matches a provider decides, in the order of declaration.
+type ProviderSelector struct {
+	// Name is the name of a registered provider
+	Name string
+	// Parameters are the storage class parameters or volume attributes,
+	// whose ProviderParameter names a provider
+	Parameters map[string]string
+	// Driver is the CSI driver name of the volume
+	Driver string
+	// VolumeID is matched against the volume ID prefixes of the providers
+	VolumeID string
+}
+
+// MultiCloudProviderInterface is a CloudProviderInterface made of several
+// named providers. Its GetProviderSession uses the provider selected by the
+// ProviderSelector of the context, see ContextWithProviderSelector.
+type MultiCloudProviderInterface interface {
+	CloudProviderInterface
+	SelectProvider(selector ProviderSelector) (string, CloudProviderInterface, error)
+}
+
+type providerSelectorKey struct{}
+
+// ContextWithProviderSelector returns a copy of ctx which makes
+// GetProviderSession of a MultiCloudProviderInterface use selector
+func ContextWithProviderSelector(ctx context.Context, selector ProviderSelector) context.Context {
+	return context.WithValue(ctx, providerSelectorKey{}, selector)
+}
+
+// ProviderSelectorFromContext returns the selector set by
+// ContextWithProviderSelector
+func ProviderSelectorFromContext(ctx context.Context) (ProviderSelector, bool) {
+	selector, ok := ctx.Value(providerSelectorKey{}).(ProviderSelector)
+	return selector, ok
+}
+
+// volumeIDPrefix maps the volumes whose ID starts with prefix to a provider
+type volumeIDPrefix struct {
+	prefix string
+	name   string
+}
+
+// ProviderRegistry holds named providers, e.g. VPC block, IKS classic and
+// Satellite, and selects one per volume
+type ProviderRegistry struct {
+	mux       sync.RWMutex
+	providers map[string]CloudProviderInterface
+	// drivers maps CSI driver names to provider names
+	drivers map[string]string
+	// prefixes are sorted by decreasing length, so the longest prefix wins
+	prefixes    []volumeIDPrefix
+	defaultName string
+}
+
+var _ MultiCloudProviderInterface = &ProviderRegistry{}
+var _ SessionInvalidator = &ProviderRegistry{}
+var _ SessionFlusher = &ProviderRegistry{}
+
+// NewProviderRegistry returns an empty registry
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		providers: map[string]CloudProviderInterface{},
+		drivers:   map[string]string{},
+	}
+}
+
+// Register adds a provider under name. The first provider registered is the
+// default one, which is used when a selector matches no other.
+func (pr *ProviderRegistry) Register(name string, cloudProvider CloudProviderInterface) error {
+	if name == "" || cloudProvider == nil {
+		return fmt.Errorf("invalid cloud provider %q", name)
+	}
+	pr.mux.Lock()
+	defer pr.mux.Unlock()
+	if _, ok := pr.providers[name]; ok {
+		return fmt.Errorf("cloud provider %q already registered", name)
+	}
+	pr.providers[name] = cloudProvider
+	if pr.defaultName == "" {
+		pr.defaultName = name
+	}
+	return nil
+}
+
+// SetDefault makes name the default provider, or leaves the registry without
+// default if name is empty
+func (pr *ProviderRegistry) SetDefault(name string) error {
+	pr.mux.Lock()
+	defer pr.mux.Unlock()
+	if err := pr.checkRegistered(name); name != "" && err != nil {
+		return err
+	}
+	pr.defaultName = name
+	return nil
+}
+
+// MapDriver selects the provider name for the volumes of a CSI driver
+func (pr *ProviderRegistry) MapDriver(driver string, name string) error {
+	pr.mux.Lock()
+	defer pr.mux.Unlock()
+	if err := pr.checkRegistered(name); err != nil {
+		return err
+	}
+	pr.drivers[driver] = name
+	return nil
+}
+
+// MapVolumeIDPrefix selects the provider name for the volumes whose ID
+// starts with prefix
+func (pr *ProviderRegistry) MapVolumeIDPrefix(prefix string, name string) error {
+	if prefix == "" {
+		return errors.New("empty volume ID prefix")
+	}
+	pr.mux.Lock()
+	defer pr.mux.Unlock()
+	if err := pr.checkRegistered(name); err != nil {
+		return err
+	}
+	for i := range pr.prefixes {
+		if pr.prefixes[i].prefix == prefix {
+			pr.prefixes[i].name = name
+			return nil
+		}
+	}
+	pr.prefixes = append(pr.prefixes, volumeIDPrefix{prefix: prefix, name: name})
+	sort.SliceStable(pr.prefixes, func(i, j int) bool {
+		return len(pr.prefixes[i].prefix) > len(pr.prefixes[j].prefix)
+	})
+	return nil
+}
+
+// Provider returns the provider registered under name
+func (pr *ProviderRegistry) Provider(name string) (CloudProviderInterface, bool) {
+	pr.mux.RLock()
+	defer pr.mux.RUnlock()
+	cloudProvider, ok := pr.providers[name]
+	return cloudProvider, ok
+}
+
+// Names returns the sorted names of the registered providers
+func (pr *ProviderRegistry) Names() []string {
+	pr.mux.RLock()
+	defer pr.mux.RUnlock()
+	names := make([]string, 0, len(pr.providers))
+	for name := range pr.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectProvider returns the name and the provider selected by selector, by
+// provider name, provider parameter, driver, volume ID prefix and finally the
+// default provider. A name or parameter naming an unknown provider is an
+// error rather than falling back to another provider.
+func (pr *ProviderRegistry) SelectProvider(selector ProviderSelector) (string, CloudProviderInterface, error) {
+	pr.mux.RLock()
+	defer pr.mux.RUnlock()
+
+	name := selector.Name
+	if name == "" {
+		name = selector.Parameters[ProviderParameter]
+	}
+	if name == "" {
+		name = pr.drivers[selector.Driver]
+	}
+	if name == "" && selector.VolumeID != "" {
+		for _, p := range pr.prefixes {
+			if strings.HasPrefix(selector.VolumeID, p.prefix) {
+				name = p.name
+				break
+			}
+		}
+	}
+	if name == "" {
+		name = pr.defaultName
+	}
+	cloudProvider, ok := pr.providers[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %q", ErrProviderNotFound, name)
+	}
+	return name, cloudProvider, nil
+}
+
+// GetProviderSession returns a session of the provider selected by the
+// ProviderSelector of ctx, or of the default provider
+func (pr *ProviderRegistry) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
+	selector, _ := ProviderSelectorFromContext(ctx)
+	name, cloudProvider, err := pr.SelectProvider(selector)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debug("Selected cloud provider", zap.String("provider", name))
+	return cloudProvider.GetProviderSession(ctx, logger)
+}
+
+// GetConfig returns the config of the default provider
+func (pr *ProviderRegistry) GetConfig() *config.Config {
+	if _, cloudProvider, err := pr.SelectProvider(ProviderSelector{}); err == nil {
+		return cloudProvider.GetConfig()
+	}
+	return nil
+}
+
+// GetClusterID returns the cluster ID of the default provider
+func (pr *ProviderRegistry) GetClusterID() string {
+	if _, cloudProvider, err := pr.SelectProvider(ProviderSelector{}); err == nil {
+		return cloudProvider.GetClusterID()
+	}
+	return ""
+}
+
+// InvalidateSession passes err on to the providers which cache sessions,
+// only the one owning session drops it
+func (pr *ProviderRegistry) InvalidateSession(session provider.Session, err error) bool {
+	pr.mux.RLock()
+	defer pr.mux.RUnlock()
+	for _, cloudProvider := range pr.providers {
+		if invalidator, ok := cloudProvider.(SessionInvalidator); ok && invalidator.InvalidateSession(session, err) {
+			return true
+		}
+	}
+	return false
+}
+
+// FlushSessions drops the cached sessions of all providers
+func (pr *ProviderRegistry) FlushSessions() {
+	pr.mux.RLock()
+	defer pr.mux.RUnlock()
+	for _, cloudProvider := range pr.providers {
+		if flusher, ok := cloudProvider.(SessionFlusher); ok {
+			flusher.FlushSessions()
+		}
+	}
+}
+
+// checkRegistered ... pr.mux must be held
+func (pr *ProviderRegistry) checkRegistered(name string) error {
+	if _, ok := pr.providers[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrProviderNotFound, name)
+	}
+	return nil
+}
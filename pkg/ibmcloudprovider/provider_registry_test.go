@@ -0,0 +1,147 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestRegistry(t *testing.T) (*ProviderRegistry, map[string]*FakeIBMCloudStorageProvider) {
+	fakes := map[string]*FakeIBMCloudStorageProvider{
+		"vpc":       NewFakeProviderBuilder().WithClusterID("vpc-cluster").Build(),
+		"classic":   NewFakeProviderBuilder().WithClusterID("classic-cluster").Build(),
+		"satellite": NewFakeProviderBuilder().WithClusterID("satellite-cluster").Build(),
+	}
+	registry := NewProviderRegistry()
+	for _, name := range []string{"vpc", "classic", "satellite"} {
+		assert.Nil(t, registry.Register(name, fakes[name]))
+	}
+	assert.Nil(t, registry.MapDriver("vpc.block.csi.ibm.io", "vpc"))
+	assert.Nil(t, registry.MapDriver("ibm.io/ibmc-block", "classic"))
+	assert.Nil(t, registry.MapVolumeIDPrefix("r", "vpc"))
+	assert.Nil(t, registry.MapVolumeIDPrefix("sat-", "satellite"))
+	return registry, fakes
+}
+
+func TestSelectProvider(t *testing.T) {
+	registry, _ := newTestRegistry(t)
+
+	testCases := []struct {
+		testCaseName string
+		selector     ProviderSelector
+		expectedName string
+		expectedErr  error
+	}{
+		{testCaseName: "by name", selector: ProviderSelector{Name: "satellite", Driver: "vpc.block.csi.ibm.io"}, expectedName: "satellite"},
+		{testCaseName: "by parameter", selector: ProviderSelector{Parameters: map[string]string{ProviderParameter: "classic"}, Driver: "vpc.block.csi.ibm.io"}, expectedName: "classic"},
+		{testCaseName: "by driver", selector: ProviderSelector{Driver: "ibm.io/ibmc-block", VolumeID: "r006-volume"}, expectedName: "classic"},
+		{testCaseName: "by volume ID", selector: ProviderSelector{Driver: "unknown.csi.ibm.io", VolumeID: "sat-volume"}, expectedName: "satellite"},
+		{testCaseName: "longest prefix", selector: ProviderSelector{VolumeID: "r006-volume"}, expectedName: "vpc"},
+		{testCaseName: "default", selector: ProviderSelector{VolumeID: "12345"}, expectedName: "vpc"},
+		{testCaseName: "unknown name", selector: ProviderSelector{Name: "power"}, expectedErr: ErrProviderNotFound},
+		{testCaseName: "unknown parameter", selector: ProviderSelector{Parameters: map[string]string{ProviderParameter: "power"}}, expectedErr: ErrProviderNotFound},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			name, cloudProvider, err := registry.SelectProvider(testcase.selector)
+			if testcase.expectedErr != nil {
+				assert.True(t, errors.Is(err, testcase.expectedErr))
+				assert.Nil(t, cloudProvider)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.expectedName, name)
+			expected, _ := registry.Provider(name)
+			assert.Same(t, expected, cloudProvider)
+		})
+	}
+}
+
+func TestRegistryGetProviderSession(t *testing.T) {
+	registry, fakes := newTestRegistry(t)
+
+	ctx := ContextWithProviderSelector(context.Background(), ProviderSelector{Driver: "ibm.io/ibmc-block"})
+	session, err := registry.GetProviderSession(ctx, zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, fakes["classic"].Session(), session)
+	assert.Equal(t, 0, fakes["vpc"].GetProviderSessionCallCount())
+
+	// Without selector the default provider is used
+	session, err = registry.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.Same(t, fakes["vpc"].Session(), session)
+	assert.Equal(t, "vpc-cluster", registry.GetClusterID())
+	assert.Same(t, fakes["vpc"].GetConfig(), registry.GetConfig())
+
+	assert.Nil(t, registry.SetDefault("satellite"))
+	assert.Equal(t, "satellite-cluster", registry.GetClusterID())
+
+	// Without default unmatched volumes have no provider
+	assert.Nil(t, registry.SetDefault(""))
+	_, err = registry.GetProviderSession(context.Background(), zap.NewNop())
+	assert.True(t, errors.Is(err, ErrProviderNotFound))
+	assert.Nil(t, registry.GetConfig())
+	assert.Equal(t, "", registry.GetClusterID())
+}
+
+func TestRegistryErrors(t *testing.T) {
+	registry, fakes := newTestRegistry(t)
+
+	assert.NotNil(t, registry.Register("vpc", fakes["vpc"]))
+	assert.NotNil(t, registry.Register("", fakes["vpc"]))
+	assert.NotNil(t, registry.Register("power", nil))
+	assert.True(t, errors.Is(registry.MapDriver("powervs.csi.ibm.io", "power"), ErrProviderNotFound))
+	assert.True(t, errors.Is(registry.MapVolumeIDPrefix("pvs-", "power"), ErrProviderNotFound))
+	assert.NotNil(t, registry.MapVolumeIDPrefix("", "vpc"))
+	assert.True(t, errors.Is(registry.SetDefault("power"), ErrProviderNotFound))
+	assert.Equal(t, []string{"classic", "satellite", "vpc"}, registry.Names())
+
+	// Mapping a prefix again replaces its provider
+	assert.Nil(t, registry.MapVolumeIDPrefix("sat-", "classic"))
+	name, _, err := registry.SelectProvider(ProviderSelector{VolumeID: "sat-volume"})
+	assert.Nil(t, err)
+	assert.Equal(t, "classic", name)
+}
+
+func TestRegistryInvalidateSession(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	vpc := NewCachingCloudProvider(logger, NewFakeProviderBuilder().WithNewSessionPerCall().Build())
+	defer vpc.Close()
+	registry := NewProviderRegistry()
+	assert.Nil(t, registry.Register("vpc", vpc))
+	assert.Nil(t, registry.Register("classic", NewFakeProviderBuilder().Build()))
+
+	session, err := registry.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	unauthenticated := status.Error(codes.Unauthenticated, "token expired")
+	assert.False(t, registry.InvalidateSession(&fake.FakeSession{}, unauthenticated))
+	assert.True(t, registry.InvalidateSession(session, unauthenticated))
+	again, err := registry.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	assert.NotSame(t, session, again)
+}
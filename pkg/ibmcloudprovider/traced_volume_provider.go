@@ -0,0 +1,88 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"github.com/IBM/ibm-csi-common/pkg/tracing"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// tracedCloudProvider records a span for every GetProviderSession call
+type tracedCloudProvider struct {
+	CloudProviderInterface
+}
+
+var _ SessionInvalidator = &tracedCloudProvider{}
+var _ SessionFlusher = &tracedCloudProvider{}
+var _ ConfigUpdater = &tracedCloudProvider{}
+
+// tracedMultiCloudProvider is a tracedCloudProvider of a
+// MultiCloudProviderInterface, which keeps its provider selection
+type tracedMultiCloudProvider struct {
+	tracedCloudProvider
+}
+
+var _ MultiCloudProviderInterface = &tracedMultiCloudProvider{}
+
+// NewTracedCloudProvider wraps cloudProvider so that session acquisition shows
+// up in traces
+func NewTracedCloudProvider(cloudProvider CloudProviderInterface) CloudProviderInterface {
+	if _, ok := cloudProvider.(MultiCloudProviderInterface); ok {
+		return &tracedMultiCloudProvider{tracedCloudProvider{CloudProviderInterface: cloudProvider}}
+	}
+	return &tracedCloudProvider{CloudProviderInterface: cloudProvider}
+}
+
+// GetProviderSession ...
+func (tcp *tracedCloudProvider) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
+	ctx, span := tracing.StartSpan(ctx, "CloudProvider.GetProviderSession")
+	session, err := tcp.CloudProviderInterface.GetProviderSession(ctx, logger)
+	tracing.EndSpan(span, err)
+	return session, err
+}
+
+// InvalidateSession ...
+func (tcp *tracedCloudProvider) InvalidateSession(session provider.Session, err error) bool {
+	if invalidator, ok := tcp.CloudProviderInterface.(SessionInvalidator); ok {
+		return invalidator.InvalidateSession(session, err)
+	}
+	return false
+}
+
+// FlushSessions ...
+func (tcp *tracedCloudProvider) FlushSessions() {
+	if flusher, ok := tcp.CloudProviderInterface.(SessionFlusher); ok {
+		flusher.FlushSessions()
+	}
+}
+
+// UpdateConfig ...
+func (tcp *tracedCloudProvider) UpdateConfig(conf *config.Config) bool {
+	if updater, ok := tcp.CloudProviderInterface.(ConfigUpdater); ok {
+		return updater.UpdateConfig(conf)
+	}
+	return false
+}
+
+// SelectProvider ...
+func (tmcp *tracedMultiCloudProvider) SelectProvider(selector ProviderSelector) (string, CloudProviderInterface, error) {
+	return tmcp.CloudProviderInterface.(MultiCloudProviderInterface).SelectProvider(selector)
+}
@@ -0,0 +1,73 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ibmcloudprovider ...
+package ibmcloudprovider
+
+import (
+	"testing"
+
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTracedCloudProviderForwardsOptionalInterfaces(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	fakeProvider := NewFakeProviderBuilder().WithNewSessionPerCall().Build()
+	ccp := NewCachingCloudProvider(logger, fakeProvider)
+	defer ccp.Close()
+	cloudProvider := NewTracedCloudProvider(ccp)
+
+	first, err := cloudProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	flusher, ok := cloudProvider.(SessionFlusher)
+	assert.True(t, ok)
+	flusher.FlushSessions()
+	second, err := cloudProvider.GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 2, fakeProvider.GetProviderSessionCallCount())
+
+	invalidator, ok := cloudProvider.(SessionInvalidator)
+	assert.True(t, ok)
+	assert.True(t, invalidator.InvalidateSession(second, status.Error(codes.Unauthenticated, "token expired")))
+
+	updater, ok := cloudProvider.(ConfigUpdater)
+	assert.True(t, ok)
+	conf := &config.Config{VPC: &config.VPCProviderConfig{G2APIKey: "new-key"}}
+	assert.True(t, updater.UpdateConfig(conf))
+	assert.Same(t, conf, fakeProvider.GetConfig())
+
+	// A single provider does not claim to select providers
+	_, ok = cloudProvider.(MultiCloudProviderInterface)
+	assert.False(t, ok)
+}
+
+func TestTracedCloudProviderSelectsProvider(t *testing.T) {
+	registry, _ := newTestRegistry(t)
+	cloudProvider, ok := NewTracedCloudProvider(registry).(MultiCloudProviderInterface)
+	assert.True(t, ok)
+	name, selected, err := cloudProvider.SelectProvider(ProviderSelector{Driver: "ibm.io/ibmc-block"})
+	assert.Nil(t, err)
+	assert.Equal(t, "classic", name)
+	expected, _ := registry.Provider("classic")
+	assert.Same(t, expected, selected)
+}
@@ -0,0 +1,153 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	util "github.com/IBM/ibmcloud-volume-interface/lib/utils"
+	"google.golang.org/grpc/codes"
+)
+
+// BackendError is an error of the ibmcloud-volume-interface library, e.g.
+// {Trace Code:920df6e8-6be9-4b4a-89e4-837ecb3f513d, Code:InvalidArgument,
+// Description:Please check parameters, RC:400 Bad Request}
+type BackendError struct {
+	TraceCode   string
+	Code        string
+	Description string
+
+	// RC is the HTTP return code of the backend, 0 if unknown
+	RC int
+
+	// Status is the text following RC, e.g. Bad Request
+	Status string
+}
+
+// backendErrorPattern matches both library formats, with and without the
+// trace code of VPC backend errors. The description may contain commas so it
+// extends to the last RC field.
+var backendErrorPattern = regexp.MustCompile(`(?s)(?:Trace Code:\s*([^,]*),\s*)?Code:\s*([^,]*),\s*Description:\s*(.*),\s*RC:\s*(\d+)\s*([A-Za-z ]*)`)
+
+// ParseBackendError extracts the fields of a library error from err, which
+// is either a library Message or carries its text
+func ParseBackendError(err error) (BackendError, bool) {
+	if err == nil {
+		return BackendError{}, false
+	}
+	var libMsg util.Message
+	if errors.As(err, &libMsg) && libMsg.RC != 0 && !strings.Contains(libMsg.BackendError, "Trace Code:") {
+		return BackendError{Code: libMsg.Code, Description: libMsg.Description, RC: libMsg.RC}, true
+	}
+	match := backendErrorPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return BackendError{}, false
+	}
+	rc, _ := strconv.Atoi(match[4])
+	return BackendError{
+		TraceCode:   strings.TrimSpace(match[1]),
+		Code:        strings.TrimSpace(match[2]),
+		Description: strings.TrimSpace(match[3]),
+		RC:          rc,
+		Status:      strings.TrimSpace(match[5]),
+	}, true
+}
+
+// backendCodes maps backend codes, lower cased, to gRPC codes. They take
+// precedence over the return code, which is less specific.
+var backendCodes = map[string]codes.Code{
+	"not_found":                   codes.NotFound,
+	"volume_not_found":            codes.NotFound,
+	"volume_id_not_found":         codes.NotFound,
+	"snapshot_not_found":          codes.NotFound,
+	"volume_attachment_not_found": codes.NotFound,
+	"instance_not_found":          codes.NotFound,
+	"volume_in_use":               codes.FailedPrecondition,
+	"volume_name_duplicate":       codes.AlreadyExists,
+	"snapshot_name_duplicate":     codes.AlreadyExists,
+	"conflict":                    codes.AlreadyExists,
+	"over_quota":                  codes.ResourceExhausted,
+	"quota_exceeded":              codes.ResourceExhausted,
+	"rate_limit_exceeded":         codes.ResourceExhausted,
+	"too_many_requests":           codes.ResourceExhausted,
+	"service_unavailable":         codes.Unavailable,
+	"timeout":                     codes.DeadlineExceeded,
+	"request_timeout":             codes.DeadlineExceeded,
+}
+
+// returnCodes maps backend return codes to gRPC codes
+var returnCodes = map[int]codes.Code{
+	400: codes.InvalidArgument,
+	401: codes.Unauthenticated,
+	403: codes.PermissionDenied,
+	404: codes.NotFound,
+	408: codes.DeadlineExceeded,
+	409: codes.AlreadyExists,
+	412: codes.FailedPrecondition,
+	429: codes.ResourceExhausted,
+	500: codes.Internal,
+	501: codes.Unimplemented,
+	502: codes.Unavailable,
+	503: codes.Unavailable,
+	504: codes.DeadlineExceeded,
+}
+
+// GRPCCode returns the gRPC code of the backend error, by backend code then
+// by return code. Unknown 5xx errors are Internal, others InvalidArgument.
+func (be BackendError) GRPCCode() codes.Code {
+	if code, ok := backendCodes[strings.ToLower(be.Code)]; ok {
+		return code
+	}
+	if code, ok := returnCodes[be.RC]; ok {
+		return code
+	}
+	if be.RC >= 500 {
+		return codes.Internal
+	}
+	return codes.InvalidArgument
+}
+
+// BackendErrorCode returns the gRPC code for err returned by the library.
+// Errors which cannot be parsed are DeadlineExceeded for timeouts, Internal
+// if they mention a 5xx return code and InvalidArgument otherwise.
+func BackendErrorCode(err error) codes.Code {
+	if be, ok := ParseBackendError(err); ok {
+		return be.GRPCCode()
+	}
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || strings.Contains(strings.ToLower(err.Error()), "timeout")) {
+		return codes.DeadlineExceeded
+	}
+	if err != nil && strings.Contains(strings.Replace(err.Error(), " ", "", -1), RC5XX) {
+		return codes.Internal
+	}
+	return codes.InvalidArgument
+}
+
+// isServerSideCode reports whether a backend error of code is a server side
+// issue rather than a problem with the request
+func isServerSideCode(code codes.Code) bool {
+	switch code {
+	case codes.Internal, codes.Unavailable, codes.DeadlineExceeded, codes.Unimplemented, codes.Unknown:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	util "github.com/IBM/ibmcloud-volume-interface/lib/utils"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseBackendError(t *testing.T) {
+	testCases := []struct {
+		testCaseName   string
+		inputErr       error
+		expectedOk     bool
+		expectedResult BackendError
+	}{
+		{
+			testCaseName: "VPC backend error",
+			inputErr:     errors.New("{Trace Code:920df6e8-6be9-4b4a-89e4-837ecb3f513d, Code:InvalidArgument, Description:Please check parameters, RC:400 Bad Request}"),
+			expectedOk:   true,
+			expectedResult: BackendError{
+				TraceCode:   "920df6e8-6be9-4b4a-89e4-837ecb3f513d",
+				Code:        "InvalidArgument",
+				Description: "Please check parameters",
+				RC:          400,
+				Status:      "Bad Request",
+			},
+		},
+		{
+			testCaseName: "VPC backend error with library description",
+			inputErr: util.Message{
+				Code:         "ErrorVolumeCreate",
+				Description:  "Failed to create volume, please retry",
+				BackendError: "Trace Code:abc, Code:volume_name_duplicate, Description:The volume name pvc-1 is already in use, RC:409 Conflict",
+				RC:           409,
+			},
+			expectedOk: true,
+			expectedResult: BackendError{
+				TraceCode:   "abc",
+				Code:        "volume_name_duplicate",
+				Description: "The volume name pvc-1 is already in use",
+				RC:          409,
+				Status:      "Conflict",
+			},
+		},
+		{
+			testCaseName:   "Library message",
+			inputErr:       fmt.Errorf("create: %w", util.Message{Code: "StorageFindFailedWithVolumeId", Description: "A volume with the specified volume ID 'vol-1' could not be found", RC: 404}),
+			expectedOk:     true,
+			expectedResult: BackendError{Code: "StorageFindFailedWithVolumeId", Description: "A volume with the specified volume ID 'vol-1' could not be found", RC: 404},
+		},
+		{
+			testCaseName:   "Library message text",
+			inputErr:       errors.New("{Code:ErrorRequiredFieldMissing, Description:[Profile] is required to complete the operation., RC:400}"),
+			expectedOk:     true,
+			expectedResult: BackendError{Code: "ErrorRequiredFieldMissing", Description: "[Profile] is required to complete the operation.", RC: 400},
+		},
+		{
+			testCaseName: "Plain error",
+			inputErr:     errors.New("connection refused"),
+			expectedOk:   false,
+		},
+		{
+			testCaseName: "Nil error",
+			inputErr:     nil,
+			expectedOk:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			result, ok := ParseBackendError(tc.inputErr)
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedResult, result)
+		})
+	}
+}
+
+func TestBackendErrorCode(t *testing.T) {
+	testCases := []struct {
+		testCaseName string
+		inputErr     error
+		expectedCode codes.Code
+	}{
+		{
+			testCaseName: "Bad request",
+			inputErr:     errors.New("{Trace Code:1, Code:InvalidArgument, Description:Please check parameters, RC:400 Bad Request}"),
+			expectedCode: codes.InvalidArgument,
+		},
+		{
+			testCaseName: "Not found by return code",
+			inputErr:     errors.New("{Trace Code:1, Code:Unknown, Description:Volume not found, RC:404 Not Found}"),
+			expectedCode: codes.NotFound,
+		},
+		{
+			testCaseName: "Not found by backend code",
+			inputErr:     errors.New("{Trace Code:1, Code:volume_not_found, Description:Volume not found, RC:400 Bad Request}"),
+			expectedCode: codes.NotFound,
+		},
+		{
+			testCaseName: "Conflict",
+			inputErr:     errors.New("{Trace Code:1, Code:volume_name_duplicate, Description:Name in use, RC:409 Conflict}"),
+			expectedCode: codes.AlreadyExists,
+		},
+		{
+			testCaseName: "Too many requests",
+			inputErr:     errors.New("{Trace Code:1, Code:rate_limit_exceeded, Description:Slow down, RC:429 Too Many Requests}"),
+			expectedCode: codes.ResourceExhausted,
+		},
+		{
+			testCaseName: "Quota",
+			inputErr:     errors.New("{Trace Code:1, Code:over_quota, Description:Quota exceeded, RC:403 Forbidden}"),
+			expectedCode: codes.ResourceExhausted,
+		},
+		{
+			testCaseName: "Forbidden",
+			inputErr:     errors.New("{Trace Code:1, Code:forbidden, Description:Not authorized, RC:403 Forbidden}"),
+			expectedCode: codes.PermissionDenied,
+		},
+		{
+			testCaseName: "Internal server error",
+			inputErr:     errors.New("{Trace Code:1, Code:InternalError, Description:Server error, RC:500 Internal Server Error}"),
+			expectedCode: codes.Internal,
+		},
+		{
+			testCaseName: "Service unavailable",
+			inputErr:     errors.New("{Trace Code:1, Code:service_error, Description:Try later, RC:503 Service Unavailable}"),
+			expectedCode: codes.Unavailable,
+		},
+		{
+			testCaseName: "Gateway timeout",
+			inputErr:     errors.New("{Trace Code:1, Code:gateway_error, Description:Timed out, RC:504 Gateway Timeout}"),
+			expectedCode: codes.DeadlineExceeded,
+		},
+		{
+			testCaseName: "Unknown 5xx",
+			inputErr:     errors.New("{Trace Code:1, Code:whatever, Description:Oops, RC:599 Custom}"),
+			expectedCode: codes.Internal,
+		},
+		{
+			testCaseName: "Context deadline",
+			inputErr:     fmt.Errorf("get volume: %w", context.DeadlineExceeded),
+			expectedCode: codes.DeadlineExceeded,
+		},
+		{
+			testCaseName: "Client timeout",
+			inputErr:     errors.New("Post https://us-south.iaas.cloud.ibm.com: net/http: request canceled (Client.Timeout exceeded)"),
+			expectedCode: codes.DeadlineExceeded,
+		},
+		{
+			testCaseName: "Unparsed 5xx",
+			inputErr:     errors.New("failed with RC: 502"),
+			expectedCode: codes.Internal,
+		},
+		{
+			testCaseName: "Unparsed error",
+			inputErr:     errors.New("invalid profile"),
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			assert.Equal(t, tc.expectedCode, BackendErrorCode(tc.inputErr))
+		})
+	}
+}
+
+func TestGetCSIBackendErrorCode(t *testing.T) {
+	MessagesEn = InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+
+	err := GetCSIBackendError(ctxLog, reqID, errors.New("{Trace Code:1, Code:volume_not_found, Description:Volume not found, RC:404 Not Found}"))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.True(t, errors.Is(err, ErrInvalidParameters))
+	assert.True(t, strings.HasPrefix(err.Error(), "rpc error: code = NotFound desc = {RequestID: "))
+
+	err = GetCSIBackendError(ctxLog, reqID, errors.New("{Trace Code:1, Code:service_error, Description:Try later, RC:503 Service Unavailable}"))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.True(t, errors.Is(err, ErrInternalError))
+
+	err = GetCSIBackendError(ctxLog, reqID, context.DeadlineExceeded)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.True(t, errors.Is(err, ErrInternalError))
+}
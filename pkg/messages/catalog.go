@@ -0,0 +1,201 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultLocale of the messages in messages_en.go, used for reason codes
+	// missing in other locales
+	DefaultLocale = "en"
+
+	// LocaleEnvVar selects the locale when the -locale flag is not set
+	LocaleEnvVar = "CSI_LOCALE"
+)
+
+// localeFiles holds the translations of every locale but English, one JSON
+// file per language
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// localizedMessage is the entry of a reason code in a locale file. The code
+// and gRPC type of a message do not depend on the locale.
+type localizedMessage struct {
+	Description string `json:"description"`
+	Action      string `json:"action"`
+}
+
+var (
+	localeFlag string
+
+	catalogMux    sync.RWMutex
+	catalog       map[string]Message
+	catalogLocale = DefaultLocale
+)
+
+// formatVerbPattern matches the fmt verbs of a description, but not %%
+var formatVerbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]*)?[a-zA-Z%]`)
+
+// formatVerbs returns the fmt verbs of s in order
+func formatVerbs(s string) []string {
+	var verbs []string
+	for _, verb := range formatVerbPattern.FindAllString(s, -1) {
+		if verb != "%%" {
+			verbs = append(verbs, verb)
+		}
+	}
+	return verbs
+}
+
+// normalizeLocale turns a locale such as ja_JP.UTF-8 or de-DE into its language
+func normalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if i := strings.IndexAny(locale, "_-"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" || locale == "c" || locale == "posix" {
+		return DefaultLocale
+	}
+	return locale
+}
+
+// AvailableLocales returns the supported locales, sorted
+func AvailableLocales() []string {
+	locales := []string{DefaultLocale}
+	entries, _ := localeFiles.ReadDir("locales")
+	for _, entry := range entries {
+		locales = append(locales, strings.TrimSuffix(entry.Name(), path.Ext(entry.Name())))
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// readLocaleFile returns the translations of locale
+func readLocaleFile(locale string) (map[string]localizedMessage, error) {
+	data, err := localeFiles.ReadFile(path.Join("locales", locale+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported locale '%s', supported locales are %v", locale, AvailableLocales())
+	}
+	var entries map[string]localizedMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid messages of locale '%s': %v", locale, err)
+	}
+	return entries, nil
+}
+
+// LoadCatalog returns the messages of locale. Reason codes without a
+// translation, or whose translation has other fmt verbs than the English
+// description, keep the English message.
+func LoadCatalog(locale string) (map[string]Message, error) {
+	locale = normalizeLocale(locale)
+	messages := make(map[string]Message, len(messagesEn))
+	for code, msg := range messagesEn {
+		messages[code] = msg
+	}
+	if locale == DefaultLocale {
+		return messages, nil
+	}
+	entries, err := readLocaleFile(locale)
+	if err != nil {
+		return nil, err
+	}
+	translate(messages, entries)
+	return messages, nil
+}
+
+// translate replaces the description and action of messages by their
+// translations in entries if they have the same fmt verbs
+func translate(messages map[string]Message, entries map[string]localizedMessage) {
+	for code, entry := range entries {
+		msg, ok := messages[code]
+		if !ok {
+			continue
+		}
+		if entry.Description != "" && reflect.DeepEqual(formatVerbs(entry.Description), formatVerbs(msg.Description)) {
+			msg.Description = entry.Description
+		}
+		if entry.Action != "" && reflect.DeepEqual(formatVerbs(entry.Action), formatVerbs(msg.Action)) {
+			msg.Action = entry.Action
+		}
+		messages[code] = msg
+	}
+}
+
+// SetLocale makes GetCSIMessage return the messages of locale, e.g. en, de,
+// ja or ja_JP.UTF-8
+func SetLocale(locale string) error {
+	messages, err := LoadCatalog(locale)
+	if err != nil {
+		return err
+	}
+	catalogMux.Lock()
+	defer catalogMux.Unlock()
+	catalog = messages
+	catalogLocale = normalizeLocale(locale)
+	return nil
+}
+
+// Locale returns the locale of the messages returned by GetCSIMessage
+func Locale() string {
+	catalogMux.RLock()
+	defer catalogMux.RUnlock()
+	return catalogLocale
+}
+
+// AddLocaleFlag registers the -locale flag on fs, read by InitLocale
+func AddLocaleFlag(fs *flag.FlagSet) {
+	fs.StringVar(&localeFlag, "locale", "", fmt.Sprintf("Locale of CSI user messages, one of %v. Defaults to $%s, then to %s.", AvailableLocales(), LocaleEnvVar, DefaultLocale))
+}
+
+// InitLocale selects the locale from the -locale flag, or from the
+// CSI_LOCALE environment variable if the flag is not set
+func InitLocale() error {
+	locale := localeFlag
+	if locale == "" {
+		locale = os.Getenv(LocaleEnvVar)
+	}
+	return SetLocale(locale)
+}
+
+// localizedCSIMessage returns the message of code in the selected locale, if
+// a locale was selected
+func localizedCSIMessage(code string) (Message, bool) {
+	catalogMux.RLock()
+	defer catalogMux.RUnlock()
+	if catalog == nil {
+		return Message{}, false
+	}
+	msg, ok := catalog[code]
+	return msg, ok
+}
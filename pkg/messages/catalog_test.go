@@ -0,0 +1,156 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resetLocale restores the default behaviour of GetCSIMessage after a test
+func resetLocale(t *testing.T) {
+	t.Cleanup(func() {
+		catalogMux.Lock()
+		defer catalogMux.Unlock()
+		catalog = nil
+		catalogLocale = DefaultLocale
+	})
+}
+
+func TestLocaleConsistency(t *testing.T) {
+	codes := reasonCodes
+	assert.True(t, len(codes) > 50)
+
+	for _, locale := range AvailableLocales() {
+		t.Run(locale, func(t *testing.T) {
+			translations := map[string]localizedMessage{}
+			if locale == DefaultLocale {
+				for code, msg := range messagesEn {
+					translations[code] = localizedMessage{Description: msg.Description, Action: msg.Action}
+				}
+			} else {
+				var err error
+				translations, err = readLocaleFile(locale)
+				assert.Nil(t, err)
+			}
+
+			for _, code := range codes {
+				english, ok := messagesEn[code]
+				assert.True(t, ok, "%s has no English message", code)
+				entry, ok := translations[code]
+				if !assert.True(t, ok, "%s has no %s message", code, locale) {
+					continue
+				}
+				assert.NotEmpty(t, entry.Description, code)
+				assert.NotEmpty(t, entry.Action, code)
+				assert.Equal(t, formatVerbs(english.Description), formatVerbs(entry.Description), "%s description arity in %s", code, locale)
+				assert.Equal(t, formatVerbs(english.Action), formatVerbs(entry.Action), "%s action arity in %s", code, locale)
+			}
+			assert.Equal(t, len(codes), len(translations), "%s has messages of unknown reason codes", locale)
+		})
+	}
+}
+
+func TestAvailableLocales(t *testing.T) {
+	assert.Equal(t, []string{"de", "en", "ja"}, AvailableLocales())
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	testCases := []struct {
+		testCaseName   string
+		input          string
+		expectedOutput string
+	}{
+		{testCaseName: "Language", input: "ja", expectedOutput: "ja"},
+		{testCaseName: "POSIX locale", input: "de_DE.UTF-8", expectedOutput: "de"},
+		{testCaseName: "BCP 47 tag", input: "ja-JP", expectedOutput: "ja"},
+		{testCaseName: "Modifier", input: "de_DE@euro", expectedOutput: "de"},
+		{testCaseName: "Empty", input: "", expectedOutput: DefaultLocale},
+		{testCaseName: "C locale", input: "C", expectedOutput: DefaultLocale},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOutput, normalizeLocale(tc.input))
+		})
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	resetLocale(t)
+	MessagesEn = InitMessages()
+	english := GetCSIMessage(VolumeAlreadyExists, "pvc-1", "10")
+
+	assert.Nil(t, SetLocale("ja_JP.UTF-8"))
+	assert.Equal(t, "ja", Locale())
+	japanese := GetCSIMessage(VolumeAlreadyExists, "pvc-1", "10")
+	assert.Equal(t, "名前 'pvc-1' のボリュームは既に存在し、サイズ '10' と互換性がありません", japanese.Description)
+	assert.Equal(t, english.Code, japanese.Code)
+	assert.Equal(t, english.Type, japanese.Type)
+
+	assert.Nil(t, SetLocale("de"))
+	assert.True(t, strings.HasPrefix(GetCSIMessage(EmptyVolumeID).Description, "Die VolumeID"))
+
+	assert.NotNil(t, SetLocale("fr"))
+	assert.Equal(t, "de", Locale())
+
+	assert.Nil(t, SetLocale(""))
+	assert.Equal(t, english, GetCSIMessage(VolumeAlreadyExists, "pvc-1", "10"))
+}
+
+func TestTranslateFallsBackToEnglish(t *testing.T) {
+	messages := map[string]Message{
+		VolumeAlreadyExists: messagesEn[VolumeAlreadyExists],
+		EmptyVolumeID:       messagesEn[EmptyVolumeID],
+		InternalError:       messagesEn[InternalError],
+	}
+	translate(messages, map[string]localizedMessage{
+		// Wrong arity is ignored
+		VolumeAlreadyExists: {Description: "Volume '%s' existiert", Action: "Anderen Namen angeben"},
+		// Missing action keeps the English one
+		EmptyVolumeID: {Description: "VolumeID fehlt"},
+		// Unknown codes are ignored
+		"UnknownCode": {Description: "Unbekannt"},
+	})
+
+	assert.Equal(t, messagesEn[VolumeAlreadyExists].Description, messages[VolumeAlreadyExists].Description)
+	assert.Equal(t, "Anderen Namen angeben", messages[VolumeAlreadyExists].Action)
+	assert.Equal(t, "VolumeID fehlt", messages[EmptyVolumeID].Description)
+	assert.Equal(t, messagesEn[EmptyVolumeID].Action, messages[EmptyVolumeID].Action)
+	assert.Equal(t, messagesEn[InternalError], messages[InternalError])
+	assert.Equal(t, 3, len(messages))
+}
+
+func TestInitLocale(t *testing.T) {
+	resetLocale(t)
+	t.Cleanup(func() { localeFlag = "" })
+
+	t.Setenv(LocaleEnvVar, "de_DE.UTF-8")
+	assert.Nil(t, InitLocale())
+	assert.Equal(t, "de", Locale())
+
+	// The flag takes precedence over the environment
+	fs := flag.NewFlagSet("driver", flag.ContinueOnError)
+	AddLocaleFlag(fs)
+	assert.Nil(t, fs.Parse([]string{"-locale", "ja"}))
+	assert.Nil(t, InitLocale())
+	assert.Equal(t, "ja", Locale())
+}
@@ -0,0 +1,91 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ErrorDomain is the domain of the google.rpc.ErrorInfo detail attached
+	// to the gRPC status of a Message
+	ErrorDomain = "ibm-csi-common"
+
+	// RequestIDMetadataKey is the ErrorInfo metadata key of the request ID
+	RequestIDMetadataKey = "requestID"
+)
+
+// Unwrap returns the error the message was created from, if any
+func (msg Message) Unwrap() error {
+	return msg.err
+}
+
+// Is reports whether target is a Message with the same reason code
+func (msg Message) Is(target error) bool {
+	switch t := target.(type) {
+	case Message:
+		return t.Code != "" && t.Code == msg.Code
+	case *Message:
+		return t != nil && t.Code != "" && t.Code == msg.Code
+	}
+	return false
+}
+
+// GRPCStatus returns the gRPC status of the message, with the reason code in
+// a google.rpc.ErrorInfo detail
+func (msg Message) GRPCStatus() *status.Status {
+	st := status.New(msg.Type, msg.Info())
+	if msg.Code == "" {
+		return st
+	}
+	info := &errdetails.ErrorInfo{Reason: msg.Code, Domain: ErrorDomain}
+	if msg.RequestID != "" {
+		info.Metadata = map[string]string{RequestIDMetadataKey: msg.RequestID}
+	}
+	if detailed, err := st.WithDetails(info); err == nil {
+		return detailed
+	}
+	return st
+}
+
+// ReasonCodeFromStatus returns the reason code in the ErrorInfo detail of st,
+// or an empty string if it has none
+func ReasonCodeFromStatus(st *status.Status) string {
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == ErrorDomain {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+// ReasonCodeFromError returns the reason code of err, which is either a
+// Message or a gRPC status error received from a driver, or an empty string
+func ReasonCodeFromError(err error) string {
+	var msg Message
+	if errors.As(err, &msg) {
+		return msg.Code
+	}
+	if st, ok := status.FromError(err); ok {
+		return ReasonCodeFromStatus(st)
+	}
+	return ""
+}
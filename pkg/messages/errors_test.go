@@ -0,0 +1,120 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMessageIs(t *testing.T) {
+	MessagesEn = InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+
+	err := GetCSIError(ctxLog, VolumeAlreadyExists, reqID, nil, "pvc-1", 10)
+	assert.True(t, errors.Is(err, ErrVolumeAlreadyExists))
+	assert.True(t, errors.Is(err, &ErrVolumeAlreadyExists))
+	assert.False(t, errors.Is(err, ErrSnapshotAlreadyExists))
+	assert.False(t, errors.Is(err, Message{}))
+
+	// Wrapped messages still match
+	wrapped := fmt.Errorf("create volume: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrVolumeAlreadyExists))
+	var msg Message
+	assert.True(t, errors.As(wrapped, &msg))
+	assert.Equal(t, reqID, msg.RequestID)
+}
+
+func TestMessageUnwrap(t *testing.T) {
+	MessagesEn = InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+	cause := errors.New("quota exceeded")
+
+	err := GetCSIError(ctxLog, VolumeCreationFailed, reqID, cause, "pvc-1")
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(err, ErrVolumeCreationFailed))
+
+	err = GetCSIBackendError(ctxLog, reqID, cause)
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(err, ErrInvalidParameters))
+
+	assert.Nil(t, GetCSIMessage(EmptyVolumeID).Unwrap())
+}
+
+func TestMessageGRPCStatus(t *testing.T) {
+	MessagesEn = InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+
+	testCases := []struct {
+		testCaseName   string
+		inputErr       error
+		expectedCode   codes.Code
+		expectedReason string
+	}{
+		{
+			testCaseName:   "CSI error",
+			inputErr:       GetCSIError(ctxLog, EmptyVolumeID, reqID, nil),
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: EmptyVolumeID,
+		},
+		{
+			testCaseName:   "Backend 5xx error",
+			inputErr:       GetCSIBackendError(ctxLog, reqID, errors.New("{Trace Code:1, Code:InternalError, Description:Server error, RC:500 Internal Server Error}")),
+			expectedCode:   codes.Internal,
+			expectedReason: InternalError,
+		},
+		{
+			testCaseName:   "Wrapped CSI error",
+			inputErr:       fmt.Errorf("node publish: %w", GetCSIError(ctxLog, TargetPathCheckFailed, reqID, nil, "/mnt")),
+			expectedCode:   codes.Internal,
+			expectedReason: TargetPathCheckFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			st, ok := status.FromError(tc.inputErr)
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedCode, st.Code())
+			assert.Equal(t, tc.expectedReason, ReasonCodeFromStatus(st))
+
+			// The reason code survives the wire format of the status
+			received := status.FromProto(st.Proto()).Err()
+			assert.Equal(t, tc.expectedReason, ReasonCodeFromError(received))
+			assert.Equal(t, tc.expectedReason, ReasonCodeFromError(tc.inputErr))
+
+			info := st.Details()[0].(*errdetails.ErrorInfo)
+			assert.Equal(t, ErrorDomain, info.GetDomain())
+			assert.Equal(t, reqID, info.GetMetadata()[RequestIDMetadataKey])
+		})
+	}
+}
+
+func TestReasonCodeFromError(t *testing.T) {
+	assert.Equal(t, "", ReasonCodeFromError(errors.New("plain")))
+	assert.Equal(t, "", ReasonCodeFromError(status.Error(codes.Internal, "no details")))
+	assert.Equal(t, "", ReasonCodeFromStatus(Message{Type: codes.Internal}.GRPCStatus()))
+}
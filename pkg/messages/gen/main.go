@@ -0,0 +1,211 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main generates helpers_gen.go of package messages: the list of
+// reason codes, a sentinel error per reason code and a typed helper per reason
+// code taking one argument per fmt verb of its English description.
+//
+// Run it with go generate ./pkg/messages/...
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"text/template"
+)
+
+// formatVerbPattern matches fmt verbs, see formatVerbs of package messages
+var formatVerbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]*)?[a-zA-Z%]`)
+
+// reasonCode is a reason code and the Go types of its description arguments
+type reasonCode struct {
+	Name     string
+	ArgTypes []string
+}
+
+// argType returns the Go type of the argument of verb
+func argType(verb string) string {
+	switch verb[len(verb)-1] {
+	case 's', 'q':
+		return "string"
+	case 'd':
+		return "int"
+	}
+	return "interface{}"
+}
+
+// reasonCodes returns the constants of reason_code.go in dir
+func reasonCodes(dir string) ([]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, "reason_code.go"), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			for _, name := range spec.(*ast.ValueSpec).Names {
+				// RC5XX is a backend return code prefix, not a reason code
+				if name.Name != "RC5XX" {
+					names = append(names, name.Name)
+				}
+			}
+		}
+	}
+	return names, nil
+}
+
+// descriptions returns the English descriptions of messages_en.go in dir
+func descriptions(dir string) (map[string]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, "messages_en.go"), nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	ast.Inspect(file, func(node ast.Node) bool {
+		entry, ok := node.(*ast.KeyValueExpr)
+		if !ok {
+			return true
+		}
+		key, ok := entry.Key.(*ast.Ident)
+		value, isLit := entry.Value.(*ast.CompositeLit)
+		if !ok || !isLit {
+			return true
+		}
+		for _, elt := range value.Elts {
+			field, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			if name, ok := field.Key.(*ast.Ident); ok && name.Name == "Description" {
+				if lit, ok := field.Value.(*ast.BasicLit); ok {
+					result[key.Name], _ = strconv.Unquote(lit.Value)
+				}
+			}
+		}
+		return false
+	})
+	return result, nil
+}
+
+var helpersTemplate = template.Must(template.New("helpers").Parse(`{{.Header}}
+
+// Code generated by go run ./gen; DO NOT EDIT.
+
+// Package messages ...
+package messages
+
+import (
+	"go.uber.org/zap"
+)
+
+// reasonCodes lists every reason code of reason_code.go
+var reasonCodes = []string{
+{{- range .Codes}}
+	{{.Name}},
+{{- end}}
+}
+
+// Sentinel errors of every reason code, for use with errors.Is, e.g.
+// errors.Is(err, messages.ErrVolumeAlreadyExists)
+var (
+{{- range $i, $code := .Codes}}
+{{- if $i}}
+{{end}}
+	// Err{{$code.Name}} matches errors with the {{$code.Name}} reason code
+	Err{{$code.Name}} = Message{Code: {{$code.Name}}}
+{{- end}}
+)
+{{range .Codes}}
+// {{.Name}}Message returns the {{.Name}} message
+func {{.Name}}Message({{range $i, $t := .ArgTypes}}{{if $i}}, {{end}}arg{{$i}} {{$t}}{{end}}) Message {
+	return GetCSIMessage({{.Name}}{{range $i, $t := .ArgTypes}}, arg{{$i}}{{end}})
+}
+
+// {{.Name}}Error logs and returns the {{.Name}} error wrapping err
+func {{.Name}}Error(logger *zap.Logger, requestID string, err error{{range $i, $t := .ArgTypes}}, arg{{$i}} {{$t}}{{end}}) error {
+	return GetCSIError(logger, {{.Name}}, requestID, err{{range $i, $t := .ArgTypes}}, arg{{$i}}{{end}})
+}
+{{end}}`))
+
+// generate returns the source of helpers_gen.go for package messages in dir
+func generate(dir string) ([]byte, error) {
+	names, err := reasonCodes(dir)
+	if err != nil {
+		return nil, err
+	}
+	descs, err := descriptions(dir)
+	if err != nil {
+		return nil, err
+	}
+	source, err := os.ReadFile(filepath.Join(dir, "reason_code.go"))
+	if err != nil {
+		return nil, err
+	}
+	header := source[:bytes.Index(source, []byte("// Package messages"))]
+
+	var codes []reasonCode
+	for _, name := range names {
+		desc, ok := descs[name]
+		if !ok {
+			return nil, fmt.Errorf("reason code %s has no entry in messages_en.go", name)
+		}
+		code := reasonCode{Name: name}
+		for _, verb := range formatVerbPattern.FindAllString(desc, -1) {
+			if verb != "%%" {
+				code.ArgTypes = append(code.ArgTypes, argType(verb))
+			}
+		}
+		codes = append(codes, code)
+	}
+
+	var buf bytes.Buffer
+	err = helpersTemplate.Execute(&buf, struct {
+		Header string
+		Codes  []reasonCode
+	}{Header: string(bytes.TrimSpace(header)), Codes: codes})
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	dir := "."
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+	source, err := generate(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "helpers_gen.go"), source, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main ...
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelpersUpToDate(t *testing.T) {
+	expected, err := generate("..")
+	assert.Nil(t, err)
+	actual, err := os.ReadFile("../helpers_gen.go")
+	assert.Nil(t, err)
+	assert.Equal(t, string(expected), string(actual), "helpers_gen.go is stale, run go generate ./pkg/messages/...")
+}
+
+func TestArgType(t *testing.T) {
+	assert.Equal(t, "string", argType("%s"))
+	assert.Equal(t, "string", argType("%q"))
+	assert.Equal(t, "int", argType("%d"))
+	assert.Equal(t, "interface{}", argType("%v"))
+}
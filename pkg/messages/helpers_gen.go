@@ -0,0 +1,788 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Code generated by go run ./gen; DO NOT EDIT.
+
+// Package messages ...
+package messages
+
+import (
+	"go.uber.org/zap"
+)
+
+// reasonCodes lists every reason code of reason_code.go
+var reasonCodes = []string{
+	MethodUnimplemented,
+	MethodUnsupported,
+	MissingVolumeName,
+	MissingSnapshotName,
+	MissingSourceVolumeID,
+	VolumeAlreadyExists,
+	SnapshotAlreadyExists,
+	EmptyVolumeID,
+	EmptySnapshotID,
+	UnsupportedVolumeContentSource,
+	EmptyVolumePath,
+	EmptyNodeID,
+	EndpointNotReachable,
+	Timeout,
+	VolumeInvalidArguments,
+	VolumeCreationFailed,
+	NoVolumeCapabilities,
+	VolumeCapabilitiesNotSupported,
+	InvalidParameters,
+	InternalError,
+	ObjectNotFound,
+	ProfileNotAllowlisted,
+	FailedPrecondition,
+	NoStagingTargetPath,
+	NoTargetPath,
+	MountPointValidateError,
+	UnmountFailed,
+	MountFailed,
+	EmptyDevicePath,
+	DevicePathFindFailed,
+	DevicePathNotFound,
+	TargetPathCheckFailed,
+	TargetPathCreateFailed,
+	VolumeMountCheckFailed,
+	FormatAndMountFailed,
+	NodeMetadataInitFailed,
+	DevicePathNotExists,
+	BlockDeviceCheckFailed,
+	GetDeviceInfoFailed,
+	GetFSInfoFailed,
+	DriverNotConfigured,
+	RemoveMountTargetFailed,
+	CreateMountTargetFailed,
+	MountingTargetFailed,
+	UnresponsiveMountHelperContainerUtility,
+	MetadataServiceNotEnabled,
+	ListVolumesFailed,
+	ListSnapshotsFailed,
+	StartVolumeIDNotFound,
+	StartSnapshotIDNotFound,
+	FileSystemResizeFailed,
+	VolumePathNotMounted,
+	SubnetIDListNotFound,
+	SubnetFindFailed,
+}
+
+// Sentinel errors of every reason code, for use with errors.Is, e.g.
+// errors.Is(err, messages.ErrVolumeAlreadyExists)
+var (
+	// ErrMethodUnimplemented matches errors with the MethodUnimplemented reason code
+	ErrMethodUnimplemented = Message{Code: MethodUnimplemented}
+
+	// ErrMethodUnsupported matches errors with the MethodUnsupported reason code
+	ErrMethodUnsupported = Message{Code: MethodUnsupported}
+
+	// ErrMissingVolumeName matches errors with the MissingVolumeName reason code
+	ErrMissingVolumeName = Message{Code: MissingVolumeName}
+
+	// ErrMissingSnapshotName matches errors with the MissingSnapshotName reason code
+	ErrMissingSnapshotName = Message{Code: MissingSnapshotName}
+
+	// ErrMissingSourceVolumeID matches errors with the MissingSourceVolumeID reason code
+	ErrMissingSourceVolumeID = Message{Code: MissingSourceVolumeID}
+
+	// ErrVolumeAlreadyExists matches errors with the VolumeAlreadyExists reason code
+	ErrVolumeAlreadyExists = Message{Code: VolumeAlreadyExists}
+
+	// ErrSnapshotAlreadyExists matches errors with the SnapshotAlreadyExists reason code
+	ErrSnapshotAlreadyExists = Message{Code: SnapshotAlreadyExists}
+
+	// ErrEmptyVolumeID matches errors with the EmptyVolumeID reason code
+	ErrEmptyVolumeID = Message{Code: EmptyVolumeID}
+
+	// ErrEmptySnapshotID matches errors with the EmptySnapshotID reason code
+	ErrEmptySnapshotID = Message{Code: EmptySnapshotID}
+
+	// ErrUnsupportedVolumeContentSource matches errors with the UnsupportedVolumeContentSource reason code
+	ErrUnsupportedVolumeContentSource = Message{Code: UnsupportedVolumeContentSource}
+
+	// ErrEmptyVolumePath matches errors with the EmptyVolumePath reason code
+	ErrEmptyVolumePath = Message{Code: EmptyVolumePath}
+
+	// ErrEmptyNodeID matches errors with the EmptyNodeID reason code
+	ErrEmptyNodeID = Message{Code: EmptyNodeID}
+
+	// ErrEndpointNotReachable matches errors with the EndpointNotReachable reason code
+	ErrEndpointNotReachable = Message{Code: EndpointNotReachable}
+
+	// ErrTimeout matches errors with the Timeout reason code
+	ErrTimeout = Message{Code: Timeout}
+
+	// ErrVolumeInvalidArguments matches errors with the VolumeInvalidArguments reason code
+	ErrVolumeInvalidArguments = Message{Code: VolumeInvalidArguments}
+
+	// ErrVolumeCreationFailed matches errors with the VolumeCreationFailed reason code
+	ErrVolumeCreationFailed = Message{Code: VolumeCreationFailed}
+
+	// ErrNoVolumeCapabilities matches errors with the NoVolumeCapabilities reason code
+	ErrNoVolumeCapabilities = Message{Code: NoVolumeCapabilities}
+
+	// ErrVolumeCapabilitiesNotSupported matches errors with the VolumeCapabilitiesNotSupported reason code
+	ErrVolumeCapabilitiesNotSupported = Message{Code: VolumeCapabilitiesNotSupported}
+
+	// ErrInvalidParameters matches errors with the InvalidParameters reason code
+	ErrInvalidParameters = Message{Code: InvalidParameters}
+
+	// ErrInternalError matches errors with the InternalError reason code
+	ErrInternalError = Message{Code: InternalError}
+
+	// ErrObjectNotFound matches errors with the ObjectNotFound reason code
+	ErrObjectNotFound = Message{Code: ObjectNotFound}
+
+	// ErrProfileNotAllowlisted matches errors with the ProfileNotAllowlisted reason code
+	ErrProfileNotAllowlisted = Message{Code: ProfileNotAllowlisted}
+
+	// ErrFailedPrecondition matches errors with the FailedPrecondition reason code
+	ErrFailedPrecondition = Message{Code: FailedPrecondition}
+
+	// ErrNoStagingTargetPath matches errors with the NoStagingTargetPath reason code
+	ErrNoStagingTargetPath = Message{Code: NoStagingTargetPath}
+
+	// ErrNoTargetPath matches errors with the NoTargetPath reason code
+	ErrNoTargetPath = Message{Code: NoTargetPath}
+
+	// ErrMountPointValidateError matches errors with the MountPointValidateError reason code
+	ErrMountPointValidateError = Message{Code: MountPointValidateError}
+
+	// ErrUnmountFailed matches errors with the UnmountFailed reason code
+	ErrUnmountFailed = Message{Code: UnmountFailed}
+
+	// ErrMountFailed matches errors with the MountFailed reason code
+	ErrMountFailed = Message{Code: MountFailed}
+
+	// ErrEmptyDevicePath matches errors with the EmptyDevicePath reason code
+	ErrEmptyDevicePath = Message{Code: EmptyDevicePath}
+
+	// ErrDevicePathFindFailed matches errors with the DevicePathFindFailed reason code
+	ErrDevicePathFindFailed = Message{Code: DevicePathFindFailed}
+
+	// ErrDevicePathNotFound matches errors with the DevicePathNotFound reason code
+	ErrDevicePathNotFound = Message{Code: DevicePathNotFound}
+
+	// ErrTargetPathCheckFailed matches errors with the TargetPathCheckFailed reason code
+	ErrTargetPathCheckFailed = Message{Code: TargetPathCheckFailed}
+
+	// ErrTargetPathCreateFailed matches errors with the TargetPathCreateFailed reason code
+	ErrTargetPathCreateFailed = Message{Code: TargetPathCreateFailed}
+
+	// ErrVolumeMountCheckFailed matches errors with the VolumeMountCheckFailed reason code
+	ErrVolumeMountCheckFailed = Message{Code: VolumeMountCheckFailed}
+
+	// ErrFormatAndMountFailed matches errors with the FormatAndMountFailed reason code
+	ErrFormatAndMountFailed = Message{Code: FormatAndMountFailed}
+
+	// ErrNodeMetadataInitFailed matches errors with the NodeMetadataInitFailed reason code
+	ErrNodeMetadataInitFailed = Message{Code: NodeMetadataInitFailed}
+
+	// ErrDevicePathNotExists matches errors with the DevicePathNotExists reason code
+	ErrDevicePathNotExists = Message{Code: DevicePathNotExists}
+
+	// ErrBlockDeviceCheckFailed matches errors with the BlockDeviceCheckFailed reason code
+	ErrBlockDeviceCheckFailed = Message{Code: BlockDeviceCheckFailed}
+
+	// ErrGetDeviceInfoFailed matches errors with the GetDeviceInfoFailed reason code
+	ErrGetDeviceInfoFailed = Message{Code: GetDeviceInfoFailed}
+
+	// ErrGetFSInfoFailed matches errors with the GetFSInfoFailed reason code
+	ErrGetFSInfoFailed = Message{Code: GetFSInfoFailed}
+
+	// ErrDriverNotConfigured matches errors with the DriverNotConfigured reason code
+	ErrDriverNotConfigured = Message{Code: DriverNotConfigured}
+
+	// ErrRemoveMountTargetFailed matches errors with the RemoveMountTargetFailed reason code
+	ErrRemoveMountTargetFailed = Message{Code: RemoveMountTargetFailed}
+
+	// ErrCreateMountTargetFailed matches errors with the CreateMountTargetFailed reason code
+	ErrCreateMountTargetFailed = Message{Code: CreateMountTargetFailed}
+
+	// ErrMountingTargetFailed matches errors with the MountingTargetFailed reason code
+	ErrMountingTargetFailed = Message{Code: MountingTargetFailed}
+
+	// ErrUnresponsiveMountHelperContainerUtility matches errors with the UnresponsiveMountHelperContainerUtility reason code
+	ErrUnresponsiveMountHelperContainerUtility = Message{Code: UnresponsiveMountHelperContainerUtility}
+
+	// ErrMetadataServiceNotEnabled matches errors with the MetadataServiceNotEnabled reason code
+	ErrMetadataServiceNotEnabled = Message{Code: MetadataServiceNotEnabled}
+
+	// ErrListVolumesFailed matches errors with the ListVolumesFailed reason code
+	ErrListVolumesFailed = Message{Code: ListVolumesFailed}
+
+	// ErrListSnapshotsFailed matches errors with the ListSnapshotsFailed reason code
+	ErrListSnapshotsFailed = Message{Code: ListSnapshotsFailed}
+
+	// ErrStartVolumeIDNotFound matches errors with the StartVolumeIDNotFound reason code
+	ErrStartVolumeIDNotFound = Message{Code: StartVolumeIDNotFound}
+
+	// ErrStartSnapshotIDNotFound matches errors with the StartSnapshotIDNotFound reason code
+	ErrStartSnapshotIDNotFound = Message{Code: StartSnapshotIDNotFound}
+
+	// ErrFileSystemResizeFailed matches errors with the FileSystemResizeFailed reason code
+	ErrFileSystemResizeFailed = Message{Code: FileSystemResizeFailed}
+
+	// ErrVolumePathNotMounted matches errors with the VolumePathNotMounted reason code
+	ErrVolumePathNotMounted = Message{Code: VolumePathNotMounted}
+
+	// ErrSubnetIDListNotFound matches errors with the SubnetIDListNotFound reason code
+	ErrSubnetIDListNotFound = Message{Code: SubnetIDListNotFound}
+
+	// ErrSubnetFindFailed matches errors with the SubnetFindFailed reason code
+	ErrSubnetFindFailed = Message{Code: SubnetFindFailed}
+)
+
+// MethodUnimplementedMessage returns the MethodUnimplemented message
+func MethodUnimplementedMessage(arg0 string) Message {
+	return GetCSIMessage(MethodUnimplemented, arg0)
+}
+
+// MethodUnimplementedError logs and returns the MethodUnimplemented error wrapping err
+func MethodUnimplementedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, MethodUnimplemented, requestID, err, arg0)
+}
+
+// MethodUnsupportedMessage returns the MethodUnsupported message
+func MethodUnsupportedMessage(arg0 string) Message {
+	return GetCSIMessage(MethodUnsupported, arg0)
+}
+
+// MethodUnsupportedError logs and returns the MethodUnsupported error wrapping err
+func MethodUnsupportedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, MethodUnsupported, requestID, err, arg0)
+}
+
+// MissingVolumeNameMessage returns the MissingVolumeName message
+func MissingVolumeNameMessage() Message {
+	return GetCSIMessage(MissingVolumeName)
+}
+
+// MissingVolumeNameError logs and returns the MissingVolumeName error wrapping err
+func MissingVolumeNameError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, MissingVolumeName, requestID, err)
+}
+
+// MissingSnapshotNameMessage returns the MissingSnapshotName message
+func MissingSnapshotNameMessage() Message {
+	return GetCSIMessage(MissingSnapshotName)
+}
+
+// MissingSnapshotNameError logs and returns the MissingSnapshotName error wrapping err
+func MissingSnapshotNameError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, MissingSnapshotName, requestID, err)
+}
+
+// MissingSourceVolumeIDMessage returns the MissingSourceVolumeID message
+func MissingSourceVolumeIDMessage() Message {
+	return GetCSIMessage(MissingSourceVolumeID)
+}
+
+// MissingSourceVolumeIDError logs and returns the MissingSourceVolumeID error wrapping err
+func MissingSourceVolumeIDError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, MissingSourceVolumeID, requestID, err)
+}
+
+// VolumeAlreadyExistsMessage returns the VolumeAlreadyExists message
+func VolumeAlreadyExistsMessage(arg0 string, arg1 string) Message {
+	return GetCSIMessage(VolumeAlreadyExists, arg0, arg1)
+}
+
+// VolumeAlreadyExistsError logs and returns the VolumeAlreadyExists error wrapping err
+func VolumeAlreadyExistsError(logger *zap.Logger, requestID string, err error, arg0 string, arg1 string) error {
+	return GetCSIError(logger, VolumeAlreadyExists, requestID, err, arg0, arg1)
+}
+
+// SnapshotAlreadyExistsMessage returns the SnapshotAlreadyExists message
+func SnapshotAlreadyExistsMessage(arg0 string, arg1 string) Message {
+	return GetCSIMessage(SnapshotAlreadyExists, arg0, arg1)
+}
+
+// SnapshotAlreadyExistsError logs and returns the SnapshotAlreadyExists error wrapping err
+func SnapshotAlreadyExistsError(logger *zap.Logger, requestID string, err error, arg0 string, arg1 string) error {
+	return GetCSIError(logger, SnapshotAlreadyExists, requestID, err, arg0, arg1)
+}
+
+// EmptyVolumeIDMessage returns the EmptyVolumeID message
+func EmptyVolumeIDMessage() Message {
+	return GetCSIMessage(EmptyVolumeID)
+}
+
+// EmptyVolumeIDError logs and returns the EmptyVolumeID error wrapping err
+func EmptyVolumeIDError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, EmptyVolumeID, requestID, err)
+}
+
+// EmptySnapshotIDMessage returns the EmptySnapshotID message
+func EmptySnapshotIDMessage() Message {
+	return GetCSIMessage(EmptySnapshotID)
+}
+
+// EmptySnapshotIDError logs and returns the EmptySnapshotID error wrapping err
+func EmptySnapshotIDError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, EmptySnapshotID, requestID, err)
+}
+
+// UnsupportedVolumeContentSourceMessage returns the UnsupportedVolumeContentSource message
+func UnsupportedVolumeContentSourceMessage() Message {
+	return GetCSIMessage(UnsupportedVolumeContentSource)
+}
+
+// UnsupportedVolumeContentSourceError logs and returns the UnsupportedVolumeContentSource error wrapping err
+func UnsupportedVolumeContentSourceError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, UnsupportedVolumeContentSource, requestID, err)
+}
+
+// EmptyVolumePathMessage returns the EmptyVolumePath message
+func EmptyVolumePathMessage() Message {
+	return GetCSIMessage(EmptyVolumePath)
+}
+
+// EmptyVolumePathError logs and returns the EmptyVolumePath error wrapping err
+func EmptyVolumePathError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, EmptyVolumePath, requestID, err)
+}
+
+// EmptyNodeIDMessage returns the EmptyNodeID message
+func EmptyNodeIDMessage() Message {
+	return GetCSIMessage(EmptyNodeID)
+}
+
+// EmptyNodeIDError logs and returns the EmptyNodeID error wrapping err
+func EmptyNodeIDError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, EmptyNodeID, requestID, err)
+}
+
+// EndpointNotReachableMessage returns the EndpointNotReachable message
+func EndpointNotReachableMessage() Message {
+	return GetCSIMessage(EndpointNotReachable)
+}
+
+// EndpointNotReachableError logs and returns the EndpointNotReachable error wrapping err
+func EndpointNotReachableError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, EndpointNotReachable, requestID, err)
+}
+
+// TimeoutMessage returns the Timeout message
+func TimeoutMessage() Message {
+	return GetCSIMessage(Timeout)
+}
+
+// TimeoutError logs and returns the Timeout error wrapping err
+func TimeoutError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, Timeout, requestID, err)
+}
+
+// VolumeInvalidArgumentsMessage returns the VolumeInvalidArguments message
+func VolumeInvalidArgumentsMessage() Message {
+	return GetCSIMessage(VolumeInvalidArguments)
+}
+
+// VolumeInvalidArgumentsError logs and returns the VolumeInvalidArguments error wrapping err
+func VolumeInvalidArgumentsError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, VolumeInvalidArguments, requestID, err)
+}
+
+// VolumeCreationFailedMessage returns the VolumeCreationFailed message
+func VolumeCreationFailedMessage() Message {
+	return GetCSIMessage(VolumeCreationFailed)
+}
+
+// VolumeCreationFailedError logs and returns the VolumeCreationFailed error wrapping err
+func VolumeCreationFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, VolumeCreationFailed, requestID, err)
+}
+
+// NoVolumeCapabilitiesMessage returns the NoVolumeCapabilities message
+func NoVolumeCapabilitiesMessage() Message {
+	return GetCSIMessage(NoVolumeCapabilities)
+}
+
+// NoVolumeCapabilitiesError logs and returns the NoVolumeCapabilities error wrapping err
+func NoVolumeCapabilitiesError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, NoVolumeCapabilities, requestID, err)
+}
+
+// VolumeCapabilitiesNotSupportedMessage returns the VolumeCapabilitiesNotSupported message
+func VolumeCapabilitiesNotSupportedMessage() Message {
+	return GetCSIMessage(VolumeCapabilitiesNotSupported)
+}
+
+// VolumeCapabilitiesNotSupportedError logs and returns the VolumeCapabilitiesNotSupported error wrapping err
+func VolumeCapabilitiesNotSupportedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, VolumeCapabilitiesNotSupported, requestID, err)
+}
+
+// InvalidParametersMessage returns the InvalidParameters message
+func InvalidParametersMessage() Message {
+	return GetCSIMessage(InvalidParameters)
+}
+
+// InvalidParametersError logs and returns the InvalidParameters error wrapping err
+func InvalidParametersError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, InvalidParameters, requestID, err)
+}
+
+// InternalErrorMessage returns the InternalError message
+func InternalErrorMessage() Message {
+	return GetCSIMessage(InternalError)
+}
+
+// InternalErrorError logs and returns the InternalError error wrapping err
+func InternalErrorError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, InternalError, requestID, err)
+}
+
+// ObjectNotFoundMessage returns the ObjectNotFound message
+func ObjectNotFoundMessage() Message {
+	return GetCSIMessage(ObjectNotFound)
+}
+
+// ObjectNotFoundError logs and returns the ObjectNotFound error wrapping err
+func ObjectNotFoundError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, ObjectNotFound, requestID, err)
+}
+
+// ProfileNotAllowlistedMessage returns the ProfileNotAllowlisted message
+func ProfileNotAllowlistedMessage(arg0 string) Message {
+	return GetCSIMessage(ProfileNotAllowlisted, arg0)
+}
+
+// ProfileNotAllowlistedError logs and returns the ProfileNotAllowlisted error wrapping err
+func ProfileNotAllowlistedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, ProfileNotAllowlisted, requestID, err, arg0)
+}
+
+// FailedPreconditionMessage returns the FailedPrecondition message
+func FailedPreconditionMessage() Message {
+	return GetCSIMessage(FailedPrecondition)
+}
+
+// FailedPreconditionError logs and returns the FailedPrecondition error wrapping err
+func FailedPreconditionError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, FailedPrecondition, requestID, err)
+}
+
+// NoStagingTargetPathMessage returns the NoStagingTargetPath message
+func NoStagingTargetPathMessage() Message {
+	return GetCSIMessage(NoStagingTargetPath)
+}
+
+// NoStagingTargetPathError logs and returns the NoStagingTargetPath error wrapping err
+func NoStagingTargetPathError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, NoStagingTargetPath, requestID, err)
+}
+
+// NoTargetPathMessage returns the NoTargetPath message
+func NoTargetPathMessage() Message {
+	return GetCSIMessage(NoTargetPath)
+}
+
+// NoTargetPathError logs and returns the NoTargetPath error wrapping err
+func NoTargetPathError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, NoTargetPath, requestID, err)
+}
+
+// MountPointValidateErrorMessage returns the MountPointValidateError message
+func MountPointValidateErrorMessage(arg0 string) Message {
+	return GetCSIMessage(MountPointValidateError, arg0)
+}
+
+// MountPointValidateErrorError logs and returns the MountPointValidateError error wrapping err
+func MountPointValidateErrorError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, MountPointValidateError, requestID, err, arg0)
+}
+
+// UnmountFailedMessage returns the UnmountFailed message
+func UnmountFailedMessage(arg0 string) Message {
+	return GetCSIMessage(UnmountFailed, arg0)
+}
+
+// UnmountFailedError logs and returns the UnmountFailed error wrapping err
+func UnmountFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, UnmountFailed, requestID, err, arg0)
+}
+
+// MountFailedMessage returns the MountFailed message
+func MountFailedMessage(arg0 string, arg1 string) Message {
+	return GetCSIMessage(MountFailed, arg0, arg1)
+}
+
+// MountFailedError logs and returns the MountFailed error wrapping err
+func MountFailedError(logger *zap.Logger, requestID string, err error, arg0 string, arg1 string) error {
+	return GetCSIError(logger, MountFailed, requestID, err, arg0, arg1)
+}
+
+// EmptyDevicePathMessage returns the EmptyDevicePath message
+func EmptyDevicePathMessage() Message {
+	return GetCSIMessage(EmptyDevicePath)
+}
+
+// EmptyDevicePathError logs and returns the EmptyDevicePath error wrapping err
+func EmptyDevicePathError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, EmptyDevicePath, requestID, err)
+}
+
+// DevicePathFindFailedMessage returns the DevicePathFindFailed message
+func DevicePathFindFailedMessage(arg0 string) Message {
+	return GetCSIMessage(DevicePathFindFailed, arg0)
+}
+
+// DevicePathFindFailedError logs and returns the DevicePathFindFailed error wrapping err
+func DevicePathFindFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, DevicePathFindFailed, requestID, err, arg0)
+}
+
+// DevicePathNotFoundMessage returns the DevicePathNotFound message
+func DevicePathNotFoundMessage(arg0 string) Message {
+	return GetCSIMessage(DevicePathNotFound, arg0)
+}
+
+// DevicePathNotFoundError logs and returns the DevicePathNotFound error wrapping err
+func DevicePathNotFoundError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, DevicePathNotFound, requestID, err, arg0)
+}
+
+// TargetPathCheckFailedMessage returns the TargetPathCheckFailed message
+func TargetPathCheckFailedMessage(arg0 string) Message {
+	return GetCSIMessage(TargetPathCheckFailed, arg0)
+}
+
+// TargetPathCheckFailedError logs and returns the TargetPathCheckFailed error wrapping err
+func TargetPathCheckFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, TargetPathCheckFailed, requestID, err, arg0)
+}
+
+// TargetPathCreateFailedMessage returns the TargetPathCreateFailed message
+func TargetPathCreateFailedMessage(arg0 string) Message {
+	return GetCSIMessage(TargetPathCreateFailed, arg0)
+}
+
+// TargetPathCreateFailedError logs and returns the TargetPathCreateFailed error wrapping err
+func TargetPathCreateFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, TargetPathCreateFailed, requestID, err, arg0)
+}
+
+// VolumeMountCheckFailedMessage returns the VolumeMountCheckFailed message
+func VolumeMountCheckFailedMessage(arg0 string) Message {
+	return GetCSIMessage(VolumeMountCheckFailed, arg0)
+}
+
+// VolumeMountCheckFailedError logs and returns the VolumeMountCheckFailed error wrapping err
+func VolumeMountCheckFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, VolumeMountCheckFailed, requestID, err, arg0)
+}
+
+// FormatAndMountFailedMessage returns the FormatAndMountFailed message
+func FormatAndMountFailedMessage(arg0 string, arg1 string) Message {
+	return GetCSIMessage(FormatAndMountFailed, arg0, arg1)
+}
+
+// FormatAndMountFailedError logs and returns the FormatAndMountFailed error wrapping err
+func FormatAndMountFailedError(logger *zap.Logger, requestID string, err error, arg0 string, arg1 string) error {
+	return GetCSIError(logger, FormatAndMountFailed, requestID, err, arg0, arg1)
+}
+
+// NodeMetadataInitFailedMessage returns the NodeMetadataInitFailed message
+func NodeMetadataInitFailedMessage() Message {
+	return GetCSIMessage(NodeMetadataInitFailed)
+}
+
+// NodeMetadataInitFailedError logs and returns the NodeMetadataInitFailed error wrapping err
+func NodeMetadataInitFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, NodeMetadataInitFailed, requestID, err)
+}
+
+// DevicePathNotExistsMessage returns the DevicePathNotExists message
+func DevicePathNotExistsMessage(arg0 string, arg1 string) Message {
+	return GetCSIMessage(DevicePathNotExists, arg0, arg1)
+}
+
+// DevicePathNotExistsError logs and returns the DevicePathNotExists error wrapping err
+func DevicePathNotExistsError(logger *zap.Logger, requestID string, err error, arg0 string, arg1 string) error {
+	return GetCSIError(logger, DevicePathNotExists, requestID, err, arg0, arg1)
+}
+
+// BlockDeviceCheckFailedMessage returns the BlockDeviceCheckFailed message
+func BlockDeviceCheckFailedMessage(arg0 string) Message {
+	return GetCSIMessage(BlockDeviceCheckFailed, arg0)
+}
+
+// BlockDeviceCheckFailedError logs and returns the BlockDeviceCheckFailed error wrapping err
+func BlockDeviceCheckFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, BlockDeviceCheckFailed, requestID, err, arg0)
+}
+
+// GetDeviceInfoFailedMessage returns the GetDeviceInfoFailed message
+func GetDeviceInfoFailedMessage() Message {
+	return GetCSIMessage(GetDeviceInfoFailed)
+}
+
+// GetDeviceInfoFailedError logs and returns the GetDeviceInfoFailed error wrapping err
+func GetDeviceInfoFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, GetDeviceInfoFailed, requestID, err)
+}
+
+// GetFSInfoFailedMessage returns the GetFSInfoFailed message
+func GetFSInfoFailedMessage() Message {
+	return GetCSIMessage(GetFSInfoFailed)
+}
+
+// GetFSInfoFailedError logs and returns the GetFSInfoFailed error wrapping err
+func GetFSInfoFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, GetFSInfoFailed, requestID, err)
+}
+
+// DriverNotConfiguredMessage returns the DriverNotConfigured message
+func DriverNotConfiguredMessage() Message {
+	return GetCSIMessage(DriverNotConfigured)
+}
+
+// DriverNotConfiguredError logs and returns the DriverNotConfigured error wrapping err
+func DriverNotConfiguredError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, DriverNotConfigured, requestID, err)
+}
+
+// RemoveMountTargetFailedMessage returns the RemoveMountTargetFailed message
+func RemoveMountTargetFailedMessage(arg0 string) Message {
+	return GetCSIMessage(RemoveMountTargetFailed, arg0)
+}
+
+// RemoveMountTargetFailedError logs and returns the RemoveMountTargetFailed error wrapping err
+func RemoveMountTargetFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, RemoveMountTargetFailed, requestID, err, arg0)
+}
+
+// CreateMountTargetFailedMessage returns the CreateMountTargetFailed message
+func CreateMountTargetFailedMessage(arg0 string) Message {
+	return GetCSIMessage(CreateMountTargetFailed, arg0)
+}
+
+// CreateMountTargetFailedError logs and returns the CreateMountTargetFailed error wrapping err
+func CreateMountTargetFailedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, CreateMountTargetFailed, requestID, err, arg0)
+}
+
+// MountingTargetFailedMessage returns the MountingTargetFailed message
+func MountingTargetFailedMessage() Message {
+	return GetCSIMessage(MountingTargetFailed)
+}
+
+// MountingTargetFailedError logs and returns the MountingTargetFailed error wrapping err
+func MountingTargetFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, MountingTargetFailed, requestID, err)
+}
+
+// UnresponsiveMountHelperContainerUtilityMessage returns the UnresponsiveMountHelperContainerUtility message
+func UnresponsiveMountHelperContainerUtilityMessage() Message {
+	return GetCSIMessage(UnresponsiveMountHelperContainerUtility)
+}
+
+// UnresponsiveMountHelperContainerUtilityError logs and returns the UnresponsiveMountHelperContainerUtility error wrapping err
+func UnresponsiveMountHelperContainerUtilityError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, UnresponsiveMountHelperContainerUtility, requestID, err)
+}
+
+// MetadataServiceNotEnabledMessage returns the MetadataServiceNotEnabled message
+func MetadataServiceNotEnabledMessage() Message {
+	return GetCSIMessage(MetadataServiceNotEnabled)
+}
+
+// MetadataServiceNotEnabledError logs and returns the MetadataServiceNotEnabled error wrapping err
+func MetadataServiceNotEnabledError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, MetadataServiceNotEnabled, requestID, err)
+}
+
+// ListVolumesFailedMessage returns the ListVolumesFailed message
+func ListVolumesFailedMessage() Message {
+	return GetCSIMessage(ListVolumesFailed)
+}
+
+// ListVolumesFailedError logs and returns the ListVolumesFailed error wrapping err
+func ListVolumesFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, ListVolumesFailed, requestID, err)
+}
+
+// ListSnapshotsFailedMessage returns the ListSnapshotsFailed message
+func ListSnapshotsFailedMessage() Message {
+	return GetCSIMessage(ListSnapshotsFailed)
+}
+
+// ListSnapshotsFailedError logs and returns the ListSnapshotsFailed error wrapping err
+func ListSnapshotsFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, ListSnapshotsFailed, requestID, err)
+}
+
+// StartVolumeIDNotFoundMessage returns the StartVolumeIDNotFound message
+func StartVolumeIDNotFoundMessage(arg0 string) Message {
+	return GetCSIMessage(StartVolumeIDNotFound, arg0)
+}
+
+// StartVolumeIDNotFoundError logs and returns the StartVolumeIDNotFound error wrapping err
+func StartVolumeIDNotFoundError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, StartVolumeIDNotFound, requestID, err, arg0)
+}
+
+// StartSnapshotIDNotFoundMessage returns the StartSnapshotIDNotFound message
+func StartSnapshotIDNotFoundMessage(arg0 string) Message {
+	return GetCSIMessage(StartSnapshotIDNotFound, arg0)
+}
+
+// StartSnapshotIDNotFoundError logs and returns the StartSnapshotIDNotFound error wrapping err
+func StartSnapshotIDNotFoundError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, StartSnapshotIDNotFound, requestID, err, arg0)
+}
+
+// FileSystemResizeFailedMessage returns the FileSystemResizeFailed message
+func FileSystemResizeFailedMessage() Message {
+	return GetCSIMessage(FileSystemResizeFailed)
+}
+
+// FileSystemResizeFailedError logs and returns the FileSystemResizeFailed error wrapping err
+func FileSystemResizeFailedError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, FileSystemResizeFailed, requestID, err)
+}
+
+// VolumePathNotMountedMessage returns the VolumePathNotMounted message
+func VolumePathNotMountedMessage(arg0 string) Message {
+	return GetCSIMessage(VolumePathNotMounted, arg0)
+}
+
+// VolumePathNotMountedError logs and returns the VolumePathNotMounted error wrapping err
+func VolumePathNotMountedError(logger *zap.Logger, requestID string, err error, arg0 string) error {
+	return GetCSIError(logger, VolumePathNotMounted, requestID, err, arg0)
+}
+
+// SubnetIDListNotFoundMessage returns the SubnetIDListNotFound message
+func SubnetIDListNotFoundMessage() Message {
+	return GetCSIMessage(SubnetIDListNotFound)
+}
+
+// SubnetIDListNotFoundError logs and returns the SubnetIDListNotFound error wrapping err
+func SubnetIDListNotFoundError(logger *zap.Logger, requestID string, err error) error {
+	return GetCSIError(logger, SubnetIDListNotFound, requestID, err)
+}
+
+// SubnetFindFailedMessage returns the SubnetFindFailed message
+func SubnetFindFailedMessage(arg0 string, arg1 string) Message {
+	return GetCSIMessage(SubnetFindFailed, arg0, arg1)
+}
+
+// SubnetFindFailedError logs and returns the SubnetFindFailed error wrapping err
+func SubnetFindFailedError(logger *zap.Logger, requestID string, err error, arg0 string, arg1 string) error {
+	return GetCSIError(logger, SubnetFindFailed, requestID, err, arg0, arg1)
+}
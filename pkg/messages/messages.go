@@ -19,7 +19,6 @@ package messages
 
 import (
 	"fmt"
-	"strings"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
@@ -35,11 +34,16 @@ type Message struct {
 	BackendError string
 	CSIError     string
 	Action       string
+
+	// err is the error the message was created from
+	err error
 }
 
-// Error Implement the Error() interface method
+// Error returns the text of the gRPC status error of the message, e.g.
+// "rpc error: code = InvalidArgument desc = {RequestID: ...}", which is what
+// GetCSIError returned before it returned a Message
 func (msg Message) Error() string {
-	return msg.Info()
+	return status.New(msg.Type, msg.Info()).String()
 }
 
 // Info ...
@@ -66,16 +70,18 @@ func (msg Message) Info() string {
 // MessagesEn ...
 var MessagesEn map[string]Message
 
-// GetCSIError ...
+// GetCSIError returns the Message of code wrapping err. The error converts to
+// a gRPC status carrying the reason code, see Message.GRPCStatus.
 func GetCSIError(logger *zap.Logger, code string, requestID string, err error, args ...interface{}) error {
 	userMsg := GetCSIMessage(code, args...)
 	if err != nil {
 		userMsg.CSIError = err.Error()
+		userMsg.err = err
 	}
 	userMsg.RequestID = requestID
 
 	logger.Error("FAILED CSI ERROR", zap.Error(userMsg))
-	return status.Error(userMsg.Type, userMsg.Info())
+	return userMsg
 }
 
 // Populate backendError from library and based on RC:xxx code set the CSI return code.
@@ -87,24 +93,37 @@ func GetCSIBackendError(logger *zap.Logger, requestID string, err error, args ..
 	if err != nil {
 		backendError = err.Error()
 	}
-	// Based on RC:xxx code from library set the appropriate CSI return code.
-	// We will consider two generic codes 5xx server side issue and 4xx client side issue.
-	if strings.Contains(strings.Replace(backendError, " ", "", -1), RC5XX) {
+	// The reason code tells 5xx server side issues from client side issues,
+	// the gRPC code follows the RC and code of the library, see BackendErrorCode.
+	code := BackendErrorCode(err)
+	if isServerSideCode(code) {
 		userMsg = GetCSIMessage(InternalError, args...)
 	} else {
 		userMsg = GetCSIMessage(InvalidParameters, args...)
 	}
+	userMsg.Type = code
 
 	userMsg.RequestID = requestID
 	userMsg.BackendError = backendError
+	userMsg.err = err
 
 	logger.Error("FAILED BACKEND ERROR", zap.Error(userMsg))
-	return status.Error(userMsg.Type, userMsg.Info())
+	return userMsg
 }
 
-// GetCSIMessage ...
+// GetCSIMessage returns the message of code in the locale selected by
+// SetLocale, or from MessagesEn if no locale was selected. Prefer the typed
+// helpers of helpers_gen.go, e.g. VolumeAlreadyExistsMessage, which take the
+// right number of arguments.
 func GetCSIMessage(code string, args ...interface{}) Message {
-	userMsg := MessagesEn[code]
+	userMsg, ok := localizedCSIMessage(code)
+	if !ok {
+		userMsg, ok = MessagesEn[code]
+	}
+	if !ok {
+		// Never report an unknown reason code as a success
+		return Message{Code: code, Type: codes.Internal, Description: code}
+	}
 	if len(args) > 0 {
 		userMsg.Description = fmt.Sprintf(userMsg.Description, args...)
 	}
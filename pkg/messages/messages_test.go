@@ -23,6 +23,7 @@ import (
 	"github.com/IBM/ibm-csi-common/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/status"
 )
 
 func TestGetCSIError(t *testing.T) {
@@ -34,6 +35,11 @@ func TestGetCSIError(t *testing.T) {
 
 	err1 := GetCSIError(ctxLog, MethodUnimplemented, reqID, err)
 	assert.NotNil(t, err1)
+
+	// The error text is that of the gRPC status error
+	message := err1.(Message)
+	assert.Equal(t, status.Error(message.Type, message.Info()).Error(), err1.Error())
+	assert.Equal(t, status.Convert(err1).Err().Error(), err1.Error())
 }
 
 func TestGetCSIMessage(t *testing.T) {
@@ -0,0 +1,81 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+//go:generate go run ./gen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+func init() {
+	if err := validateCatalog(reasonCodes, messagesEn); err != nil {
+		panic(err)
+	}
+}
+
+// validateMessage returns an error if msg cannot be returned to users
+func validateMessage(code string, msg Message) error {
+	switch {
+	case msg.Code == "":
+		return fmt.Errorf("message %s has no code", code)
+	case msg.Description == "":
+		return fmt.Errorf("message %s has no description", code)
+	case msg.Type == codes.OK:
+		return fmt.Errorf("message %s has the OK gRPC code", code)
+	}
+	return nil
+}
+
+// validateCatalog returns an error listing the reason codes without a valid
+// message in messages
+func validateCatalog(reasonCodes []string, messages map[string]Message) error {
+	var problems []string
+	for _, code := range reasonCodes {
+		msg, ok := messages[code]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("reason code %s has no message", code))
+			continue
+		}
+		if err := validateMessage(code, msg); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid message catalogue: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Register adds the English message of a reason code defined outside this
+// package, e.g. by a driver. It must be called during initialisation, before
+// SetLocale, and panics if msg is invalid or its code already registered.
+func Register(msg Message) {
+	if err := validateMessage(msg.Code, msg); err != nil {
+		panic(err)
+	}
+	if _, ok := messagesEn[msg.Code]; ok {
+		panic(fmt.Sprintf("message %s is already registered", msg.Code))
+	}
+	messagesEn[msg.Code] = msg
+}
@@ -0,0 +1,79 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package messages ...
+package messages
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateCatalog(t *testing.T) {
+	assert.Nil(t, validateCatalog(reasonCodes, messagesEn))
+
+	messages := map[string]Message{
+		EmptyVolumeID: messagesEn[EmptyVolumeID],
+		EmptyNodeID:   {Code: EmptyNodeID, Description: "NodeID is empty"},
+	}
+	err := validateCatalog([]string{EmptyVolumeID, EmptyNodeID, EmptySnapshotID}, messages)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "reason code EmptySnapshotID has no message")
+	assert.Contains(t, err.Error(), "message EmptyNodeID has the OK gRPC code")
+	assert.NotContains(t, err.Error(), "EmptyVolumeID")
+}
+
+func TestRegister(t *testing.T) {
+	msg := Message{Code: "DriverSpecificFailure", Description: "Driver failed on '%s'", Type: codes.Aborted, Action: "Please retry"}
+	Register(msg)
+	t.Cleanup(func() { delete(messagesEn, msg.Code) })
+
+	MessagesEn = InitMessages()
+	assert.Equal(t, "Driver failed on 'vol-1'", GetCSIMessage(msg.Code, "vol-1").Description)
+
+	assert.Panics(t, func() { Register(msg) })
+	assert.Panics(t, func() { Register(Message{Code: "NoType", Description: "No type"}) })
+	assert.Panics(t, func() { Register(Message{Description: "No code", Type: codes.Internal}) })
+}
+
+func TestGetCSIMessageUnknownCode(t *testing.T) {
+	MessagesEn = InitMessages()
+	msg := GetCSIMessage("UnknownReasonCode")
+	assert.Equal(t, "UnknownReasonCode", msg.Code)
+	assert.Equal(t, codes.Internal, msg.Type)
+}
+
+func TestTypedHelpers(t *testing.T) {
+	MessagesEn = InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+
+	msg := VolumeAlreadyExistsMessage("pvc-1", "10")
+	assert.Equal(t, GetCSIMessage(VolumeAlreadyExists, "pvc-1", "10"), msg)
+	assert.NotContains(t, msg.Description, "%!")
+
+	err := FormatAndMountFailedError(ctxLog, reqID, errors.New("mkfs failed"), "/dev/vdb", "/mnt")
+	assert.True(t, errors.Is(err, ErrFormatAndMountFailed))
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Contains(t, err.Error(), "Failed to format '/dev/vdb' and mount it at '/mnt'")
+
+	assert.Equal(t, messagesEn[EmptyVolumeID].Description, EmptyVolumeIDMessage().Description)
+}
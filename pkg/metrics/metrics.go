@@ -18,102 +18,516 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
+	"github.com/IBM/ibm-csi-common/pkg/messages"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/status"
 )
 
 // FunctionLabel is a name of CSI plugin operation for which
 // we measure duration
 type FunctionLabel string
 
-var pluginNamespace string
-
-var (
-	/**** Metrics related to controller ****/
-	volumesCount = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: pluginNamespace,
-			Name:      "volumes_count",
-			Help:      "Total Number of volumes in the cluster.",
-		},
-	)
-
-	volumesAttachedCount = prometheus.NewGauge(
-		prometheus.GaugeOpts{
-			Namespace: pluginNamespace,
-			Name:      "volumes_attached_count",
-			Help:      "Total Number of volumes attached in the cluster ",
-		},
-	)
-	functionDuration = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: pluginNamespace,
-			Name:      "function_duration_seconds",
-			Help:      "Time taken by various operation of Plugin",
-		}, []string{"function"},
-	)
-	functionCount = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: pluginNamespace,
-			Name:      "functions_total",
-			Help:      "The number of plugin operation  completeted successfully.",
-		}, []string{"function"},
-	)
-
-	errorsCount = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: pluginNamespace,
-			Name:      "errors_total",
-			Help:      "The number of plugin operation  failed due to an error.",
-		}, []string{"type"},
-	)
+const (
+	// StatusUnspecified is the code label of durations recorded through the
+	// deprecated UpdateDuration, which does not know the outcome
+	StatusUnspecified = "Unspecified"
+
+	// ReasonRC5XX labels backend errors with a 5xx return code
+	ReasonRC5XX = "RC5XX"
+
+	// ReasonRC4XX labels backend errors with any other return code
+	ReasonRC4XX = "RC4XX"
+
+	// ReasonUnknown labels errors without a reason code
+	ReasonUnknown = "Unknown"
+
+	// ReasonNone is the reason code of a nil error
+	ReasonNone = "None"
 )
 
-// RegisterAll registers all metrics.
+// Metrics holds the collectors of a CSI driver, named under the driver
+// namespace. Drivers sharing a process, e.g. block, file and object, each
+// create their own Metrics with a distinct namespace.
+type Metrics struct {
+	namespace string
+	gatherer  prometheus.Gatherer
+
+	volumes           *volumeCollector
+	operationDuration *prometheus.HistogramVec
+	operationErrors   *prometheus.CounterVec
+	functionDuration  *prometheus.GaugeVec
+	functionCount     *prometheus.CounterVec
+	errorsCount       *prometheus.CounterVec
+	lockWaitDuration  *prometheus.HistogramVec
+	lockHoldDuration  *prometheus.HistogramVec
+	locksHeld         *prometheus.GaugeVec
+	retries           *prometheus.CounterVec
+	retriesExhausted  *prometheus.CounterVec
+	configReloads     *prometheus.CounterVec
+	configLastReload  *prometheus.GaugeVec
+}
+
+// New creates the collectors of a driver under namespace and registers them
+// with registerer, or leaves them unregistered if registerer is nil.
+// Characters not allowed in metric names, e.g. in a driver name such as
+// vpc-block-csi-driver, are replaced by underscores in namespace.
+// Creating Metrics twice for the same namespace and registerer shares the
+// collectors registered first instead of failing.
+func New(namespace string, registerer prometheus.Registerer) (*Metrics, error) {
+	m := newMetrics(namespace)
+	if registerer == nil {
+		return m, nil
+	}
+	if gatherer, ok := registerer.(prometheus.Gatherer); ok {
+		m.gatherer = gatherer
+	}
+	var err error
+	if m.volumes, err = register(registerer, m.volumes); err != nil {
+		return nil, err
+	}
+	if m.operationDuration, err = register(registerer, m.operationDuration); err != nil {
+		return nil, err
+	}
+	if m.operationErrors, err = register(registerer, m.operationErrors); err != nil {
+		return nil, err
+	}
+	if m.functionDuration, err = register(registerer, m.functionDuration); err != nil {
+		return nil, err
+	}
+	if m.functionCount, err = register(registerer, m.functionCount); err != nil {
+		return nil, err
+	}
+	if m.errorsCount, err = register(registerer, m.errorsCount); err != nil {
+		return nil, err
+	}
+	if m.lockWaitDuration, err = register(registerer, m.lockWaitDuration); err != nil {
+		return nil, err
+	}
+	if m.lockHoldDuration, err = register(registerer, m.lockHoldDuration); err != nil {
+		return nil, err
+	}
+	if m.locksHeld, err = register(registerer, m.locksHeld); err != nil {
+		return nil, err
+	}
+	if m.retries, err = register(registerer, m.retries); err != nil {
+		return nil, err
+	}
+	if m.retriesExhausted, err = register(registerer, m.retriesExhausted); err != nil {
+		return nil, err
+	}
+	if m.configReloads, err = register(registerer, m.configReloads); err != nil {
+		return nil, err
+	}
+	if m.configLastReload, err = register(registerer, m.configLastReload); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MustNew is like New but panics if the collectors cannot be registered
+func MustNew(namespace string, registerer prometheus.Registerer) *Metrics {
+	m, err := New(namespace, registerer)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// register registers collector, or returns the identical collector that is
+// already registered
+func register[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	err := registerer.Register(collector)
+	if err == nil {
+		return collector, nil
+	}
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return collector, err
+}
+
+// namespaceInvalidChars matches characters not allowed in metric names
+var namespaceInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func newMetrics(namespace string) *Metrics {
+	namespace = namespaceInvalidChars.ReplaceAllString(namespace, "_")
+	if namespace != "" && namespace[0] >= '0' && namespace[0] <= '9' {
+		namespace = "_" + namespace
+	}
+	return &Metrics{
+		namespace: namespace,
+
+		/**** Metrics related to controller ****/
+		volumes: newVolumeCollector(namespace),
+
+		operationDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "operation_duration_seconds",
+				Help:      "Time taken by various operation of Plugin, by gRPC status code.",
+				Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15, 30, 60, 120, 300, 600},
+			}, []string{"function", "code"},
+		),
+
+		operationErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "operation_errors_total",
+				Help:      "The number of plugin operation failed, by reason code.",
+			}, []string{"function", "reason"},
+		),
+
+		// Deprecated: use operationDuration, a gauge only keeps the last value
+		functionDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "function_duration_seconds",
+				Help:      "Time taken by various operation of Plugin. Deprecated: use operation_duration_seconds.",
+			}, []string{"function"},
+		),
+		functionCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "functions_total",
+				Help:      "The number of plugin operation  completeted successfully.",
+			}, []string{"function"},
+		),
+
+		// Deprecated: use operationErrors
+		errorsCount: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "errors_total",
+				Help:      "The number of plugin operation  failed due to an error, by reason code. Deprecated: use operation_errors_total.",
+			}, []string{"type"},
+		),
+
+		/**** Metrics related to lock contention ****/
+		lockWaitDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "lock_wait_duration_seconds",
+				Help:      "Time spent waiting to acquire a named lock.",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+			}, []string{"store"},
+		),
+
+		lockHoldDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "lock_hold_duration_seconds",
+				Help:      "Time a named lock was held before being released.",
+				Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+			}, []string{"store"},
+		),
+
+		locksHeld: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "locks_held",
+				Help:      "The number of named locks currently held.",
+			}, []string{"store"},
+		),
+
+		/**** Metrics related to retries ****/
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "retries_total",
+				Help:      "The number of retried plugin operation attempts, by reason code of the failed attempt.",
+			}, []string{"function", "reason"},
+		),
+
+		retriesExhausted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "retries_exhausted_total",
+				Help:      "The number of plugin operation which failed after all retries.",
+			}, []string{"function"},
+		),
+
+		/**** Metrics related to configuration reloads ****/
+		configReloads: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "config_reloads_total",
+				Help:      "The number of provider configuration reloads, by source and result.",
+			}, []string{"source", "result"},
+		),
+
+		configLastReload: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "config_last_reload_success_timestamp_seconds",
+				Help:      "Time of the last successful provider configuration reload, by source.",
+			}, []string{"source"},
+		),
+	}
+}
+
+// Namespace returns the namespace of the metric names
+func (m *Metrics) Namespace() string {
+	return m.namespace
+}
+
+// Handler serves the metrics gathered from the registerer passed to New. It
+// returns an error if that registerer is not also a prometheus.Gatherer; use
+// the package level Handler with the matching gatherer then.
+func (m *Metrics) Handler() (http.Handler, error) {
+	if m.gatherer == nil {
+		return nil, fmt.Errorf("metrics of namespace %s were not registered with a prometheus.Gatherer", m.namespace)
+	}
+	return Handler(m.gatherer), nil
+}
+
+// Handler serves the metrics of gatherer in the Prometheus exposition format
+func Handler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+// UpdateVolumeCount records number of volumes currently present in the
+// cluster, unless a volume inventory is registered
+func (m *Metrics) UpdateVolumeCount(podsCount int) {
+	m.volumes.volumes.Set(float64(podsCount))
+}
+
+// UpdateVolumeAttachedCount records number of volumes currently attached in
+// the cluster, unless a volume inventory is registered
+func (m *Metrics) UpdateVolumeAttachedCount(podsCount int) {
+	m.volumes.attached.Set(float64(podsCount))
+}
+
+// ObserveOperation records the duration of the operation identified by the
+// label, started at start, under the gRPC status code of err. Failed
+// operations are also counted by the reason code of err.
+func (m *Metrics) ObserveOperation(label FunctionLabel, start time.Time, err error) {
+	duration := time.Since(start)
+	m.operationDuration.WithLabelValues(string(label), status.Code(err).String()).Observe(duration.Seconds())
+	if err != nil {
+		m.operationErrors.WithLabelValues(string(label), ReasonCode(err)).Inc()
+	}
+}
+
+// ObserveOperationFromStart logs and records the outcome of the operation
+// identified by the label, see ObserveOperation
+func (m *Metrics) ObserveOperationFromStart(logger *zap.Logger, label FunctionLabel, start time.Time, err error) {
+	logger.Info("Time to complete", zap.Float64(string(label), time.Since(start).Seconds()), zap.Stringer("code", status.Code(err)))
+	m.ObserveOperation(label, start, err)
+}
+
+// UpdateDurationFromStart records the duration of the step identified by the
+// label using start time
+//
+// Deprecated: use ObserveOperationFromStart which also records the outcome
+func (m *Metrics) UpdateDurationFromStart(logger *zap.Logger, label FunctionLabel, start time.Time) {
+	duration := time.Since(start)
+	logger.Info("Time to complete", zap.Float64(string(label), duration.Seconds()))
+	m.UpdateDuration(label, duration)
+}
+
+// UpdateDuration records the duration of the step identified by the label
+//
+// Deprecated: use ObserveOperation. The duration is recorded with the
+// StatusUnspecified code.
+func (m *Metrics) UpdateDuration(label FunctionLabel, duration time.Duration) {
+	m.functionDuration.WithLabelValues(string(label)).Set(duration.Seconds())
+	m.operationDuration.WithLabelValues(string(label), StatusUnspecified).Observe(duration.Seconds())
+}
+
+// RegisterError records any errors for any plugin operation. The error is
+// counted under its reason code, or under errType if err is nil.
+//
+// Deprecated: use ObserveOperation
+func (m *Metrics) RegisterError(errType string, err error) {
+	if err != nil {
+		errType = ReasonCode(err)
+	}
+	m.errorsCount.WithLabelValues(errType).Add(1.0)
+}
+
+// RegisterFunction records any errors for any plugin operation.
+func (m *Metrics) RegisterFunction(label FunctionLabel) {
+	m.functionCount.WithLabelValues(string(label)).Add(1.0)
+}
+
+// RegisterRetry records that the operation identified by the label is
+// retried after err
+func (m *Metrics) RegisterRetry(label FunctionLabel, err error) {
+	m.retries.WithLabelValues(string(label), ReasonCode(err)).Inc()
+}
+
+// RegisterRetriesExhausted records that the operation identified by the label
+// failed after all retries
+func (m *Metrics) RegisterRetriesExhausted(label FunctionLabel) {
+	m.retriesExhausted.WithLabelValues(string(label)).Inc()
+}
+
+// RegisterConfigReload records a reload of the provider configuration from
+// source, which failed if err is not nil
+func (m *Metrics) RegisterConfigReload(source string, err error) {
+	if err != nil {
+		m.configReloads.WithLabelValues(source, "failure").Inc()
+		return
+	}
+	m.configReloads.WithLabelValues(source, "success").Inc()
+	m.configLastReload.WithLabelValues(source).SetToCurrentTime()
+}
+
+// LockObserver returns an observer recording lock wait and hold times of a
+// utils.LockStore in m
+func (m *Metrics) LockObserver() LockObserver {
+	return LockObserver{Metrics: m}
+}
+
+// defaultMetrics backs the package level functions. Until RegisterAll is
+// called its collectors are not registered anywhere.
+var defaultMetrics atomic.Pointer[Metrics]
+
+func init() {
+	defaultMetrics.Store(newMetrics(""))
+}
+
+// Default returns the Metrics used by the package level functions
+func Default() *Metrics {
+	return defaultMetrics.Load()
+}
+
+// RegisterAll registers all metrics under namespace with the default
+// prometheus registerer and makes them the default Metrics. Calling it again
+// with the same namespace keeps the registered collectors.
+//
+// Deprecated: use New with an explicit registerer
 func RegisterAll(namespace string) {
-	pluginNamespace = namespace
-	prometheus.MustRegister(volumesCount)
-	prometheus.MustRegister(volumesAttachedCount)
-	prometheus.MustRegister(functionDuration)
-	prometheus.MustRegister(functionCount)
-	prometheus.MustRegister(errorsCount)
+	defaultMetrics.Store(MustNew(namespace, prometheus.DefaultRegisterer))
 }
 
 // UpdateVolumeCount records number of volumes currently present in the cluster
 func UpdateVolumeCount(podsCount int) {
-	volumesCount.Set(float64(podsCount))
+	Default().UpdateVolumeCount(podsCount)
 }
 
 // UpdateVolumeAttachedCount records number of volumes currently attached in the cluster
 func UpdateVolumeAttachedCount(podsCount int) {
-	volumesAttachedCount.Set(float64(podsCount))
+	Default().UpdateVolumeAttachedCount(podsCount)
+}
+
+// ObserveOperation records the outcome of an operation in the default Metrics
+func ObserveOperation(label FunctionLabel, start time.Time, err error) {
+	Default().ObserveOperation(label, start, err)
+}
+
+// ObserveOperationFromStart logs and records the outcome of an operation in
+// the default Metrics
+func ObserveOperationFromStart(logger *zap.Logger, label FunctionLabel, start time.Time, err error) {
+	Default().ObserveOperationFromStart(logger, label, start, err)
 }
 
 // UpdateDurationFromStart records the duration of the step identified by the
 // label using start time
+//
+// Deprecated: use ObserveOperationFromStart which also records the outcome
 func UpdateDurationFromStart(logger *zap.Logger, label FunctionLabel, start time.Time) {
-	duration := time.Since(start)
-	logger.Info("Time to complete", zap.Float64(string(label), duration.Seconds()))
-	UpdateDuration(label, duration)
+	Default().UpdateDurationFromStart(logger, label, start)
 }
 
 // UpdateDuration records the duration of the step identified by the label
+//
+// Deprecated: use ObserveOperation
 func UpdateDuration(label FunctionLabel, duration time.Duration) {
-	functionDuration.WithLabelValues(string(label)).Set(duration.Seconds())
+	Default().UpdateDuration(label, duration)
 }
 
 // RegisterError records any errors for any plugin operation.
+//
+// Deprecated: use ObserveOperation
 func RegisterError(errType string, err error) {
-	if err != nil {
-		errType = err.Error() // TODO Get the error code
-	}
-	errorsCount.WithLabelValues(errType).Add(1.0)
+	Default().RegisterError(errType, err)
 }
 
 // RegisterFunction records any errors for any plugin operation.
 func RegisterFunction(label FunctionLabel) {
-	functionCount.WithLabelValues(string(label)).Add(1.0)
+	Default().RegisterFunction(label)
+}
+
+// reasonCodePattern finds the reason code in errors built by messages.Message.Info
+var reasonCodePattern = regexp.MustCompile(`Code: ([A-Za-z0-9]+),`)
+
+// ReasonCode returns a bounded label for err: the messages reason code such
+// as VolumeCreationFailed, RC5XX or RC4XX for backend errors, or Unknown.
+// It returns None if err is nil.
+func ReasonCode(err error) string {
+	if err == nil {
+		return ReasonNone
+	}
+	var msg messages.Message
+	if errors.As(err, &msg) {
+		return messageReasonCode(msg.Code, msg.BackendError)
+	}
+	text := err.Error()
+	if st, ok := status.FromError(err); ok {
+		text = st.Message()
+	}
+	if strings.Contains(text, "BackendError: ") {
+		return messageReasonCode("", text)
+	}
+	if match := reasonCodePattern.FindStringSubmatch(text); match != nil {
+		return match[1]
+	}
+	return ReasonUnknown
+}
+
+func messageReasonCode(code string, backendError string) string {
+	if backendError != "" {
+		if code == messages.InternalError || strings.Contains(strings.Replace(backendError, " ", "", -1), messages.RC5XX) {
+			return ReasonRC5XX
+		}
+		return ReasonRC4XX
+	}
+	if code == "" {
+		return ReasonUnknown
+	}
+	return code
+}
+
+// LockObserver records lock wait and hold times of a utils.LockStore in
+// Metrics, or in the default Metrics if Metrics is nil
+type LockObserver struct {
+	Metrics *Metrics
+}
+
+func (o LockObserver) metrics() *Metrics {
+	if o.Metrics != nil {
+		return o.Metrics
+	}
+	return Default()
+}
+
+// ObserveLockWait records the time spent waiting for a lock of the store,
+// which is held from now on
+func (o LockObserver) ObserveLockWait(store string, wait time.Duration) {
+	m := o.metrics()
+	m.lockWaitDuration.WithLabelValues(store).Observe(wait.Seconds())
+	m.locksHeld.WithLabelValues(store).Inc()
+}
+
+// ObserveLockHold records the time a lock of the store was held before it
+// was released
+func (o LockObserver) ObserveLockHold(store string, hold time.Duration) {
+	m := o.metrics()
+	m.lockHoldDuration.WithLabelValues(store).Observe(hold.Seconds())
+	m.locksHeld.WithLabelValues(store).Dec()
 }
@@ -19,11 +19,19 @@ package metrics
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/IBM/ibm-csi-common/pkg/messages"
 	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestGetCSIError(t *testing.T) {
@@ -58,3 +66,242 @@ func TestRegisterFunction(t *testing.T) {
 	funLabel := FunctionLabel("myFunction")
 	RegisterFunction(funLabel)
 }
+
+func TestLockObserver(t *testing.T) {
+	m := MustNew("driver", prometheus.NewRegistry())
+	store := utils.LockStore{Name: "volume", Observer: m.LockObserver()}
+	store.Lock("vol-1")
+	assert.True(t, store.TryLock("vol-2"))
+	assert.Equal(t, float64(2), writeMetric(t, m.locksHeld.WithLabelValues("volume")).GetGauge().GetValue())
+
+	store.Unlock("vol-1")
+	assert.Equal(t, float64(1), writeMetric(t, m.locksHeld.WithLabelValues("volume")).GetGauge().GetValue())
+	store.Unlock("vol-2")
+	assert.Equal(t, float64(0), writeMetric(t, m.locksHeld.WithLabelValues("volume")).GetGauge().GetValue())
+
+	assert.Equal(t, uint64(2), writeMetric(t, m.lockWaitDuration.WithLabelValues("volume")).GetHistogram().GetSampleCount())
+	assert.Equal(t, uint64(2), writeMetric(t, m.lockHoldDuration.WithLabelValues("volume")).GetHistogram().GetSampleCount())
+
+	// The default observer records in the default Metrics
+	store.Observer = LockObserver{}
+	waits := writeMetric(t, Default().lockWaitDuration.WithLabelValues("volume")).GetHistogram().GetSampleCount()
+	store.Lock("vol-1")
+	store.Unlock("vol-1")
+	assert.Equal(t, waits+1, writeMetric(t, Default().lockWaitDuration.WithLabelValues("volume")).GetHistogram().GetSampleCount())
+}
+
+// writeMetric returns the current value of a single metric
+func writeMetric(t *testing.T, metric interface{}) *dto.Metric {
+	m := &dto.Metric{}
+	assert.Nil(t, metric.(prometheus.Metric).Write(m))
+	return m
+}
+
+func TestObserveOperation(t *testing.T) {
+	funLabel := FunctionLabel("observeOperation")
+	ObserveOperation(funLabel, time.Now(), nil)
+	ObserveOperation(funLabel, time.Now(), status.Error(codes.NotFound, "not found"))
+	ctxLog, _ := utils.GetContextLogger(context.Background(), false)
+	ObserveOperationFromStart(ctxLog, funLabel, time.Now(), nil)
+
+	assert.Equal(t, uint64(2), writeMetric(t, Default().operationDuration.WithLabelValues(string(funLabel), codes.OK.String())).GetHistogram().GetSampleCount())
+	assert.Equal(t, uint64(1), writeMetric(t, Default().operationDuration.WithLabelValues(string(funLabel), codes.NotFound.String())).GetHistogram().GetSampleCount())
+	assert.Equal(t, float64(1), writeMetric(t, Default().operationErrors.WithLabelValues(string(funLabel), ReasonUnknown)).GetCounter().GetValue())
+}
+
+func TestUpdateDurationRecordsHistogram(t *testing.T) {
+	funLabel := FunctionLabel("legacyFunction")
+	UpdateDuration(funLabel, time.Second)
+	UpdateDuration(funLabel, 2*time.Second)
+
+	// The deprecated gauge keeps the last value, the histogram both
+	assert.Equal(t, float64(2), writeMetric(t, Default().functionDuration.WithLabelValues(string(funLabel))).GetGauge().GetValue())
+	histogram := writeMetric(t, Default().operationDuration.WithLabelValues(string(funLabel), StatusUnspecified)).GetHistogram()
+	assert.Equal(t, uint64(2), histogram.GetSampleCount())
+	assert.Equal(t, float64(3), histogram.GetSampleSum())
+}
+
+func TestRegisterErrorUsesReasonCode(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+	err := messages.GetCSIError(ctxLog, messages.VolumeCreationFailed, reqID, fmt.Errorf("quota exceeded"), "pvc-1")
+	RegisterError("CreateVolume", err)
+	assert.Equal(t, float64(1), writeMetric(t, Default().errorsCount.WithLabelValues(messages.VolumeCreationFailed)).GetCounter().GetValue())
+
+	RegisterError("CreateVolume", nil)
+	assert.Equal(t, float64(1), writeMetric(t, Default().errorsCount.WithLabelValues("CreateVolume")).GetCounter().GetValue())
+}
+
+func TestNewUsesNamespace(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m, err := New("vpc_block_csi_driver", registry)
+	assert.Nil(t, err)
+	assert.Equal(t, "vpc_block_csi_driver", m.Namespace())
+	m.UpdateVolumeCount(3)
+	m.ObserveOperation("CreateVolume", time.Now(), nil)
+
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	assert.True(t, names["vpc_block_csi_driver_volumes_count"])
+	assert.True(t, names["vpc_block_csi_driver_operation_duration_seconds"])
+	assert.False(t, names["volumes_count"])
+}
+
+func TestNewTwiceSharesCollectors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	first := MustNew("ibm_vpc_file_csi_driver", registry)
+	second, err := New("ibm_vpc_file_csi_driver", registry)
+	assert.Nil(t, err)
+
+	first.RegisterFunction("NodePublishVolume")
+	second.RegisterFunction("NodePublishVolume")
+	assert.Equal(t, float64(2), writeMetric(t, first.functionCount.WithLabelValues("NodePublishVolume")).GetCounter().GetValue())
+}
+
+func TestNewConflictingCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Namespace: "driver", Name: "volumes_count", Help: "Conflicting help."}))
+	_, err := New("driver", registry)
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { MustNew("driver", registry) })
+}
+
+func TestDriversShareProcess(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	block := MustNew("vpc_block_csi_driver", registry)
+	file := MustNew("ibm_vpc_file_csi_driver", registry)
+	object := MustNew("ibm_object_csi_driver", registry)
+	block.UpdateVolumeCount(1)
+	file.UpdateVolumeCount(2)
+	object.UpdateVolumeCount(3)
+
+	recorder := httptest.NewRecorder()
+	handler, err := block.Handler()
+	assert.Nil(t, err)
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, "vpc_block_csi_driver_volumes_count 1")
+	assert.Contains(t, body, "ibm_vpc_file_csi_driver_volumes_count 2")
+	assert.Contains(t, body, "ibm_object_csi_driver_volumes_count 3")
+}
+
+func TestUnregisteredMetrics(t *testing.T) {
+	m, err := New("driver", nil)
+	assert.Nil(t, err)
+	_, err = m.Handler()
+	assert.NotNil(t, err) // Nothing to gather from
+	m.UpdateVolumeAttachedCount(1)
+	m.LockObserver().ObserveLockWait("volume", time.Millisecond)
+	assert.Equal(t, uint64(1), writeMetric(t, m.lockWaitDuration.WithLabelValues("volume")).GetHistogram().GetSampleCount())
+}
+
+func TestNamespaceSanitised(t *testing.T) {
+	testCases := []struct {
+		testCaseName      string
+		inputNamespace    string
+		expectedNamespace string
+	}{
+		{
+			testCaseName:      "Valid namespace",
+			inputNamespace:    "vpc_block_csi_driver",
+			expectedNamespace: "vpc_block_csi_driver",
+		},
+		{
+			testCaseName:      "Driver name",
+			inputNamespace:    "vpc.block.csi-driver",
+			expectedNamespace: "vpc_block_csi_driver",
+		},
+		{
+			testCaseName:      "Leading digit",
+			inputNamespace:    "1driver",
+			expectedNamespace: "_1driver",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			m, err := New(tc.inputNamespace, prometheus.NewRegistry())
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedNamespace, m.Namespace())
+		})
+	}
+}
+
+func TestRegisterAllTwice(t *testing.T) {
+	assert.NotPanics(t, func() {
+		RegisterAll("mydriver_twice")
+		RegisterAll("mydriver_twice")
+	})
+	assert.Equal(t, "mydriver_twice", Default().Namespace())
+}
+
+func TestReasonCode(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+	testCases := []struct {
+		testCaseName   string
+		inputErr       error
+		expectedReason string
+	}{
+		{
+			testCaseName:   "CSI error",
+			inputErr:       messages.GetCSIError(ctxLog, messages.EmptyVolumeID, reqID, nil),
+			expectedReason: messages.EmptyVolumeID,
+		},
+		{
+			testCaseName:   "CSI message",
+			inputErr:       messages.GetCSIMessage(messages.VolumeCreationFailed, "pvc-1"),
+			expectedReason: messages.VolumeCreationFailed,
+		},
+		{
+			testCaseName:   "Backend 5xx error",
+			inputErr:       messages.GetCSIBackendError(ctxLog, reqID, fmt.Errorf("{Trace Code:1, Code:InternalError, Description:Server error, RC:500 Internal Server Error}")),
+			expectedReason: ReasonRC5XX,
+		},
+		{
+			testCaseName:   "Backend 4xx error",
+			inputErr:       messages.GetCSIBackendError(ctxLog, reqID, fmt.Errorf("{Trace Code:1, Code:InvalidArgument, Description:Please check parameters, RC:400 Bad Request}")),
+			expectedReason: ReasonRC4XX,
+		},
+		{
+			testCaseName:   "Plain error",
+			inputErr:       fmt.Errorf("metrics error"),
+			expectedReason: ReasonUnknown,
+		},
+		{
+			testCaseName:   "No error",
+			inputErr:       nil,
+			expectedReason: ReasonNone,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			assert.Equal(t, tc.expectedReason, ReasonCode(tc.inputErr))
+		})
+	}
+}
+
+func TestRegisterRetry(t *testing.T) {
+	m := MustNew("driver", prometheus.NewRegistry())
+	m.RegisterRetry("CreateVolume", status.Error(codes.Unavailable, "try again"))
+	m.RegisterRetry("CreateVolume", status.Error(codes.Unavailable, "try again"))
+	m.RegisterRetriesExhausted("CreateVolume")
+	assert.Equal(t, float64(2), writeMetric(t, m.retries.WithLabelValues("CreateVolume", ReasonUnknown)).GetCounter().GetValue())
+	assert.Equal(t, float64(1), writeMetric(t, m.retriesExhausted.WithLabelValues("CreateVolume")).GetCounter().GetValue())
+}
+
+func TestRegisterConfigReload(t *testing.T) {
+	m := MustNew("driver", prometheus.NewRegistry())
+	m.RegisterConfigReload("file:/etc/storage_ibmc/slclient.toml", nil)
+	m.RegisterConfigReload("file:/etc/storage_ibmc/slclient.toml", fmt.Errorf("invalid config"))
+	m.RegisterConfigReload("file:/etc/storage_ibmc/slclient.toml", nil)
+	assert.Equal(t, float64(2), writeMetric(t, m.configReloads.WithLabelValues("file:/etc/storage_ibmc/slclient.toml", "success")).GetCounter().GetValue())
+	assert.Equal(t, float64(1), writeMetric(t, m.configReloads.WithLabelValues("file:/etc/storage_ibmc/slclient.toml", "failure")).GetCounter().GetValue())
+	assert.NotZero(t, writeMetric(t, m.configLastReload.WithLabelValues("file:/etc/storage_ibmc/slclient.toml")).GetGauge().GetValue())
+}
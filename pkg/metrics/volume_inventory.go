@@ -0,0 +1,203 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics ...
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	storagelisters "k8s.io/client-go/listers/storage/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	volumesCountName         = "volumes_count"
+	volumesCountHelp         = "Total Number of volumes in the cluster."
+	volumesAttachedCountName = "volumes_attached_count"
+	volumesAttachedCountHelp = "Total Number of volumes attached in the cluster "
+
+	// unknownLabelValue labels the attachments of volumes missing from the
+	// informer cache
+	unknownLabelValue = "unknown"
+)
+
+var (
+	volumesCountLabels         = []string{"storage_class", "zone", "phase"}
+	volumesAttachedCountLabels = []string{"storage_class", "zone"}
+)
+
+// volumeKey groups volumes of the inventory
+type volumeKey struct {
+	storageClass string
+	zone         string
+	phase        string
+}
+
+// VolumeInventory is a collector counting, on every scrape, the persistent
+// volumes and volume attachments of a driver in an informer cache
+type VolumeInventory struct {
+	provisionerName string
+	pvLister        corelisters.PersistentVolumeLister
+	vaLister        storagelisters.VolumeAttachmentLister
+	hasSynced       []cache.InformerSynced
+
+	volumes  *prometheus.Desc
+	attached *prometheus.Desc
+}
+
+// NewVolumeInventory creates the inventory of the volumes provisioned by
+// provisionerName, with metric names under namespace. It registers the
+// persistent volume and volume attachment informers with factory, which the
+// caller starts.
+func NewVolumeInventory(namespace string, provisionerName string, factory informers.SharedInformerFactory) *VolumeInventory {
+	pvInformer := factory.Core().V1().PersistentVolumes()
+	vaInformer := factory.Storage().V1().VolumeAttachments()
+	return &VolumeInventory{
+		provisionerName: provisionerName,
+		pvLister:        pvInformer.Lister(),
+		vaLister:        vaInformer.Lister(),
+		hasSynced:       []cache.InformerSynced{pvInformer.Informer().HasSynced, vaInformer.Informer().HasSynced},
+		volumes:         prometheus.NewDesc(prometheus.BuildFQName(namespace, "", volumesCountName), volumesCountHelp, volumesCountLabels, nil),
+		attached:        prometheus.NewDesc(prometheus.BuildFQName(namespace, "", volumesAttachedCountName), volumesAttachedCountHelp, volumesAttachedCountLabels, nil),
+	}
+}
+
+// HasSynced reports whether the informer caches have been filled
+func (vi *VolumeInventory) HasSynced() bool {
+	for _, hasSynced := range vi.hasSynced {
+		if !hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe ...
+func (vi *VolumeInventory) Describe(ch chan<- *prometheus.Desc) {
+	ch <- vi.volumes
+	ch <- vi.attached
+}
+
+// Collect ...
+func (vi *VolumeInventory) Collect(ch chan<- prometheus.Metric) {
+	volumes, attached := vi.count()
+	for key, count := range volumes {
+		ch <- prometheus.MustNewConstMetric(vi.volumes, prometheus.GaugeValue, float64(count), key.storageClass, key.zone, key.phase)
+	}
+	for key, count := range attached {
+		ch <- prometheus.MustNewConstMetric(vi.attached, prometheus.GaugeValue, float64(count), key.storageClass, key.zone)
+	}
+}
+
+// count returns the volumes of the driver by storage class, zone and phase,
+// and the attached ones by storage class and zone
+func (vi *VolumeInventory) count() (map[volumeKey]int, map[volumeKey]int) {
+	volumes := map[volumeKey]int{}
+	attached := map[volumeKey]int{}
+	pvs, err := vi.pvLister.List(labels.Everything())
+	if err != nil {
+		return volumes, attached
+	}
+	driverPVs := map[string]*v1.PersistentVolume{}
+	for _, pv := range pvs {
+		if !utils.IsPVProvisionedBy(pv, vi.provisionerName) {
+			continue
+		}
+		driverPVs[pv.Name] = pv
+		volumes[volumeKey{storageClass: pv.Spec.StorageClassName, zone: utils.GetPVZone(pv), phase: string(pv.Status.Phase)}]++
+	}
+
+	vas, err := vi.vaLister.List(labels.Everything())
+	if err != nil {
+		return volumes, attached
+	}
+	for _, va := range vas {
+		if va.Spec.Attacher != vi.provisionerName || !va.Status.Attached || va.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		key := volumeKey{storageClass: unknownLabelValue, zone: unknownLabelValue}
+		if pv, ok := driverPVs[*va.Spec.Source.PersistentVolumeName]; ok {
+			key = volumeKey{storageClass: pv.Spec.StorageClassName, zone: utils.GetPVZone(pv)}
+		}
+		attached[key]++
+	}
+	return volumes, attached
+}
+
+// volumeCollector exports volumes_count and volumes_attached_count. Until an
+// inventory is set these are the unlabelled values of UpdateVolumeCount and
+// UpdateVolumeAttachedCount, as they always were, afterwards the labelled
+// counts of the inventory. Only the unlabelled gauges are described, so the
+// collector registers like before; the registry checks the collected
+// descriptors only in pedantic mode.
+type volumeCollector struct {
+	volumes   prometheus.Gauge
+	attached  prometheus.Gauge
+	inventory atomic.Pointer[VolumeInventory]
+}
+
+func newVolumeCollector(namespace string) *volumeCollector {
+	return &volumeCollector{
+		volumes: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      volumesCountName,
+				Help:      volumesCountHelp,
+			},
+		),
+		attached: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      volumesAttachedCountName,
+				Help:      volumesAttachedCountHelp,
+			},
+		),
+	}
+}
+
+// Describe ...
+func (c *volumeCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.volumes.Describe(ch)
+	c.attached.Describe(ch)
+}
+
+// Collect ...
+func (c *volumeCollector) Collect(ch chan<- prometheus.Metric) {
+	if inventory := c.inventory.Load(); inventory != nil {
+		inventory.Collect(ch)
+		return
+	}
+	c.volumes.Collect(ch)
+	c.attached.Collect(ch)
+}
+
+// RegisterVolumeInventory makes m export volumes_count and
+// volumes_attached_count, counting the volumes provisioned by provisionerName
+// on every scrape, instead of the values of UpdateVolumeCount and
+// UpdateVolumeAttachedCount.
+// The caller starts factory.
+func (m *Metrics) RegisterVolumeInventory(provisionerName string, factory informers.SharedInformerFactory) *VolumeInventory {
+	inventory := NewVolumeInventory(m.namespace, provisionerName, factory)
+	m.volumes.inventory.Store(inventory)
+	return inventory
+}
@@ -0,0 +1,182 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics ...
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+const testProvisioner = "vpc.block.csi.ibm.io"
+
+func newTestPV(name, driver, storageClass, zone string, phase v1.PersistentVolumePhase) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1.PersistentVolumeSpec{
+			StorageClassName: storageClass,
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:           driver,
+					VolumeHandle:     name,
+					VolumeAttributes: map[string]string{utils.ZoneLabel: zone},
+				},
+			},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: phase},
+	}
+}
+
+func newTestVA(name, attacher, pvName string, attached bool) *storagev1.VolumeAttachment {
+	return &storagev1.VolumeAttachment{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: storagev1.VolumeAttachmentSpec{
+			Attacher: attacher,
+			NodeName: "node-1",
+			Source:   storagev1.VolumeAttachmentSource{PersistentVolumeName: &pvName},
+		},
+		Status: storagev1.VolumeAttachmentStatus{Attached: attached},
+	}
+}
+
+// startInventory registers an inventory of objects in a new registry
+func startInventory(t *testing.T, objects ...runtime.Object) (*VolumeInventory, *prometheus.Registry) {
+	clientset := fake.NewSimpleClientset(objects...)
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	registry := prometheus.NewRegistry()
+	m := MustNew("vpc_block_csi_driver", registry)
+	inventory := m.RegisterVolumeInventory(testProvisioner, factory)
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+	assert.True(t, cache.WaitForCacheSync(stopCh, inventory.HasSynced))
+	return inventory, registry
+}
+
+// gatherGauges returns the gauge values of the family name by label values
+func gatherGauges(t *testing.T, registry *prometheus.Registry, name string) map[string]float64 {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	values := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			key := ""
+			for _, label := range metric.GetLabel() {
+				key += label.GetName() + "=" + label.GetValue() + ","
+			}
+			values[key] = metric.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
+func TestVolumeInventory(t *testing.T) {
+	_, registry := startInventory(t,
+		newTestPV("pv-1", testProvisioner, "ibmc-vpc-block-10iops-tier", "us-south-1", v1.VolumeBound),
+		newTestPV("pv-2", testProvisioner, "ibmc-vpc-block-10iops-tier", "us-south-1", v1.VolumeBound),
+		newTestPV("pv-3", testProvisioner, "ibmc-vpc-block-5iops-tier", "us-south-2", v1.VolumeReleased),
+		newTestPV("pv-file", "vpc.file.csi.ibm.io", "ibmc-vpc-file-dp2", "us-south-1", v1.VolumeBound),
+		newTestVA("va-1", testProvisioner, "pv-1", true),
+		newTestVA("va-2", testProvisioner, "pv-2", false),
+		newTestVA("va-file", "vpc.file.csi.ibm.io", "pv-file", true),
+	)
+
+	assert.Equal(t, map[string]float64{
+		"phase=Bound,storage_class=ibmc-vpc-block-10iops-tier,zone=us-south-1,":   2,
+		"phase=Released,storage_class=ibmc-vpc-block-5iops-tier,zone=us-south-2,": 1,
+	}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_count"))
+	assert.Equal(t, map[string]float64{
+		"storage_class=ibmc-vpc-block-10iops-tier,zone=us-south-1,": 1,
+	}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_attached_count"))
+}
+
+func TestVolumeInventoryFollowsCache(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	inventory := NewVolumeInventory("", testProvisioner, factory)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	assert.True(t, cache.WaitForCacheSync(stopCh, inventory.HasSynced))
+
+	volumes, _ := inventory.count()
+	assert.Equal(t, 0, len(volumes))
+
+	pv := newTestPV("pv-1", testProvisioner, "ibmc-vpc-block-general-purpose", "", v1.VolumeAvailable)
+	_, err := clientset.CoreV1().PersistentVolumes().Create(t.Context(), pv, metav1.CreateOptions{})
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		volumes, _ := inventory.count()
+		return volumes[volumeKey{storageClass: "ibmc-vpc-block-general-purpose", phase: string(v1.VolumeAvailable)}] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestVolumeCountsWithoutInventory(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	m := MustNew("vpc_block_csi_driver", registry)
+	m.UpdateVolumeCount(4)
+	m.UpdateVolumeAttachedCount(2)
+	assert.Equal(t, map[string]float64{"": 4}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_count"))
+	assert.Equal(t, map[string]float64{"": 2}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_attached_count"))
+
+	// Once an inventory is registered only its labelled counts are exported
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(newTestPV("pv-1", testProvisioner, "ibmc-vpc-block-5iops-tier", "us-south-1", v1.VolumeBound)), 0)
+	inventory := m.RegisterVolumeInventory(testProvisioner, factory)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	assert.True(t, cache.WaitForCacheSync(stopCh, inventory.HasSynced))
+	assert.Equal(t, map[string]float64{
+		"phase=Bound,storage_class=ibmc-vpc-block-5iops-tier,zone=us-south-1,": 1,
+	}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_count"))
+	assert.Empty(t, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_attached_count"))
+}
+
+func TestVolumeInventoryUnknownVolume(t *testing.T) {
+	// The attachment shows up before its volume is in the cache
+	_, registry := startInventory(t, newTestVA("va-1", testProvisioner, "pv-missing", true))
+	assert.Equal(t, map[string]float64{
+		"storage_class=unknown,zone=unknown,": 1,
+	}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_attached_count"))
+}
+
+func TestVolumeInventoryStandalone(t *testing.T) {
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(newTestPV("pv-1", testProvisioner, "", "", v1.VolumeBound)), 0)
+	inventory := NewVolumeInventory("vpc_block_csi_driver", testProvisioner, factory)
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(inventory))
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	assert.True(t, cache.WaitForCacheSync(stopCh, inventory.HasSynced))
+	assert.Equal(t, map[string]float64{"phase=Bound,storage_class=,zone=,": 1}, gatherGauges(t, registry, "vpc_block_csi_driver_volumes_count"))
+}
@@ -31,6 +31,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/IBM/ibm-csi-common/pkg/tracing"
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
 	mount "k8s.io/mount-utils"
 )
 
@@ -43,13 +46,17 @@ const (
 	urlDebugPath = "http://unix/api/debugLogs"
 	// http timeout
 	timeout = 3 * time.Minute
+	// request ID header, matching the x-request-id gRPC metadata key
+	requestIDHeader = "X-Request-ID"
 )
 
 // MountEITBasedFileShare mounts EIT based FileShare on host system
 func (m *NodeMounter) MountEITBasedFileShare(mountPath string, targetPath string, fsType string, transitEncryption string, requestID string) (string, error) {
+	// GetContextLogger returns the request ID with a trailing space
+	requestID = strings.TrimSpace(requestID)
 	// Create payload
 	payload := fmt.Sprintf(`{"mountPath":"%s","targetPath":"%s","fsType":"%s","transitEncryption":"%s","requestID":"%s"}`, mountPath, targetPath, fsType, transitEncryption, requestID)
-	errResponse, err := createMountHelperContainerRequest(payload, urlMountPath)
+	errResponse, err := createMountHelperContainerRequest(payload, urlMountPath, requestID)
 
 	if err != nil {
 		return errResponse, err
@@ -108,7 +115,7 @@ func (m *NodeMounter) Resize(devicePath string, deviceMountPath string) (bool, e
 }
 
 // createMountHelperContainerRequest creates a request to mount-helper-container server over UNIX socket and returns errors if any.
-func createMountHelperContainerRequest(payload string, url string) (string, error) {
+func createMountHelperContainerRequest(payload string, url string, requestID string) (description string, err error) {
 	// Get socket path
 	socketPath := os.Getenv("SOCKET_PATH")
 	if socketPath == "" {
@@ -121,23 +128,30 @@ func createMountHelperContainerRequest(payload string, url string) (string, erro
 
 	// Create an HTTP client with the Unix socket transport
 	client := &http.Client{
-		Transport: &http.Transport{
+		Transport: tracing.HTTPTransport(&http.Transport{
 			DialContext: dialer,
-		},
+		}),
 		Timeout: timeout,
 	}
 
+	ctx, span := tracing.StartSpan(utils.ContextWithRequestID(context.Background(), requestID), "MountHelper "+url)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	//Create POST request
-	req, err := http.NewRequest("POST", url, strings.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(payload))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if requestID != "" {
+		req.Header.Set(requestIDHeader, requestID)
+	}
 	response, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer response.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		return "", err
@@ -0,0 +1,83 @@
+//go:build linux
+// +build linux
+
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mountmanager ...
+package mountmanager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestCreateMountHelperContainerRequestSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	}()
+
+	socketPath := filepath.Join(t.TempDir(), "mount.sock")
+	t.Setenv("SOCKET_PATH", socketPath)
+	listener, err := net.Listen("unix", socketPath)
+	assert.Nil(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/debugLogs" {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"MountExitCode":"0"}`))
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"MountExitCode":"32","Description":"mount failed"}`))
+	})}
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	description, err := createMountHelperContainerRequest("{}", urlMountPath, "req-1")
+	assert.NotNil(t, err)
+	assert.Equal(t, "mount failed", description)
+
+	_, err = createMountHelperContainerRequest("{}", urlDebugPath, "req-2")
+	assert.Nil(t, err)
+
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.Name() == "MountHelper "+urlMountPath || span.Name() == "MountHelper "+urlDebugPath {
+			spans = append(spans, span)
+		}
+	}
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, otelcodes.Error, spans[0].Status().Code)
+	assert.Equal(t, 1, len(spans[0].Events())) // The error is recorded
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+	assert.Equal(t, otelcodes.Unset, spans[1].Status().Code)
+	assert.Contains(t, spans[1].Attributes(), attribute.Int("http.response.status_code", http.StatusOK))
+}
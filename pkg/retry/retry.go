@@ -0,0 +1,227 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package retry retries backend calls such as GetProviderSession or volume
+// operations with exponential backoff, as long as their errors are retryable.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibm-csi-common/pkg/metrics"
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryableReturnCodes are the backend return codes of transient failures
+var retryableReturnCodes = map[int]bool{
+	408: true,
+	429: true,
+	500: true,
+	502: true,
+	503: true,
+	504: true,
+}
+
+// retryableGRPCCodes are the gRPC codes of transient failures
+var retryableGRPCCodes = map[codes.Code]bool{
+	codes.Unavailable:       true,
+	codes.ResourceExhausted: true,
+	codes.DeadlineExceeded:  true,
+	codes.Aborted:           true,
+}
+
+// retryableReasonCodes are the reason codes of transient failures which are
+// not backend errors
+var retryableReasonCodes = map[string]bool{
+	messages.FailedPrecondition:   true,
+	messages.EndpointNotReachable: true,
+	messages.Timeout:              true,
+}
+
+// permanentError marks an error as terminal
+type permanentError struct {
+	err error
+}
+
+// Error ...
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap ...
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as terminal, so that Do returns it without retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable reports whether the operation which failed with err may
+// succeed if retried: backend errors with a 408, 429 or 5xx return code,
+// gRPC errors such as Unavailable, provider or IAM endpoint unavailability
+// and network timeouts. Other errors are terminal.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if backendErr, ok := messages.ParseBackendError(err); ok {
+		return retryableReturnCodes[backendErr.RC] || backendErr.RC > 500
+	}
+	if st, ok := status.FromError(err); ok && retryableGRPCCodes[st.Code()] {
+		return true
+	}
+	if retryableReasonCodes[messages.ReasonCodeFromError(err)] {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Policy tells how many times and how often an operation is retried. The
+// delay before retry n, starting at 0, is InitialDelay * Multiplier^n capped
+// at MaxDelay, randomly shortened by up to Jitter of its value.
+type Policy struct {
+	// MaxRetries after the first attempt
+	MaxRetries int
+
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+
+	// Jitter between 0 and 1
+	Jitter float64
+
+	// Retryable classifies errors, IsRetryable if nil
+	Retryable func(error) bool
+
+	// Metrics records retries, the default metrics if nil
+	Metrics *metrics.Metrics
+}
+
+// DefaultPolicy returns the policy retrying utils.MaxRetryAttemptForSessions
+// times after 1s, then 2s and so on
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxRetries:   utils.MaxRetryAttemptForSessions,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// Delay returns the delay before the retry number retry, starting at 0
+func (p Policy) Delay(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(retry))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * math.Min(p.Jitter, 1) * rand.Float64() // #nosec G404 jitter does not need a secure source
+	}
+	return time.Duration(delay)
+}
+
+func (p Policy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+func (p Policy) metrics() *metrics.Metrics {
+	if p.Metrics != nil {
+		return p.Metrics
+	}
+	return metrics.Default()
+}
+
+// Do calls fn until it succeeds, fails with a terminal error, the retries of
+// policy are exhausted or ctx is done, and returns the last error of fn.
+// Attempts are logged with logger, which should be the request logger.
+func Do(ctx context.Context, logger *zap.Logger, operation string, policy Policy, fn func(ctx context.Context) error) error {
+	_, err := DoValue(ctx, logger, operation, policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// DoValue is like Do for functions returning a value, e.g.
+//
+//	session, err := retry.DoValue(ctx, ctxLogger, "GetProviderSession", retry.DefaultPolicy(),
+//		func(ctx context.Context) (provider.Session, error) {
+//			return cloudProvider.GetProviderSession(ctx, ctxLogger)
+//		})
+func DoValue[T any](ctx context.Context, logger *zap.Logger, operation string, policy Policy, fn func(ctx context.Context) (T, error)) (T, error) {
+	label := metrics.FunctionLabel(operation)
+	for attempt := 0; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil {
+			if attempt > 0 {
+				logger.Info("Operation succeeded after retries", zap.String("operation", operation), zap.Int("attempt", attempt+1))
+			}
+			return value, nil
+		}
+		if !policy.retryable(err) {
+			logger.Debug("Operation failed with a terminal error", zap.String("operation", operation), zap.Int("attempt", attempt+1), zap.Error(err))
+			return value, err
+		}
+		if attempt >= policy.MaxRetries {
+			logger.Warn("Operation failed, no retries left", zap.String("operation", operation), zap.Int("attempt", attempt+1), zap.Error(err))
+			policy.metrics().RegisterRetriesExhausted(label)
+			return value, err
+		}
+
+		delay := policy.Delay(attempt)
+		logger.Warn("Operation failed, retrying", zap.String("operation", operation), zap.Int("attempt", attempt+1),
+			zap.Int("maxAttempts", policy.MaxRetries+1), zap.Duration("delay", delay), zap.Error(err))
+		policy.metrics().RegisterRetry(label, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Warn("Operation cancelled while waiting to retry", zap.String("operation", operation), zap.Error(ctx.Err()))
+			return value, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,270 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package retry ...
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibm-csi-common/pkg/metrics"
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	messages.MessagesEn = messages.InitMessages()
+	ctxLog, reqID := utils.GetContextLogger(context.Background(), false)
+
+	testCases := []struct {
+		testCaseName   string
+		inputErr       error
+		expectedResult bool
+	}{
+		{
+			testCaseName:   "No error",
+			inputErr:       nil,
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "Backend 500",
+			inputErr:       errors.New("{Trace Code:1, Code:InternalError, Description:Server error, RC:500 Internal Server Error}"),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "Backend 429",
+			inputErr:       errors.New("{Trace Code:1, Code:rate_limit_exceeded, Description:Slow down, RC:429 Too Many Requests}"),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "Backend 404",
+			inputErr:       errors.New("{Trace Code:1, Code:volume_not_found, Description:Not found, RC:404 Not Found}"),
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "Backend 503 as CSI error",
+			inputErr:       messages.GetCSIBackendError(ctxLog, reqID, errors.New("{Trace Code:1, Code:service_error, Description:Try later, RC:503 Service Unavailable}")),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "Backend 400 as CSI error",
+			inputErr:       messages.GetCSIBackendError(ctxLog, reqID, errors.New("{Trace Code:1, Code:InvalidArgument, Description:Bad, RC:400 Bad Request}")),
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "Provider not ready",
+			inputErr:       messages.GetCSIError(ctxLog, messages.FailedPrecondition, reqID, nil),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "IAM endpoint timeout",
+			inputErr:       messages.GetCSIError(ctxLog, messages.Timeout, reqID, errors.New("no route")),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "Invalid request",
+			inputErr:       messages.GetCSIError(ctxLog, messages.EmptyVolumeID, reqID, nil),
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "gRPC unavailable",
+			inputErr:       status.Error(codes.Unavailable, "connection refused"),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "gRPC not found",
+			inputErr:       status.Error(codes.NotFound, "missing"),
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "Network timeout",
+			inputErr:       fmt.Errorf("dial: %w", timeoutError{}),
+			expectedResult: true,
+		},
+		{
+			testCaseName:   "Context cancelled",
+			inputErr:       context.Canceled,
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "Permanent",
+			inputErr:       Permanent(status.Error(codes.Unavailable, "gone for good")),
+			expectedResult: false,
+		},
+		{
+			testCaseName:   "Plain error",
+			inputErr:       errors.New("boom"),
+			expectedResult: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			assert.Equal(t, tc.expectedResult, IsRetryable(tc.inputErr))
+		})
+	}
+}
+
+func TestDelay(t *testing.T) {
+	policy := Policy{InitialDelay: time.Second, MaxDelay: 5 * time.Second, Multiplier: 2}
+	assert.Equal(t, time.Second, policy.Delay(0))
+	assert.Equal(t, 2*time.Second, policy.Delay(1))
+	assert.Equal(t, 4*time.Second, policy.Delay(2))
+	assert.Equal(t, 5*time.Second, policy.Delay(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.Delay(1)
+		assert.True(t, delay > time.Second && delay <= 2*time.Second, delay)
+	}
+}
+
+// testPolicy retries fast and records metrics in a new registry
+func testPolicy(maxRetries int) (Policy, *prometheus.Registry) {
+	registry := prometheus.NewRegistry()
+	m := metrics.MustNew("retry_test", registry)
+	return Policy{MaxRetries: maxRetries, InitialDelay: time.Millisecond, Multiplier: 2, Metrics: m}, registry
+}
+
+// counterValue returns the sum of the counters of the family name
+func counterValue(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+	total := 0.0
+	for _, family := range families {
+		if family.GetName() == name {
+			for _, metric := range family.GetMetric() {
+				total += metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return total
+}
+
+func TestDo(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(core)
+	policy, registry := testPolicy(3)
+
+	calls := 0
+	err := Do(context.Background(), logger, "CreateVolume", policy, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return status.Error(codes.Unavailable, "try again")
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 2, logs.FilterMessage("Operation failed, retrying").Len())
+	assert.Equal(t, 1, logs.FilterMessage("Operation succeeded after retries").Len())
+	assert.Equal(t, float64(2), counterValue(t, registry, "retry_test_retries_total"))
+	assert.Equal(t, float64(0), counterValue(t, registry, "retry_test_retries_exhausted_total"))
+}
+
+func TestDoTerminalError(t *testing.T) {
+	policy, _ := testPolicy(3)
+	calls := 0
+	terminal := status.Error(codes.InvalidArgument, "bad request")
+	err := Do(context.Background(), zap.NewNop(), "CreateVolume", policy, func(ctx context.Context) error {
+		calls++
+		return terminal
+	})
+	assert.Equal(t, terminal, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDoExhausted(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	policy, registry := testPolicy(2)
+	calls := 0
+	err := Do(context.Background(), zap.New(core), "AttachVolume", policy, func(ctx context.Context) error {
+		calls++
+		return status.Error(codes.Unavailable, fmt.Sprintf("attempt %d", calls))
+	})
+	assert.Equal(t, "attempt 3", status.Convert(err).Message())
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 1, logs.FilterMessage("Operation failed, no retries left").Len())
+	assert.Equal(t, float64(2), counterValue(t, registry, "retry_test_retries_total"))
+	assert.Equal(t, float64(1), counterValue(t, registry, "retry_test_retries_exhausted_total"))
+}
+
+func TestDoCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{MaxRetries: 5, InitialDelay: time.Hour}
+	calls := 0
+	done := make(chan error)
+	go func() {
+		done <- Do(ctx, zap.NewNop(), "DeleteVolume", policy, func(ctx context.Context) error {
+			calls++
+			return status.Error(codes.Unavailable, "try again")
+		})
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 1, calls)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Do did not return after cancellation")
+	}
+}
+
+func TestDoValue(t *testing.T) {
+	policy, _ := testPolicy(1)
+	calls := 0
+	value, err := DoValue(context.Background(), zap.NewNop(), "GetProviderSession", policy, func(ctx context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			return "", messages.ErrFailedPrecondition
+		}
+		return "session", nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "session", value)
+
+	policy.Retryable = func(error) bool { return false }
+	calls = 0
+	_, err = DoValue(context.Background(), zap.NewNop(), "GetProviderSession", policy, func(ctx context.Context) (string, error) {
+		calls++
+		return "", messages.ErrFailedPrecondition
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	policy := DefaultPolicy()
+	assert.Equal(t, utils.MaxRetryAttemptForSessions, policy.MaxRetries)
+	assert.Equal(t, time.Second, policy.InitialDelay)
+}
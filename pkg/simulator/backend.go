@@ -0,0 +1,282 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package simulator is an in-memory stand-in for the IBM Cloud block storage
+// backend. It implements provider.Session and CloudProviderInterface so that
+// the PV watcher and the drivers can run lifecycle tests without network.
+package simulator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	util "github.com/IBM/ibmcloud-volume-interface/lib/utils"
+)
+
+const (
+	// MinCapacityGiB is the smallest volume the backend creates
+	MinCapacityGiB = 10
+	// MaxCapacityGiB is the largest volume the backend creates
+	MaxCapacityGiB = 16000
+	// DefaultPageSize is the page size of list calls without a limit
+	DefaultPageSize = 50
+	// MaxPageSize is the largest page size of list calls
+	MaxPageSize = 100
+
+	// VolumeStatusAvailable is the status of created volumes
+	VolumeStatusAvailable = "available"
+	// AttachmentStatusAttached is the status of attached volumes
+	AttachmentStatusAttached = "attached"
+)
+
+// Profiles are the volume profiles known to the backend
+var Profiles = []string{"general-purpose", "5iops-tier", "10iops-tier", "custom", "sdp"}
+
+// Quota limits the resources of a Backend, a zero value means no limit
+type Quota struct {
+	// MaxVolumes is the number of volumes
+	MaxVolumes int
+	// MaxCapacityGiB is the capacity of all volumes together
+	MaxCapacityGiB int
+	// MaxSnapshotsPerVolume is the number of snapshots of one volume
+	MaxSnapshotsPerVolume int
+	// MaxAttachmentsPerInstance is the number of volumes attached to one
+	// instance
+	MaxAttachmentsPerInstance int
+}
+
+// Backend holds the volumes, snapshots and attachments shared by the sessions
+// of a CloudProvider
+type Backend struct {
+	// Quota is enforced on create, expand, snapshot and attach
+	Quota Quota
+
+	mux sync.Mutex
+	// zones are the zones volumes can be created in
+	zones map[string]bool
+	// instances maps instance IDs to their zone
+	instances map[string]string
+	volumes   map[string]*provider.Volume
+	snapshots map[string]*provider.Snapshot
+	// snapshotZones maps snapshot IDs to the zone of their source volume,
+	// which is the zone volumes are restored in
+	snapshotZones map[string]string
+	// attachments maps volume IDs to their attachment
+	attachments map[string]*provider.VolumeAttachmentResponse
+	// nextID numbers the resources in creation order
+	nextID int
+	now    func() time.Time
+}
+
+// NewBackend returns an empty backend accepting volumes in zones
+func NewBackend(zones ...string) *Backend {
+	backend := &Backend{
+		zones:         map[string]bool{},
+		instances:     map[string]string{},
+		volumes:       map[string]*provider.Volume{},
+		snapshots:     map[string]*provider.Snapshot{},
+		snapshotZones: map[string]string{},
+		attachments:   map[string]*provider.VolumeAttachmentResponse{},
+		now:           time.Now,
+	}
+	for _, zone := range zones {
+		backend.zones[zone] = true
+	}
+	return backend
+}
+
+// AddInstance registers a virtual server instance in zone, volumes can only
+// be attached to known instances of their zone
+func (b *Backend) AddInstance(instanceID string, zone string) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.instances[instanceID] = zone
+}
+
+// Volumes returns copies of all volumes in creation order
+func (b *Backend) Volumes() []*provider.Volume {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	volumes := []*provider.Volume{}
+	for _, id := range sortedIDs(b.volumes) {
+		volumes = append(volumes, copyVolume(b.volumes[id]))
+	}
+	return volumes
+}
+
+// Snapshots returns copies of all snapshots in creation order
+func (b *Backend) Snapshots() []*provider.Snapshot {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	snapshots := []*provider.Snapshot{}
+	for _, id := range sortedIDs(b.snapshots) {
+		snapshots = append(snapshots, copySnapshot(b.snapshots[id]))
+	}
+	return snapshots
+}
+
+// newID returns a new ID with prefix, ordered by creation. b.mux must be held.
+func (b *Backend) newID(prefix string) string {
+	b.nextID++
+	return fmt.Sprintf("%s-%08d", prefix, b.nextID)
+}
+
+// usedCapacity returns the capacity of all volumes in GiB. b.mux must be held.
+func (b *Backend) usedCapacity() int {
+	used := 0
+	for _, volume := range b.volumes {
+		used += capacity(volume)
+	}
+	return used
+}
+
+// checkCapacity validates the capacity of a new or expanded volume, where
+// previous is the capacity it had. b.mux must be held.
+func (b *Backend) checkCapacity(requested int, previous int) error {
+	if requested < MinCapacityGiB || requested > MaxCapacityGiB {
+		return newError("invalid_capacity", http.StatusBadRequest, "The capacity %d GiB is not between %d and %d GiB", requested, MinCapacityGiB, MaxCapacityGiB)
+	}
+	if b.Quota.MaxCapacityGiB > 0 && b.usedCapacity()-previous+requested > b.Quota.MaxCapacityGiB {
+		return newError("over_quota", http.StatusForbidden, "The capacity quota of %d GiB is exceeded", b.Quota.MaxCapacityGiB)
+	}
+	return nil
+}
+
+// volumeNotFound ...
+func volumeNotFound(volumeID string) error {
+	return newError("volume_not_found", http.StatusNotFound, "The volume %s could not be found", volumeID)
+}
+
+// snapshotNotFound ...
+func snapshotNotFound(snapshotID string) error {
+	return newError("snapshot_not_found", http.StatusNotFound, "The snapshot %s could not be found", snapshotID)
+}
+
+// newError returns an error in the format of the ibmcloud-volume-interface
+// library, see messages.ParseBackendError
+func newError(code string, rc int, format string, args ...interface{}) error {
+	return util.Message{
+		Code:        code,
+		Type:        http.StatusText(rc),
+		Description: fmt.Sprintf(format, args...),
+		RC:          rc,
+	}
+}
+
+// page returns the IDs of a list call, starting at start, and the start of
+// the next page
+func page(ids []string, limit int, start string) ([]string, string, error) {
+	if limit < 0 || limit > MaxPageSize {
+		return nil, "", newError("invalid_limit", http.StatusBadRequest, "The limit %d is not between 0 and %d", limit, MaxPageSize)
+	}
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	first := 0
+	if start != "" {
+		first = sort.SearchStrings(ids, start)
+		if first == len(ids) || ids[first] != start {
+			return nil, "", newError("invalid_start", http.StatusBadRequest, "The start %s is not valid", start)
+		}
+	}
+	last := first + limit
+	if last >= len(ids) {
+		return ids[first:], "", nil
+	}
+	return ids[first:last], ids[last], nil
+}
+
+// sortedIDs returns the keys of resources, which sort in creation order
+func sortedIDs[T any](resources map[string]T) []string {
+	ids := make([]string, 0, len(resources))
+	for id := range resources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// capacity returns the capacity of volume in GiB
+func capacity(volume *provider.Volume) int {
+	if volume.Capacity == nil {
+		return 0
+	}
+	return *volume.Capacity
+}
+
+// hasTag reports whether tags contain tag, ignoring case like the backend
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyVolume returns a copy of volume which shares no state with it
+func copyVolume(volume *provider.Volume) *provider.Volume {
+	c := *volume
+	c.Capacity = copyPtr(volume.Capacity)
+	c.Iops = copyPtr(volume.Iops)
+	c.Tier = copyPtr(volume.Tier)
+	c.Name = copyPtr(volume.Name)
+	c.ServiceOffering = copyPtr(volume.ServiceOffering)
+	c.Tags = append([]string(nil), volume.Tags...)
+	c.Attributes = copyMap(volume.Attributes)
+	c.VolumeNotes = copyMap(volume.VolumeNotes)
+	c.SnapshotTags = copyMap(volume.SnapshotTags)
+	if volume.Profile != nil {
+		profile := *volume.Profile
+		c.Profile = &profile
+	}
+	if volume.VolumeAttachments != nil {
+		attachments := append([]provider.VolumeAttachment(nil), *volume.VolumeAttachments...)
+		c.VolumeAttachments = &attachments
+	}
+	return &c
+}
+
+// copySnapshot returns a copy of snapshot which shares no state with it
+func copySnapshot(snapshot *provider.Snapshot) *provider.Snapshot {
+	c := *snapshot
+	c.SnapshotTags = copyMap(snapshot.SnapshotTags)
+	return &c
+}
+
+func copyPtr[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	c := *value
+	return &c
+}
+
+func copyMap[M ~map[string]string](m M) M {
+	if m == nil {
+		return nil
+	}
+	c := make(M, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,66 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package simulator ...
+package simulator
+
+import (
+	cloudprovider "github.com/IBM/ibm-csi-common/pkg/ibmcloudprovider"
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+)
+
+// ClusterID is the cluster ID reported by CloudProvider
+const ClusterID = "simulator-cluster"
+
+// CloudProvider is a CloudProviderInterface whose sessions all work on the
+// same Backend
+type CloudProvider struct {
+	Backend        *Backend
+	ProviderConfig *config.Config
+	ClusterID      string
+}
+
+var _ cloudprovider.CloudProviderInterface = &CloudProvider{}
+
+// NewCloudProvider returns a provider of sessions on backend
+func NewCloudProvider(backend *Backend) *CloudProvider {
+	return &CloudProvider{
+		Backend: backend,
+		ProviderConfig: &config.Config{VPC: &config.VPCProviderConfig{
+			VPCBlockProviderName: string(ProviderName),
+			VPCBlockProviderType: string(ProviderName),
+		}},
+		ClusterID: ClusterID,
+	}
+}
+
+// GetProviderSession ...
+func (cp *CloudProvider) GetProviderSession(ctx context.Context, logger *zap.Logger) (provider.Session, error) {
+	return NewSession(cp.Backend), nil
+}
+
+// GetConfig ...
+func (cp *CloudProvider) GetConfig() *config.Config {
+	return cp.ProviderConfig
+}
+
+// GetClusterID ...
+func (cp *CloudProvider) GetClusterID() string {
+	return cp.ClusterID
+}
@@ -0,0 +1,609 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package simulator ...
+package simulator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+)
+
+const (
+	// ProviderName is the name of the simulated provider
+	ProviderName = provider.VolumeProvider("simulator")
+	// VolumeType is the type of the simulated volumes
+	VolumeType = provider.VolumeType("block")
+)
+
+// Session is a provider.Session on a Backend. Volumes and snapshots are
+// ready as soon as they are created, attachments as soon as they are
+// requested.
+type Session struct {
+	backend *Backend
+}
+
+var _ provider.Session = &Session{}
+
+// NewSession returns a session on backend
+func NewSession(backend *Backend) *Session {
+	return &Session{backend: backend}
+}
+
+// ProviderName ...
+func (s *Session) ProviderName() provider.VolumeProvider {
+	return ProviderName
+}
+
+// Type ...
+func (s *Session) Type() provider.VolumeType {
+	return VolumeType
+}
+
+// GetProviderDisplayName ...
+func (s *Session) GetProviderDisplayName() provider.VolumeProvider {
+	return ProviderName
+}
+
+// Close ...
+func (s *Session) Close() {
+}
+
+// GetVolumeProfileByName ...
+func (s *Session) GetVolumeProfileByName(name string) (*provider.Profile, error) {
+	for _, profile := range Profiles {
+		if profile == name {
+			return &provider.Profile{Name: name}, nil
+		}
+	}
+	return nil, newError("profile_not_found", http.StatusNotFound, "The volume profile %s could not be found", name)
+}
+
+// CreateVolume creates a volume with the name, capacity and zone of the
+// request
+func (s *Session) CreateVolume(volumeRequest provider.Volume) (*provider.Volume, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if volumeRequest.Name == nil || *volumeRequest.Name == "" {
+		return nil, newError("missing_name", http.StatusBadRequest, "The volume name is required")
+	}
+	if volumeRequest.Capacity == nil {
+		return nil, newError("missing_capacity", http.StatusBadRequest, "The volume capacity is required")
+	}
+	if !b.zones[volumeRequest.Az] {
+		return nil, newError("zone_not_found", http.StatusBadRequest, "The zone %q is not available", volumeRequest.Az)
+	}
+	if volumeRequest.Profile != nil {
+		if _, err := s.GetVolumeProfileByName(volumeRequest.Profile.Name); err != nil {
+			return nil, err
+		}
+	}
+	if b.volumeByName(*volumeRequest.Name) != nil {
+		return nil, newError("volume_name_duplicate", http.StatusConflict, "The volume name %s is already in use", *volumeRequest.Name)
+	}
+	if b.Quota.MaxVolumes > 0 && len(b.volumes) >= b.Quota.MaxVolumes {
+		return nil, newError("over_quota", http.StatusForbidden, "The quota of %d volumes is exceeded", b.Quota.MaxVolumes)
+	}
+	if err := b.checkCapacity(*volumeRequest.Capacity, 0); err != nil {
+		return nil, err
+	}
+
+	volume := copyVolume(&volumeRequest)
+	volume.VolumeID = b.newID("r-vol")
+	volume.CRN = "crn:v1:simulator:public:is:" + volume.Az + ":a/simulator::volume:" + volume.VolumeID
+	volume.Provider = ProviderName
+	volume.VolumeType = VolumeType
+	volume.Status = VolumeStatusAvailable
+	volume.CreationTime = b.now()
+	b.volumes[volume.VolumeID] = volume
+	return copyVolume(volume), nil
+}
+
+// CreateVolumeFromSnapshot creates a volume with the content and capacity of
+// snapshot, in the zone of its source volume
+func (s *Session) CreateVolumeFromSnapshot(snapshot provider.Snapshot, tags map[string]string) (*provider.Volume, error) {
+	b := s.backend
+	b.mux.Lock()
+	source, ok := b.snapshots[snapshot.SnapshotID]
+	if !ok {
+		b.mux.Unlock()
+		return nil, snapshotNotFound(snapshot.SnapshotID)
+	}
+	if !source.ReadyToUse {
+		b.mux.Unlock()
+		return nil, newError("snapshot_not_ready", http.StatusConflict, "The snapshot %s is not ready to use", snapshot.SnapshotID)
+	}
+	capacityGiB := int(source.SnapshotSize >> 30)
+	zone := b.snapshotZones[snapshot.SnapshotID]
+	b.mux.Unlock()
+
+	name := tags["name"]
+	if name == "" {
+		name = "restored-" + snapshot.SnapshotID
+	}
+	volume := provider.Volume{Name: &name, Capacity: &capacityGiB, Az: zone}
+	volume.Snapshot = *copySnapshot(source)
+	for key, value := range tags {
+		if key != "name" {
+			volume.Tags = append(volume.Tags, key+":"+value)
+		}
+	}
+	sort.Strings(volume.Tags)
+	return s.CreateVolume(volume)
+}
+
+// UpdateVolume replaces the tags and merges the attributes of a volume
+func (s *Session) UpdateVolume(volumeRequest provider.Volume) error {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	volume, ok := b.volumes[volumeRequest.VolumeID]
+	if !ok {
+		return volumeNotFound(volumeRequest.VolumeID)
+	}
+	if volumeRequest.Tags != nil {
+		volume.Tags = append([]string(nil), volumeRequest.Tags...)
+	}
+	if volumeRequest.Attributes != nil {
+		if volume.Attributes == nil {
+			volume.Attributes = map[string]string{}
+		}
+		for key, value := range volumeRequest.Attributes {
+			volume.Attributes[key] = value
+		}
+	}
+	if volumeRequest.Iops != nil {
+		volume.Iops = copyPtr(volumeRequest.Iops)
+	}
+	return nil
+}
+
+// DeleteVolume deletes a volume which is not attached
+func (s *Session) DeleteVolume(volumeRequest *provider.Volume) error {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if volumeRequest == nil {
+		return newError("missing_volume", http.StatusBadRequest, "The volume is required")
+	}
+	if _, ok := b.volumes[volumeRequest.VolumeID]; !ok {
+		return volumeNotFound(volumeRequest.VolumeID)
+	}
+	if attachment, ok := b.attachments[volumeRequest.VolumeID]; ok {
+		return newError("volume_in_use", http.StatusConflict, "The volume %s is attached to instance %s", volumeRequest.VolumeID, attachment.InstanceID)
+	}
+	delete(b.volumes, volumeRequest.VolumeID)
+	return nil
+}
+
+// GetVolume ...
+func (s *Session) GetVolume(id string) (*provider.Volume, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	volume, ok := b.volumes[id]
+	if !ok {
+		return nil, volumeNotFound(id)
+	}
+	return copyVolume(volume), nil
+}
+
+// GetVolumeByName ...
+func (s *Session) GetVolumeByName(name string) (*provider.Volume, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	volume := b.volumeByName(name)
+	if volume == nil {
+		return nil, newError("volume_not_found", http.StatusNotFound, "The volume with name %s could not be found", name)
+	}
+	return copyVolume(volume), nil
+}
+
+// ListVolumes lists the volumes in creation order, filtered by the "name",
+// "zone.name" and "tag" keys of tags. Next is the start of the next page.
+func (s *Session) ListVolumes(limit int, start string, tags map[string]string) (*provider.VolumeList, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for key := range tags {
+		if key != "name" && key != "zone.name" && key != "tag" {
+			return nil, newError("invalid_filter", http.StatusBadRequest, "The filter %s is not supported", key)
+		}
+	}
+	ids := []string{}
+	for _, id := range sortedIDs(b.volumes) {
+		volume := b.volumes[id]
+		if name, ok := tags["name"]; ok && (volume.Name == nil || *volume.Name != name) {
+			continue
+		}
+		if zone, ok := tags["zone.name"]; ok && volume.Az != zone {
+			continue
+		}
+		if tag, ok := tags["tag"]; ok && !hasTag(volume.Tags, tag) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	pageIDs, next, err := page(ids, limit, start)
+	if err != nil {
+		return nil, err
+	}
+	list := &provider.VolumeList{Next: next, Volumes: []*provider.Volume{}}
+	for _, id := range pageIDs {
+		list.Volumes = append(list.Volumes, copyVolume(b.volumes[id]))
+	}
+	return list, nil
+}
+
+// GetVolumeByRequestID is not supported by block storage
+func (s *Session) GetVolumeByRequestID(requestID string) (*provider.Volume, error) {
+	return nil, notSupported("GetVolumeByRequestID")
+}
+
+// AuthorizeVolume only checks that the volume exists, block volumes need no
+// authorization
+func (s *Session) AuthorizeVolume(volumeAuthorization provider.VolumeAuthorization) error {
+	_, err := s.GetVolume(volumeAuthorization.Volume.VolumeID)
+	return err
+}
+
+// ExpandVolume grows a volume to the requested capacity and returns it.
+// Volumes cannot shrink.
+func (s *Session) ExpandVolume(expandVolumeRequest provider.ExpandVolumeRequest) (int64, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	volume, ok := b.volumes[expandVolumeRequest.VolumeID]
+	if !ok {
+		return 0, volumeNotFound(expandVolumeRequest.VolumeID)
+	}
+	current := capacity(volume)
+	requested := int(expandVolumeRequest.Capacity)
+	if requested == current {
+		return int64(current), nil
+	}
+	if requested < current {
+		return 0, newError("invalid_capacity", http.StatusBadRequest, "The capacity %d GiB is less than the current capacity %d GiB", requested, current)
+	}
+	if err := b.checkCapacity(requested, current); err != nil {
+		return 0, err
+	}
+	volume.Capacity = &requested
+	return int64(requested), nil
+}
+
+// AttachVolume attaches a volume to an instance of its zone. Attaching it
+// again to the same instance returns the existing attachment.
+func (s *Session) AttachVolume(attachRequest provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	volume, ok := b.volumes[attachRequest.VolumeID]
+	if !ok {
+		return nil, volumeNotFound(attachRequest.VolumeID)
+	}
+	zone, ok := b.instances[attachRequest.InstanceID]
+	if !ok {
+		return nil, newError("instance_not_found", http.StatusNotFound, "The instance %s could not be found", attachRequest.InstanceID)
+	}
+	if zone != volume.Az {
+		return nil, newError("zone_mismatch", http.StatusBadRequest, "The volume %s in zone %s cannot be attached to instance %s in zone %s", volume.VolumeID, volume.Az, attachRequest.InstanceID, zone)
+	}
+	if attachment, ok := b.attachments[volume.VolumeID]; ok {
+		if attachment.InstanceID == attachRequest.InstanceID {
+			return copyAttachment(attachment), nil
+		}
+		return nil, newError("volume_in_use", http.StatusConflict, "The volume %s is attached to instance %s", volume.VolumeID, attachment.InstanceID)
+	}
+	attached := b.instanceAttachments(attachRequest.InstanceID)
+	if b.Quota.MaxAttachmentsPerInstance > 0 && attached >= b.Quota.MaxAttachmentsPerInstance {
+		return nil, newError("over_quota", http.StatusForbidden, "The quota of %d volumes per instance is exceeded", b.Quota.MaxAttachmentsPerInstance)
+	}
+
+	now := b.now()
+	attachment := &provider.VolumeAttachmentResponse{
+		VolumeAttachmentRequest: provider.VolumeAttachmentRequest{
+			VolumeID:   volume.VolumeID,
+			InstanceID: attachRequest.InstanceID,
+			VPCVolumeAttachment: &provider.VolumeAttachment{
+				ID:         b.newID("r-att"),
+				Type:       "data",
+				DevicePath: fmt.Sprintf("/dev/disk/by-id/virtio-%s", strings.TrimPrefix(volume.VolumeID, "r-")),
+			},
+		},
+		Status:    AttachmentStatusAttached,
+		CreatedAt: &now,
+	}
+	b.attachments[volume.VolumeID] = attachment
+	volume.VolumeAttachments = &[]provider.VolumeAttachment{*attachment.VPCVolumeAttachment}
+	return copyAttachment(attachment), nil
+}
+
+// DetachVolume detaches a volume from the instance it is attached to
+func (s *Session) DetachVolume(detachRequest provider.VolumeAttachmentRequest) (*http.Response, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	attachment, err := b.attachment(detachRequest)
+	if err != nil {
+		return nil, err
+	}
+	delete(b.attachments, attachment.VolumeID)
+	if volume, ok := b.volumes[attachment.VolumeID]; ok {
+		volume.VolumeAttachments = nil
+	}
+	return &http.Response{StatusCode: http.StatusNoContent, Status: http.StatusText(http.StatusNoContent)}, nil
+}
+
+// WaitForAttachVolume returns the attachment, which is ready right away
+func (s *Session) WaitForAttachVolume(attachRequest provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	return s.GetVolumeAttachment(attachRequest)
+}
+
+// WaitForDetachVolume fails if the volume is still attached to the instance
+func (s *Session) WaitForDetachVolume(detachRequest provider.VolumeAttachmentRequest) error {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if _, err := b.attachment(detachRequest); err == nil {
+		return newError("timeout", http.StatusRequestTimeout, "The volume %s is still attached to instance %s", detachRequest.VolumeID, detachRequest.InstanceID)
+	}
+	return nil
+}
+
+// GetVolumeAttachment ...
+func (s *Session) GetVolumeAttachment(attachRequest provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	attachment, err := b.attachment(attachRequest)
+	if err != nil {
+		return nil, err
+	}
+	return copyAttachment(attachment), nil
+}
+
+// OrderSnapshot is not supported, use CreateSnapshot
+func (s *Session) OrderSnapshot(volumeRequest provider.Volume) error {
+	return notSupported("OrderSnapshot")
+}
+
+// CreateSnapshot creates a snapshot of a volume, ready to use right away
+func (s *Session) CreateSnapshot(sourceVolumeID string, snapshotParameters provider.SnapshotParameters) (*provider.Snapshot, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	volume, ok := b.volumes[sourceVolumeID]
+	if !ok {
+		return nil, volumeNotFound(sourceVolumeID)
+	}
+	if snapshotParameters.Name != "" && b.snapshotByName(snapshotParameters.Name) != nil {
+		return nil, newError("snapshot_name_duplicate", http.StatusConflict, "The snapshot name %s is already in use", snapshotParameters.Name)
+	}
+	if b.Quota.MaxSnapshotsPerVolume > 0 && b.volumeSnapshots(sourceVolumeID) >= b.Quota.MaxSnapshotsPerVolume {
+		return nil, newError("over_quota", http.StatusForbidden, "The quota of %d snapshots per volume is exceeded", b.Quota.MaxSnapshotsPerVolume)
+	}
+
+	snapshot := &provider.Snapshot{
+		VolumeID:             sourceVolumeID,
+		SnapshotID:           b.newID("r-snap"),
+		SnapshotSize:         int64(capacity(volume)) << 30,
+		SnapshotCreationTime: b.now(),
+		SnapshotTags:         copyMap(snapshotParameters.SnapshotTags),
+		ReadyToUse:           true,
+	}
+	snapshot.SnapshotCRN = "crn:v1:simulator:public:is::a/simulator::snapshot:" + snapshot.SnapshotID
+	snapshot.VPC = provider.VPC{ID: snapshot.SnapshotID, CRN: snapshot.SnapshotCRN, Name: snapshotParameters.Name}
+	b.snapshots[snapshot.SnapshotID] = snapshot
+	b.snapshotZones[snapshot.SnapshotID] = volume.Az
+	return copySnapshot(snapshot), nil
+}
+
+// DeleteSnapshot ...
+func (s *Session) DeleteSnapshot(snapshotRequest *provider.Snapshot) error {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if snapshotRequest == nil {
+		return newError("missing_snapshot", http.StatusBadRequest, "The snapshot is required")
+	}
+	if _, ok := b.snapshots[snapshotRequest.SnapshotID]; !ok {
+		return snapshotNotFound(snapshotRequest.SnapshotID)
+	}
+	delete(b.snapshots, snapshotRequest.SnapshotID)
+	delete(b.snapshotZones, snapshotRequest.SnapshotID)
+	return nil
+}
+
+// GetSnapshot returns a snapshot, of sourceVolumeID if given
+func (s *Session) GetSnapshot(snapshotID string, sourceVolumeID ...string) (*provider.Snapshot, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	snapshot, ok := b.snapshots[snapshotID]
+	if !ok || (len(sourceVolumeID) > 0 && sourceVolumeID[0] != "" && snapshot.VolumeID != sourceVolumeID[0]) {
+		return nil, snapshotNotFound(snapshotID)
+	}
+	return copySnapshot(snapshot), nil
+}
+
+// GetSnapshotByName ...
+func (s *Session) GetSnapshotByName(snapshotName string, scopeID ...string) (*provider.Snapshot, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	snapshot := b.snapshotByName(snapshotName)
+	if snapshot == nil {
+		return nil, newError("snapshot_not_found", http.StatusNotFound, "The snapshot with name %s could not be found", snapshotName)
+	}
+	return copySnapshot(snapshot), nil
+}
+
+// ListSnapshots lists the snapshots in creation order, filtered by the "name"
+// and "source_volume.id" keys of tags. Next is the start of the next page.
+func (s *Session) ListSnapshots(limit int, start string, tags map[string]string) (*provider.SnapshotList, error) {
+	b := s.backend
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for key := range tags {
+		if key != "name" && key != "source_volume.id" {
+			return nil, newError("invalid_filter", http.StatusBadRequest, "The filter %s is not supported", key)
+		}
+	}
+	ids := []string{}
+	for _, id := range sortedIDs(b.snapshots) {
+		snapshot := b.snapshots[id]
+		if name, ok := tags["name"]; ok && snapshot.VPC.Name != name {
+			continue
+		}
+		if volumeID, ok := tags["source_volume.id"]; ok && snapshot.VolumeID != volumeID {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	pageIDs, next, err := page(ids, limit, start)
+	if err != nil {
+		return nil, err
+	}
+	list := &provider.SnapshotList{Next: next, Snapshots: []*provider.Snapshot{}}
+	for _, id := range pageIDs {
+		list.Snapshots = append(list.Snapshots, copySnapshot(b.snapshots[id]))
+	}
+	return list, nil
+}
+
+// CreateVolumeAccessPoint is not supported by block storage
+func (s *Session) CreateVolumeAccessPoint(accessPointRequest provider.VolumeAccessPointRequest) (*provider.VolumeAccessPointResponse, error) {
+	return nil, notSupported("CreateVolumeAccessPoint")
+}
+
+// DeleteVolumeAccessPoint is not supported by block storage
+func (s *Session) DeleteVolumeAccessPoint(deleteAccessPointRequest provider.VolumeAccessPointRequest) (*http.Response, error) {
+	return nil, notSupported("DeleteVolumeAccessPoint")
+}
+
+// WaitForCreateVolumeAccessPoint is not supported by block storage
+func (s *Session) WaitForCreateVolumeAccessPoint(accessPointRequest provider.VolumeAccessPointRequest) (*provider.VolumeAccessPointResponse, error) {
+	return nil, notSupported("WaitForCreateVolumeAccessPoint")
+}
+
+// WaitForDeleteVolumeAccessPoint is not supported by block storage
+func (s *Session) WaitForDeleteVolumeAccessPoint(deleteAccessPointRequest provider.VolumeAccessPointRequest) error {
+	return notSupported("WaitForDeleteVolumeAccessPoint")
+}
+
+// GetVolumeAccessPoint is not supported by block storage
+func (s *Session) GetVolumeAccessPoint(accessPointRequest provider.VolumeAccessPointRequest) (*provider.VolumeAccessPointResponse, error) {
+	return nil, notSupported("GetVolumeAccessPoint")
+}
+
+// GetSubnetForVolumeAccessPoint is not supported by block storage
+func (s *Session) GetSubnetForVolumeAccessPoint(subnetRequest provider.SubnetRequest) (string, error) {
+	return "", notSupported("GetSubnetForVolumeAccessPoint")
+}
+
+// GetSecurityGroupForVolumeAccessPoint is not supported by block storage
+func (s *Session) GetSecurityGroupForVolumeAccessPoint(securityGroupRequest provider.SecurityGroupRequest) (string, error) {
+	return "", notSupported("GetSecurityGroupForVolumeAccessPoint")
+}
+
+// volumeByName ... b.mux must be held
+func (b *Backend) volumeByName(name string) *provider.Volume {
+	for _, volume := range b.volumes {
+		if volume.Name != nil && *volume.Name == name {
+			return volume
+		}
+	}
+	return nil
+}
+
+// snapshotByName ... b.mux must be held
+func (b *Backend) snapshotByName(name string) *provider.Snapshot {
+	for _, snapshot := range b.snapshots {
+		if snapshot.VPC.Name == name {
+			return snapshot
+		}
+	}
+	return nil
+}
+
+// volumeSnapshots returns the number of snapshots of a volume. b.mux must be
+// held.
+func (b *Backend) volumeSnapshots(volumeID string) int {
+	count := 0
+	for _, snapshot := range b.snapshots {
+		if snapshot.VolumeID == volumeID {
+			count++
+		}
+	}
+	return count
+}
+
+// instanceAttachments returns the number of volumes attached to an instance.
+// b.mux must be held.
+func (b *Backend) instanceAttachments(instanceID string) int {
+	count := 0
+	for _, attachment := range b.attachments {
+		if attachment.InstanceID == instanceID {
+			count++
+		}
+	}
+	return count
+}
+
+// attachment returns the attachment of request. b.mux must be held.
+func (b *Backend) attachment(request provider.VolumeAttachmentRequest) (*provider.VolumeAttachmentResponse, error) {
+	attachment, ok := b.attachments[request.VolumeID]
+	if !ok || attachment.InstanceID != request.InstanceID {
+		return nil, newError("volume_attachment_not_found", http.StatusNotFound, "The volume %s is not attached to instance %s", request.VolumeID, request.InstanceID)
+	}
+	return attachment, nil
+}
+
+// copyAttachment returns a copy of attachment which shares no state with it
+func copyAttachment(attachment *provider.VolumeAttachmentResponse) *provider.VolumeAttachmentResponse {
+	c := *attachment
+	c.VPCVolumeAttachment = copyPtr(attachment.VPCVolumeAttachment)
+	c.CreatedAt = copyPtr(attachment.CreatedAt)
+	return &c
+}
+
+// notSupported ...
+func notSupported(method string) error {
+	return newError("not_supported", http.StatusNotImplemented, "%s is not supported by the simulator", method)
+}
@@ -0,0 +1,361 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package simulator ...
+package simulator
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/messages"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	testZone      = "us-south-1"
+	otherZone     = "us-south-2"
+	testInstance  = "instance-1"
+	otherInstance = "instance-2"
+)
+
+func newTestSession(t *testing.T) (*Session, *Backend) {
+	backend := NewBackend(testZone, otherZone)
+	backend.AddInstance(testInstance, testZone)
+	backend.AddInstance(otherInstance, otherZone)
+	session, err := NewCloudProvider(backend).GetProviderSession(context.Background(), zap.NewNop())
+	assert.Nil(t, err)
+	return session.(*Session), backend
+}
+
+func volumeRequest(name string, capacity int, zone string) provider.Volume {
+	return provider.Volume{Name: &name, Capacity: &capacity, Az: zone}
+}
+
+func createVolume(t *testing.T, session *Session, name string, capacity int) *provider.Volume {
+	volume, err := session.CreateVolume(volumeRequest(name, capacity, testZone))
+	assert.Nil(t, err)
+	return volume
+}
+
+// assertBackendCode checks that err is a library error with the gRPC code
+func assertBackendCode(t *testing.T, expected codes.Code, err error) {
+	_, ok := messages.ParseBackendError(err)
+	assert.True(t, ok, "not a backend error: %v", err)
+	assert.Equal(t, expected, messages.BackendErrorCode(err))
+}
+
+func TestCreateVolume(t *testing.T) {
+	session, backend := newTestSession(t)
+	backend.Quota = Quota{MaxVolumes: 2, MaxCapacityGiB: 100}
+	createVolume(t, session, "existing", 10)
+	unknownProfile := volumeRequest("profile", 10, testZone)
+	unknownProfile.Profile = &provider.Profile{Name: "unknown"}
+
+	testCases := []struct {
+		testCaseName string
+		request      provider.Volume
+		expectedCode codes.Code
+	}{
+		{testCaseName: "duplicate name", request: volumeRequest("existing", 10, testZone), expectedCode: codes.AlreadyExists},
+		{testCaseName: "no name", request: provider.Volume{Az: testZone}, expectedCode: codes.InvalidArgument},
+		{testCaseName: "no capacity", request: provider.Volume{Name: unknownProfile.Name, Az: testZone}, expectedCode: codes.InvalidArgument},
+		{testCaseName: "too small", request: volumeRequest("small", 1, testZone), expectedCode: codes.InvalidArgument},
+		{testCaseName: "unknown zone", request: volumeRequest("zone", 10, "eu-de-1"), expectedCode: codes.InvalidArgument},
+		{testCaseName: "unknown profile", request: unknownProfile, expectedCode: codes.NotFound},
+		{testCaseName: "over capacity quota", request: volumeRequest("large", 100, testZone), expectedCode: codes.ResourceExhausted},
+		{testCaseName: "success", request: volumeRequest("new", 20, otherZone), expectedCode: codes.OK},
+		{testCaseName: "over volume quota", request: volumeRequest("third", 10, testZone), expectedCode: codes.ResourceExhausted},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			volume, err := session.CreateVolume(testcase.request)
+			if testcase.expectedCode != codes.OK {
+				assert.Nil(t, volume)
+				assertBackendCode(t, testcase.expectedCode, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.NotEmpty(t, volume.VolumeID)
+			assert.Equal(t, VolumeStatusAvailable, volume.Status)
+			assert.Equal(t, otherZone, volume.Az)
+
+			// The backend keeps its own copy
+			*volume.Capacity = 1000
+			stored, err := session.GetVolume(volume.VolumeID)
+			assert.Nil(t, err)
+			assert.Equal(t, 20, *stored.Capacity)
+			byName, err := session.GetVolumeByName("new")
+			assert.Nil(t, err)
+			assert.Equal(t, volume.VolumeID, byName.VolumeID)
+		})
+	}
+	assert.Equal(t, 2, len(backend.Volumes()))
+}
+
+func TestUpdateAndExpandVolume(t *testing.T) {
+	session, backend := newTestSession(t)
+	backend.Quota = Quota{MaxCapacityGiB: 50}
+	volume := createVolume(t, session, "volume", 20)
+	createVolume(t, session, "other", 10)
+
+	err := session.UpdateVolume(provider.Volume{VolumeID: volume.VolumeID, VPCVolume: provider.VPCVolume{Tags: []string{"pv:pv-1"}}, Attributes: map[string]string{"status": "created"}})
+	assert.Nil(t, err)
+	err = session.UpdateVolume(provider.Volume{VolumeID: volume.VolumeID, Attributes: map[string]string{"clusterid": "c1"}})
+	assert.Nil(t, err)
+	updated, _ := session.GetVolume(volume.VolumeID)
+	assert.Equal(t, []string{"pv:pv-1"}, updated.Tags)
+	assert.Equal(t, map[string]string{"status": "created", "clusterid": "c1"}, updated.Attributes)
+	assertBackendCode(t, codes.NotFound, session.UpdateVolume(provider.Volume{VolumeID: "unknown"}))
+
+	testCases := []struct {
+		testCaseName     string
+		capacity         int64
+		expectedCapacity int64
+		expectedCode     codes.Code
+	}{
+		{testCaseName: "grow", capacity: 30, expectedCapacity: 30},
+		{testCaseName: "same capacity", capacity: 30, expectedCapacity: 30},
+		{testCaseName: "shrink", capacity: 20, expectedCode: codes.InvalidArgument},
+		{testCaseName: "over quota", capacity: 41, expectedCode: codes.ResourceExhausted},
+		{testCaseName: "up to quota", capacity: 40, expectedCapacity: 40},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			newCapacity, err := session.ExpandVolume(provider.ExpandVolumeRequest{VolumeID: volume.VolumeID, Capacity: testcase.capacity})
+			if testcase.expectedCode != codes.OK {
+				assertBackendCode(t, testcase.expectedCode, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, testcase.expectedCapacity, newCapacity)
+		})
+	}
+}
+
+func TestAttachDetachVolume(t *testing.T) {
+	session, backend := newTestSession(t)
+	backend.Quota = Quota{MaxAttachmentsPerInstance: 1}
+	volume := createVolume(t, session, "volume", 10)
+	second := createVolume(t, session, "second", 10)
+	request := provider.VolumeAttachmentRequest{VolumeID: volume.VolumeID, InstanceID: testInstance}
+
+	attachment, err := session.AttachVolume(request)
+	assert.Nil(t, err)
+	assert.Equal(t, AttachmentStatusAttached, attachment.Status)
+	assert.NotEmpty(t, attachment.VPCVolumeAttachment.DevicePath)
+	again, err := session.WaitForAttachVolume(request)
+	assert.Nil(t, err)
+	assert.Equal(t, attachment.VPCVolumeAttachment.ID, again.VPCVolumeAttachment.ID)
+	attached, _ := session.GetVolume(volume.VolumeID)
+	assert.Equal(t, 1, len(*attached.VolumeAttachments))
+
+	testCases := []struct {
+		testCaseName string
+		request      provider.VolumeAttachmentRequest
+		expectedCode codes.Code
+	}{
+		{testCaseName: "same instance", request: request, expectedCode: codes.OK},
+		{testCaseName: "other instance", request: provider.VolumeAttachmentRequest{VolumeID: volume.VolumeID, InstanceID: otherInstance}, expectedCode: codes.InvalidArgument},
+		{testCaseName: "unknown instance", request: provider.VolumeAttachmentRequest{VolumeID: volume.VolumeID, InstanceID: "unknown"}, expectedCode: codes.NotFound},
+		{testCaseName: "unknown volume", request: provider.VolumeAttachmentRequest{VolumeID: "unknown", InstanceID: testInstance}, expectedCode: codes.NotFound},
+		{testCaseName: "over quota", request: provider.VolumeAttachmentRequest{VolumeID: second.VolumeID, InstanceID: testInstance}, expectedCode: codes.ResourceExhausted},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			_, err := session.AttachVolume(testcase.request)
+			if testcase.expectedCode != codes.OK {
+				assertBackendCode(t, testcase.expectedCode, err)
+				return
+			}
+			assert.Nil(t, err)
+		})
+	}
+
+	// An attached volume cannot be deleted
+	assertBackendCode(t, codes.FailedPrecondition, session.DeleteVolume(volume))
+	assertBackendCode(t, codes.DeadlineExceeded, session.WaitForDetachVolume(request))
+
+	response, err := session.DetachVolume(request)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Nil(t, session.WaitForDetachVolume(request))
+	_, err = session.DetachVolume(request)
+	assertBackendCode(t, codes.NotFound, err)
+	_, err = session.GetVolumeAttachment(request)
+	assertBackendCode(t, codes.NotFound, err)
+
+	assert.Nil(t, session.DeleteVolume(volume))
+	_, err = session.GetVolume(volume.VolumeID)
+	assertBackendCode(t, codes.NotFound, err)
+	assertBackendCode(t, codes.NotFound, session.DeleteVolume(volume))
+}
+
+func TestSnapshots(t *testing.T) {
+	session, backend := newTestSession(t)
+	backend.Quota = Quota{MaxSnapshotsPerVolume: 1}
+	volume, err := session.CreateVolume(volumeRequest("volume", 20, otherZone))
+	assert.Nil(t, err)
+
+	snapshot, err := session.CreateSnapshot(volume.VolumeID, provider.SnapshotParameters{Name: "snap", SnapshotTags: provider.SnapshotTags{"pv": "pv-1"}})
+	assert.Nil(t, err)
+	assert.True(t, snapshot.ReadyToUse)
+	assert.Equal(t, int64(20)<<30, snapshot.SnapshotSize)
+	_, err = session.CreateSnapshot(volume.VolumeID, provider.SnapshotParameters{Name: "snap"})
+	assertBackendCode(t, codes.AlreadyExists, err)
+	_, err = session.CreateSnapshot(volume.VolumeID, provider.SnapshotParameters{Name: "second"})
+	assertBackendCode(t, codes.ResourceExhausted, err)
+	_, err = session.CreateSnapshot("unknown", provider.SnapshotParameters{})
+	assertBackendCode(t, codes.NotFound, err)
+
+	byName, err := session.GetSnapshotByName("snap")
+	assert.Nil(t, err)
+	assert.Equal(t, snapshot.SnapshotID, byName.SnapshotID)
+	_, err = session.GetSnapshot(snapshot.SnapshotID, volume.VolumeID)
+	assert.Nil(t, err)
+	_, err = session.GetSnapshot(snapshot.SnapshotID, "other-volume")
+	assertBackendCode(t, codes.NotFound, err)
+
+	// Restored volumes are in the zone of the source volume
+	restored, err := session.CreateVolumeFromSnapshot(*snapshot, map[string]string{"name": "restored", "pvc": "pvc-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, otherZone, restored.Az)
+	assert.Equal(t, 20, *restored.Capacity)
+	assert.Equal(t, []string{"pvc:pvc-1"}, restored.Tags)
+	assert.Equal(t, snapshot.SnapshotID, restored.Snapshot.SnapshotID)
+
+	assert.Nil(t, session.DeleteSnapshot(snapshot))
+	assertBackendCode(t, codes.NotFound, session.DeleteSnapshot(snapshot))
+	_, err = session.CreateVolumeFromSnapshot(*snapshot, nil)
+	assertBackendCode(t, codes.NotFound, err)
+	assert.Equal(t, 0, len(backend.Snapshots()))
+}
+
+func TestListVolumesPagination(t *testing.T) {
+	session, _ := newTestSession(t)
+	ids := []string{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		ids = append(ids, createVolume(t, session, name, 10).VolumeID)
+	}
+	tagged := ids[3]
+	assert.Nil(t, session.UpdateVolume(provider.Volume{VolumeID: tagged, VPCVolume: provider.VPCVolume{Tags: []string{"PV:pv-1"}}}))
+
+	// Walk all pages
+	listed := []string{}
+	start := ""
+	pages := 0
+	for {
+		list, err := session.ListVolumes(2, start, nil)
+		assert.Nil(t, err)
+		for _, volume := range list.Volumes {
+			listed = append(listed, volume.VolumeID)
+		}
+		pages++
+		if list.Next == "" {
+			break
+		}
+		start = list.Next
+	}
+	assert.Equal(t, ids, listed)
+	assert.Equal(t, 3, pages)
+
+	testCases := []struct {
+		testCaseName string
+		limit        int
+		start        string
+		tags         map[string]string
+		expectedIDs  []string
+		expectedCode codes.Code
+	}{
+		{testCaseName: "default limit", expectedIDs: ids},
+		{testCaseName: "by name", tags: map[string]string{"name": "b"}, expectedIDs: ids[1:2]},
+		{testCaseName: "by zone", tags: map[string]string{"zone.name": otherZone}, expectedIDs: []string{}},
+		{testCaseName: "by tag", tags: map[string]string{"tag": "pv:pv-1"}, expectedIDs: []string{tagged}},
+		{testCaseName: "unknown filter", tags: map[string]string{"owner": "me"}, expectedCode: codes.InvalidArgument},
+		{testCaseName: "limit too large", limit: MaxPageSize + 1, expectedCode: codes.InvalidArgument},
+		{testCaseName: "unknown start", start: "unknown", expectedCode: codes.InvalidArgument},
+	}
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			list, err := session.ListVolumes(testcase.limit, testcase.start, testcase.tags)
+			if testcase.expectedCode != codes.OK {
+				assertBackendCode(t, testcase.expectedCode, err)
+				return
+			}
+			assert.Nil(t, err)
+			listedIDs := []string{}
+			for _, volume := range list.Volumes {
+				listedIDs = append(listedIDs, volume.VolumeID)
+			}
+			assert.Equal(t, testcase.expectedIDs, listedIDs)
+			assert.Empty(t, list.Next)
+		})
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	session, _ := newTestSession(t)
+	first := createVolume(t, session, "first", 10)
+	second := createVolume(t, session, "second", 10)
+	ids := []string{}
+	for i, volume := range []*provider.Volume{first, second, first} {
+		snapshot, err := session.CreateSnapshot(volume.VolumeID, provider.SnapshotParameters{Name: string(rune('a' + i))})
+		assert.Nil(t, err)
+		ids = append(ids, snapshot.SnapshotID)
+	}
+
+	list, err := session.ListSnapshots(2, "", map[string]string{"source_volume.id": first.VolumeID})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(list.Snapshots))
+	assert.Equal(t, ids[0], list.Snapshots[0].SnapshotID)
+	assert.Equal(t, ids[2], list.Snapshots[1].SnapshotID)
+	assert.Empty(t, list.Next)
+
+	list, err = session.ListSnapshots(1, "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, ids[1], list.Next)
+	_, err = session.ListSnapshots(0, "", map[string]string{"zone": testZone})
+	assertBackendCode(t, codes.InvalidArgument, err)
+}
+
+func TestNotSupported(t *testing.T) {
+	session, _ := newTestSession(t)
+	_, err := session.GetVolumeByRequestID("request")
+	assertBackendCode(t, codes.Unimplemented, err)
+	assertBackendCode(t, codes.Unimplemented, session.OrderSnapshot(provider.Volume{}))
+	_, err = session.CreateVolumeAccessPoint(provider.VolumeAccessPointRequest{})
+	assertBackendCode(t, codes.Unimplemented, err)
+}
+
+func TestSessionsShareBackend(t *testing.T) {
+	backend := NewBackend(testZone)
+	cloudProvider := NewCloudProvider(backend)
+	assert.Equal(t, ClusterID, cloudProvider.GetClusterID())
+	assert.Equal(t, string(ProviderName), cloudProvider.GetConfig().VPC.VPCBlockProviderType)
+
+	first, _ := cloudProvider.GetProviderSession(context.Background(), zap.NewNop())
+	second, _ := cloudProvider.GetProviderSession(context.Background(), zap.NewNop())
+	volume, err := first.CreateVolume(volumeRequest("volume", 10, testZone))
+	assert.Nil(t, err)
+	first.Close()
+	_, err = second.GetVolume(volume.VolumeID)
+	assert.Nil(t, err)
+}
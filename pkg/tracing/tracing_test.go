@@ -0,0 +1,225 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tracing ...
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// useRecorder installs a global provider recording spans in memory
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return recorder
+}
+
+func attributeValue(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestSetupDisabled(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "vpc.block.csi.ibm.io"})
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+func TestSetupFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	path := filepath.Join(t.TempDir(), "spans.json")
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "vpc.block.csi.ibm.io", FilePath: path})
+	assert.Nil(t, err)
+
+	ctx := utils.ContextWithRequestID(context.Background(), "req-1")
+	_, span := StartSpan(ctx, "CreateVolume", attribute.String("volumeID", "vol-1"))
+	EndSpan(span, errors.New("backend failure"))
+	assert.Nil(t, shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"Name":"CreateVolume"`)
+	assert.Contains(t, string(data), "req-1")
+	assert.Contains(t, string(data), "backend failure")
+	assert.Contains(t, string(data), "vpc.block.csi.ibm.io")
+}
+
+func TestSetupSampleRatio(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	testCases := []struct {
+		testCaseName      string
+		sampleRatio       *float64
+		expectedRecording bool
+	}{
+		{
+			testCaseName:      "Default samples everything",
+			sampleRatio:       nil,
+			expectedRecording: true,
+		},
+		{
+			testCaseName:      "Zero samples nothing",
+			sampleRatio:       new(float64),
+			expectedRecording: false,
+		},
+		{
+			testCaseName:      "One samples everything",
+			sampleRatio:       func() *float64 { ratio := 1.0; return &ratio }(),
+			expectedRecording: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "spans.json")
+			shutdown, err := Setup(context.Background(), Config{FilePath: path, SampleRatio: tc.sampleRatio})
+			assert.Nil(t, err)
+			_, span := StartSpan(context.Background(), "CreateVolume")
+			assert.Equal(t, tc.expectedRecording, span.IsRecording())
+			span.End()
+			assert.Nil(t, shutdown(context.Background()))
+		})
+	}
+}
+
+func TestSetupFileExporterInvalidPath(t *testing.T) {
+	_, err := Setup(context.Background(), Config{FilePath: filepath.Join(t.TempDir(), "missing", "spans.json")})
+	assert.NotNil(t, err)
+}
+
+func TestEndSpan(t *testing.T) {
+	recorder := useRecorder(t)
+	_, span := StartSpan(context.Background(), "ok")
+	EndSpan(span, nil)
+	_, span = StartSpan(context.Background(), "failed")
+	EndSpan(span, errors.New("failed"))
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, otelcodes.Unset, spans[0].Status().Code)
+	assert.Equal(t, otelcodes.Error, spans[1].Status().Code)
+	assert.Equal(t, 1, len(spans[1].Events())) // recorded error
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	recorder := useRecorder(t)
+
+	// The caller's trace is continued
+	parentCtx, parent := Tracer().Start(context.Background(), "sidecar")
+	md := metadata.Pairs(utils.RequestIDMetadataKey, "req-1")
+	otel.GetTextMapPropagator().Inject(parentCtx, metadataCarrier(md))
+	parent.End()
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+
+	interceptor := UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/csi.v1.Controller/CreateVolume"}
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "volume not found")
+	})
+	assert.NotNil(t, err)
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	span := spans[1]
+	assert.Equal(t, "/csi.v1.Controller/CreateVolume", span.Name())
+	assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Equal(t, int64(codes.NotFound), attributeValue(span.Attributes(), "rpc.grpc.status_code").AsInt64())
+	assert.Equal(t, "req-1", attributeValue(span.Attributes(), RequestIDAttribute).AsString())
+	assert.Equal(t, otelcodes.Error, span.Status().Code)
+	assert.Equal(t, "volume not found", span.Status().Description)
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	recorder := useRecorder(t)
+	interceptor := StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/provider.APIKeyProvider/Watch"}
+	err := interceptor(nil, &fakeServerStream{ctx: context.Background()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		_, span := StartSpan(ss.Context(), "child")
+		EndSpan(span, nil)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "child", spans[0].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, int64(codes.OK), attributeValue(spans[1].Attributes(), "rpc.grpc.status_code").AsInt64())
+}
+
+func TestHTTPTransport(t *testing.T) {
+	recorder := useRecorder(t)
+	var traceParent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceParent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	ctx, span := StartSpan(context.Background(), "MountEITBasedFileShare")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL, nil)
+	client := &http.Client{Transport: HTTPTransport(http.DefaultTransport)}
+	resp, err := client.Do(req)
+	assert.Nil(t, err)
+	_ = resp.Body.Close()
+	EndSpan(span, nil)
+
+	assert.Contains(t, traceParent, span.SpanContext().TraceID().String())
+	assert.Equal(t, 2, len(recorder.Ended()))
+}
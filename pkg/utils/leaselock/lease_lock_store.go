@@ -0,0 +1,401 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package leaselock ...
+package leaselock
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"go.uber.org/zap"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultLeaseDuration is how long a lease stays valid without renewal
+	DefaultLeaseDuration = 30 * time.Second
+
+	// DefaultRenewInterval is how often a held lease is renewed
+	DefaultRenewInterval = 10 * time.Second
+
+	// DefaultRetryInterval is how often a busy lease is polled while waiting
+	DefaultRetryInterval = 1 * time.Second
+
+	// LockNameAnnotation records the original lock name on the Lease object
+	LockNameAnnotation = "ibm-csi-common/lock-name"
+
+	// leaseNamePrefix ...
+	leaseNamePrefix = "csi-lock-"
+
+	// maxLeaseNameBody keeps lease names well below the 253 character limit
+	maxLeaseNameBody = 200
+)
+
+// errLeaseLost is returned by renewLease once the lease is no longer ours
+var errLeaseLost = errors.New("lease lost")
+
+// LeaseLockStore provides named locks that are exclusive across processes.
+// Each name is backed by a coordination.k8s.io/v1 Lease in namespace which is
+// renewed while held and deleted on Unlock. A lease that is not renewed
+// within LeaseDuration, e.g. because its holder crashed, can be taken over.
+// Callers in the same process are serialised by an in-process LockStore
+// before the Lease is contended.
+type LeaseLockStore struct {
+	// LeaseDuration is rounded up to whole seconds, the granularity of Lease
+	// objects
+	LeaseDuration time.Duration
+
+	// RenewInterval is clamped to at most LeaseDuration/3 so that a held
+	// lease survives a missed renewal
+	RenewInterval time.Duration
+
+	// RetryInterval ...
+	RetryInterval time.Duration
+
+	// OnLost is optional and is called when a held lock is lost because its
+	// lease was taken over or deleted, or could not be renewed before it
+	// expired. The holder must stop relying on the lock and still Unlock it.
+	OnLost func(name string)
+
+	logger    *zap.Logger
+	client    kubernetes.Interface
+	namespace string
+	identity  string
+
+	local    utils.LockStore
+	mux      sync.Mutex
+	renewals map[string]context.CancelFunc
+}
+
+var _ utils.Locker = &LeaseLockStore{}
+
+// New creates a LeaseLockStore holding leases in namespace as identity.
+// identity must be unique per replica, e.g. the pod name.
+func New(logger *zap.Logger, client kubernetes.Interface, namespace string, identity string) *LeaseLockStore {
+	return &LeaseLockStore{
+		LeaseDuration: DefaultLeaseDuration,
+		RenewInterval: DefaultRenewInterval,
+		RetryInterval: DefaultRetryInterval,
+		logger:        logger,
+		client:        client,
+		namespace:     namespace,
+		identity:      identity,
+		renewals:      make(map[string]context.CancelFunc),
+	}
+}
+
+// LeaseName returns the Lease object name used for the lock name. Characters
+// not allowed in object names are replaced and a hash of the original name
+// is appended so that distinct names never share a Lease.
+func LeaseName(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	body := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, name)
+	if len(body) > maxLeaseNameBody {
+		body = body[:maxLeaseNameBody]
+	}
+	body = strings.Trim(body, "-.")
+	if body == "" {
+		return leaseNamePrefix + hex.EncodeToString(sum[:8])
+	}
+	return leaseNamePrefix + body + "-" + hex.EncodeToString(sum[:4])
+}
+
+// Lock blocks until the named lock is acquired. API errors are logged and
+// retried indefinitely; use LockWithContext to bound the wait.
+func (s *LeaseLockStore) Lock(name string) {
+	_ = s.LockWithContext(context.Background(), name)
+}
+
+// LockWithContext blocks until the named lock is acquired or ctx is done.
+// API errors while acquiring are logged and retried. If ctx is done after an
+// API error, the returned error wraps ctx.Err() and the last API error.
+func (s *LeaseLockStore) LockWithContext(ctx context.Context, name string) error {
+	if !*utils.LockEnabled {
+		return nil
+	}
+	if err := s.local.LockWithContext(ctx, name); err != nil {
+		return err
+	}
+	var lastErr error
+	for {
+		acquired, err := s.tryAcquireLease(ctx, name)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Warn("Failed to acquire lease", zap.String("name", name), zap.Error(err))
+			lastErr = err
+		}
+		if acquired {
+			s.startRenewal(name)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			s.local.Unlock(name)
+			if lastErr != nil {
+				return fmt.Errorf("%w: last error acquiring lease: %w", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-time.After(s.RetryInterval):
+		}
+	}
+}
+
+// TryLock makes a single attempt to acquire the named lock
+func (s *LeaseLockStore) TryLock(name string) bool {
+	if !*utils.LockEnabled {
+		return true
+	}
+	if !s.local.TryLock(name) {
+		return false
+	}
+	acquired, err := s.tryAcquireLease(context.Background(), name)
+	if err != nil {
+		s.logger.Warn("Failed to acquire lease", zap.String("name", name), zap.Error(err))
+	}
+	if !acquired {
+		s.local.Unlock(name)
+		return false
+	}
+	s.startRenewal(name)
+	return true
+}
+
+// Unlock stops renewing and deletes the lease of the named lock. Like
+// utils.LockStore, it panics if the name is not locked by this store. A lock
+// whose lease was lost must still be unlocked.
+func (s *LeaseLockStore) Unlock(name string) {
+	if !*utils.LockEnabled {
+		return
+	}
+	s.mux.Lock()
+	cancel, held := s.renewals[name]
+	delete(s.renewals, name)
+	s.mux.Unlock()
+	if !held {
+		panic("leaselock: unlock of unlocked lock " + name)
+	}
+	cancel()
+	if err := s.releaseLease(context.Background(), name); err != nil {
+		// The lease expires on its own once it is no longer renewed
+		s.logger.Warn("Failed to release lease", zap.String("name", name), zap.Error(err))
+	}
+	s.local.Unlock(name)
+}
+
+// LockAll acquires all the named locks and returns a function releasing them.
+// Names are locked in utils.CanonicalLockOrder so that replicas locking
+// overlapping names cannot deadlock.
+func (s *LeaseLockStore) LockAll(names ...string) func() {
+	keys := utils.CanonicalLockOrder(names)
+	for _, name := range keys {
+		s.Lock(name)
+	}
+	return func() {
+		for i := len(keys) - 1; i >= 0; i-- {
+			s.Unlock(keys[i])
+		}
+	}
+}
+
+// tryAcquireLease creates the lease or takes over a free or expired one
+func (s *LeaseLockStore) tryAcquireLease(ctx context.Context, name string) (bool, error) {
+	leases := s.client.CoordinationV1().Leases(s.namespace)
+	leaseName := LeaseName(name)
+	now := metav1.NowMicro()
+	durationSeconds := s.leaseDurationSeconds()
+
+	lease, err := leases.Get(ctx, leaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        leaseName,
+				Namespace:   s.namespace,
+				Annotations: map[string]string{LockNameAnnotation: name},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &s.identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if err != nil {
+		return false, err
+	}
+	if s.heldByOther(lease, now.Time) {
+		return false, nil
+	}
+
+	transitions := int32(0)
+	if lease.Spec.LeaseTransitions != nil {
+		transitions = *lease.Spec.LeaseTransitions
+	}
+	transitions++
+	lease.Spec.HolderIdentity = &s.identity
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	lease.Spec.LeaseTransitions = &transitions
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		// Another replica updated the lease first
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// heldByOther reports whether the lease has a live holder other than us. A
+// lease carrying our own identity can only be left over from an earlier run,
+// since callers within this process are serialised locally.
+func (s *LeaseLockStore) heldByOther(lease *coordinationv1.Lease, now time.Time) bool {
+	holder := lease.Spec.HolderIdentity
+	if holder == nil || *holder == "" || *holder == s.identity {
+		return false
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
+
+// leaseDurationSeconds returns LeaseDuration rounded up to whole seconds
+func (s *LeaseLockStore) leaseDurationSeconds() int32 {
+	seconds := (s.LeaseDuration + time.Second - 1) / time.Second
+	if seconds < 1 {
+		seconds = 1
+	}
+	return int32(seconds)
+}
+
+// renewInterval returns RenewInterval clamped to at most a third of the lease
+// duration
+func (s *LeaseLockStore) renewInterval() time.Duration {
+	limit := s.LeaseDuration / 3
+	if limit <= 0 {
+		limit = time.Duration(s.leaseDurationSeconds()) * time.Second / 3
+	}
+	if s.RenewInterval <= 0 || s.RenewInterval > limit {
+		return limit
+	}
+	return s.RenewInterval
+}
+
+func (s *LeaseLockStore) startRenewal(name string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mux.Lock()
+	s.renewals[name] = cancel
+	s.mux.Unlock()
+	go s.renew(ctx, name)
+}
+
+// renew keeps the lease of name alive until ctx is cancelled or the lease is
+// lost. A lease is lost once another holder owns it or it is deleted, or if
+// renewing keeps failing for LeaseDuration, after which others may take it.
+func (s *LeaseLockStore) renew(ctx context.Context, name string) {
+	ticker := time.NewTicker(s.renewInterval())
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := s.renewLease(ctx, name)
+		if err == nil {
+			renewed = time.Now()
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, errLeaseLost) && time.Since(renewed) < s.LeaseDuration {
+			s.logger.Warn("Failed to renew lease", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		s.logger.Error("Lost lease of held lock", zap.String("name", name), zap.Error(err))
+		if s.OnLost != nil {
+			s.OnLost(name)
+		}
+		return
+	}
+}
+
+func (s *LeaseLockStore) renewLease(ctx context.Context, name string) error {
+	leases := s.client.CoordinationV1().Leases(s.namespace)
+	lease, err := leases.Get(ctx, LeaseName(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("%w: lease %s was deleted", errLeaseLost, LeaseName(name))
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != s.identity {
+		return fmt.Errorf("%w: lease %s is no longer held by %s", errLeaseLost, lease.Name, s.identity)
+	}
+	now := metav1.NowMicro()
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// releaseLease deletes the lease of name if it is still held by us
+func (s *LeaseLockStore) releaseLease(ctx context.Context, name string) error {
+	leases := s.client.CoordinationV1().Leases(s.namespace)
+	lease, err := leases.Get(ctx, LeaseName(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != s.identity {
+		return nil
+	}
+	err = leases.Delete(ctx, lease.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &lease.UID, ResourceVersion: &lease.ResourceVersion},
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
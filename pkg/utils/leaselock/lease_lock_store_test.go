@@ -0,0 +1,368 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package leaselock ...
+package leaselock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testNamespace = "kube-system"
+
+func newTestStore(t *testing.T, client kubernetes.Interface, identity string) (*LeaseLockStore, func()) {
+	logger, teardown := utils.GetTestLogger(t)
+	store := New(logger, client, testNamespace, identity)
+	store.RetryInterval = 5 * time.Millisecond
+	return store, teardown
+}
+
+func getLease(t *testing.T, client kubernetes.Interface, name string) *coordinationv1.Lease {
+	lease, err := client.CoordinationV1().Leases(testNamespace).Get(context.Background(), LeaseName(name), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	assert.Nil(t, err)
+	return lease
+}
+
+func createLease(t *testing.T, client kubernetes.Interface, name string, holder string, renewTime time.Time) {
+	duration := int32(30)
+	renew := metav1.NewMicroTime(renewTime)
+	lease := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: LeaseName(name), Namespace: testNamespace},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renew,
+		},
+	}
+	_, err := client.CoordinationV1().Leases(testNamespace).Create(context.Background(), lease, metav1.CreateOptions{})
+	assert.Nil(t, err)
+}
+
+func TestLeaseName(t *testing.T) {
+	testCases := []struct {
+		testCaseName   string
+		inputName      string
+		expectedPrefix string
+	}{
+		{
+			testCaseName:   "Volume ID",
+			inputName:      "r006-2f2a7a0c-1b4e",
+			expectedPrefix: "csi-lock-r006-2f2a7a0c-1b4e-",
+		},
+		{
+			testCaseName:   "Upper case and separators",
+			inputName:      "Node_1/vol:A",
+			expectedPrefix: "csi-lock-node-1-vol-a-",
+		},
+		{
+			testCaseName:   "Only invalid characters",
+			inputName:      "___",
+			expectedPrefix: "csi-lock-",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			leaseName := LeaseName(tc.inputName)
+			assert.True(t, strings.HasPrefix(leaseName, tc.expectedPrefix), leaseName)
+			assert.Equal(t, leaseName, LeaseName(tc.inputName))
+		})
+	}
+
+	// Names that sanitise alike still map to different leases
+	assert.NotEqual(t, LeaseName("vol_1"), LeaseName("vol:1"))
+	assert.True(t, len(LeaseName(strings.Repeat("a", 500))) < 253)
+}
+
+func TestLockUnlock(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store, teardown := newTestStore(t, client, "replica-1")
+	defer teardown()
+
+	store.Lock("vol-1")
+	lease := getLease(t, client, "vol-1")
+	assert.NotNil(t, lease)
+	assert.Equal(t, "replica-1", *lease.Spec.HolderIdentity)
+	assert.Equal(t, "vol-1", lease.Annotations[LockNameAnnotation])
+	assert.Equal(t, int32(30), *lease.Spec.LeaseDurationSeconds)
+
+	store.Unlock("vol-1")
+	assert.Nil(t, getLease(t, client, "vol-1"))
+
+	// Unlocking a name which is not held panics like utils.LockStore
+	assert.Panics(t, func() { store.Unlock("vol-1") })
+	assert.Panics(t, func() { store.Unlock("vol-2") })
+
+	// The store is still usable afterwards
+	assert.True(t, store.TryLock("vol-1"))
+	store.Unlock("vol-1")
+}
+
+func TestLockHeldByOtherReplica(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createLease(t, client, "vol-1", "replica-2", time.Now())
+	store, teardown := newTestStore(t, client, "replica-1")
+	defer teardown()
+
+	assert.False(t, store.TryLock("vol-1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, store.LockWithContext(ctx, "vol-1"))
+	assert.Equal(t, "replica-2", *getLease(t, client, "vol-1").Spec.HolderIdentity)
+
+	// The local lock is released again after a failed attempt
+	assert.True(t, store.TryLock("vol-2"))
+	store.Unlock("vol-2")
+}
+
+func TestExpiredLeaseIsTakenOver(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	createLease(t, client, "vol-1", "replica-2", time.Now().Add(-time.Minute))
+	store, teardown := newTestStore(t, client, "replica-1")
+	defer teardown()
+
+	assert.True(t, store.TryLock("vol-1"))
+	lease := getLease(t, client, "vol-1")
+	assert.Equal(t, "replica-1", *lease.Spec.HolderIdentity)
+	assert.Equal(t, int32(1), *lease.Spec.LeaseTransitions)
+	store.Unlock("vol-1")
+}
+
+func TestLeaseIsRenewed(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store, teardown := newTestStore(t, client, "replica-1")
+	defer teardown()
+	store.RenewInterval = 5 * time.Millisecond
+
+	store.Lock("vol-1")
+	acquired := getLease(t, client, "vol-1").Spec.RenewTime.Time
+	assert.Eventually(t, func() bool {
+		return getLease(t, client, "vol-1").Spec.RenewTime.After(acquired)
+	}, time.Second, time.Millisecond)
+	store.Unlock("vol-1")
+	assert.Nil(t, getLease(t, client, "vol-1"))
+}
+
+func TestTwoReplicas(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	replica1, teardown1 := newTestStore(t, client, "replica-1")
+	defer teardown1()
+	replica2, teardown2 := newTestStore(t, client, "replica-2")
+	defer teardown2()
+
+	replica1.Lock("vol-1")
+	assert.False(t, replica2.TryLock("vol-1"))
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- replica2.LockWithContext(context.Background(), "vol-1")
+	}()
+	replica1.Unlock("vol-1")
+	assert.Nil(t, <-acquired)
+	assert.Equal(t, "replica-2", *getLease(t, client, "vol-1").Spec.HolderIdentity)
+	replica2.Unlock("vol-1")
+}
+
+func TestLeaseDurationSeconds(t *testing.T) {
+	testCases := []struct {
+		testCaseName    string
+		leaseDuration   time.Duration
+		expectedSeconds int32
+	}{
+		{
+			testCaseName:    "Whole seconds",
+			leaseDuration:   30 * time.Second,
+			expectedSeconds: 30,
+		},
+		{
+			testCaseName:    "Fraction is rounded up",
+			leaseDuration:   1500 * time.Millisecond,
+			expectedSeconds: 2,
+		},
+		{
+			testCaseName:    "Sub-second",
+			leaseDuration:   500 * time.Millisecond,
+			expectedSeconds: 1,
+		},
+		{
+			testCaseName:    "Zero",
+			leaseDuration:   0,
+			expectedSeconds: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			store := &LeaseLockStore{LeaseDuration: tc.leaseDuration}
+			assert.Equal(t, tc.expectedSeconds, store.leaseDurationSeconds())
+		})
+	}
+}
+
+func TestRenewInterval(t *testing.T) {
+	testCases := []struct {
+		testCaseName     string
+		leaseDuration    time.Duration
+		renewInterval    time.Duration
+		expectedInterval time.Duration
+	}{
+		{
+			testCaseName:     "Defaults",
+			leaseDuration:    DefaultLeaseDuration,
+			renewInterval:    DefaultRenewInterval,
+			expectedInterval: DefaultRenewInterval,
+		},
+		{
+			testCaseName:     "Shorter interval is kept",
+			leaseDuration:    30 * time.Second,
+			renewInterval:    time.Second,
+			expectedInterval: time.Second,
+		},
+		{
+			testCaseName:     "Interval equal to the lease duration is clamped",
+			leaseDuration:    30 * time.Second,
+			renewInterval:    30 * time.Second,
+			expectedInterval: 10 * time.Second,
+		},
+		{
+			testCaseName:     "Interval longer than the lease duration is clamped",
+			leaseDuration:    30 * time.Second,
+			renewInterval:    time.Minute,
+			expectedInterval: 10 * time.Second,
+		},
+		{
+			testCaseName:     "Zero interval",
+			leaseDuration:    30 * time.Second,
+			renewInterval:    0,
+			expectedInterval: 10 * time.Second,
+		},
+		{
+			testCaseName:     "Zero lease duration",
+			leaseDuration:    0,
+			renewInterval:    time.Minute,
+			expectedInterval: time.Second / 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			store := &LeaseLockStore{LeaseDuration: tc.leaseDuration, RenewInterval: tc.renewInterval}
+			assert.Equal(t, tc.expectedInterval, store.renewInterval())
+		})
+	}
+}
+
+func TestLockWithContextReportsAPIErrors(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("apiserver unavailable")
+	})
+	store, teardown := newTestStore(t, client, "replica-1")
+	defer teardown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	err := store.LockWithContext(ctx, "vol-1")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "apiserver unavailable")
+
+	// The local lock is released again after a failed attempt
+	assert.True(t, store.local.TryLock("vol-1"))
+	store.local.Unlock("vol-1")
+}
+
+func TestLostLeaseIsReported(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	replica1, teardown1 := newTestStore(t, client, "replica-1")
+	defer teardown1()
+	replica2, teardown2 := newTestStore(t, client, "replica-2")
+	defer teardown2()
+
+	// replica-1 cannot renew its lease, so it expires and is stolen
+	client.PrependReactor("update", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lease := action.(k8stesting.UpdateAction).GetObject().(*coordinationv1.Lease)
+		if *lease.Spec.HolderIdentity == "replica-1" {
+			return true, nil, errors.New("apiserver unavailable")
+		}
+		return false, nil, nil
+	})
+	lost := make(chan string, 1)
+	replica1.LeaseDuration = 500 * time.Millisecond
+	replica1.OnLost = func(name string) { lost <- name }
+
+	replica1.Lock("vol-1")
+	assert.Equal(t, int32(1), *getLease(t, client, "vol-1").Spec.LeaseDurationSeconds)
+	assert.False(t, replica2.TryLock("vol-1"))
+	assert.Eventually(t, func() bool { return replica2.TryLock("vol-1") }, 5*time.Second, 10*time.Millisecond)
+
+	select {
+	case name := <-lost:
+		assert.Equal(t, "vol-1", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lost lease was not reported")
+	}
+
+	// Unlocking the lost lock leaves the new holder's lease alone
+	replica1.Unlock("vol-1")
+	assert.Equal(t, "replica-2", *getLease(t, client, "vol-1").Spec.HolderIdentity)
+	replica2.Unlock("vol-1")
+	assert.Nil(t, getLease(t, client, "vol-1"))
+}
+
+func TestLockAll(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	replica1, teardown1 := newTestStore(t, client, "replica-1")
+	defer teardown1()
+	replica2, teardown2 := newTestStore(t, client, "replica-2")
+	defer teardown2()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			release := replica1.LockAll("vol-1", "node-1")
+			release()
+		}()
+		go func() {
+			defer wg.Done()
+			release := replica2.LockAll("node-1", "vol-1")
+			release()
+		}()
+	}
+	wg.Wait()
+	assert.Nil(t, getLease(t, client, "vol-1"))
+	assert.Nil(t, getLease(t, client, "node-1"))
+}
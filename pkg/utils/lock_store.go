@@ -18,51 +18,275 @@
 package utils
 
 import (
+	"context"
 	"flag"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"sync"
+	"time"
 )
 
 // LockEnabled ...
 var LockEnabled = flag.Bool("lock_enabled", true, "Enable or disable lock")
 
-// LockStore ...
+// Locker is the named lock API shared by the in-process LockStore and
+// distributed implementations such as leaselock.LeaseLockStore
+type Locker interface {
+	// Lock blocks until the named lock is acquired
+	Lock(name string)
+
+	// LockWithContext blocks until the named lock is acquired or ctx is done
+	LockWithContext(ctx context.Context, name string) error
+
+	// TryLock acquires the named lock only if it is free
+	TryLock(name string) bool
+
+	// Unlock releases the named lock. Like sync.Mutex, it panics if the name
+	// is not locked by this Locker.
+	Unlock(name string)
+
+	// LockAll acquires all the named locks in a canonical order
+	LockAll(names ...string) func()
+}
+
+var _ Locker = &LockStore{}
+
+// LockObserver receives lock wait and hold durations, e.g. to feed metrics
+type LockObserver interface {
+	// ObserveLockWait records how long a caller waited to acquire a lock
+	ObserveLockWait(store string, wait time.Duration)
+
+	// ObserveLockHold records how long a lock was held before it was released
+	ObserveLockHold(store string, hold time.Duration)
+}
+
+// LockInfo describes a currently held lock, as returned by LockStore.Snapshot
+type LockInfo struct {
+	Name      string        `json:"name"`
+	Holder    string        `json:"holder"`
+	RequestID string        `json:"requestID,omitempty"`
+	HeldFor   time.Duration `json:"heldFor"`
+	Waiters   int           `json:"waiters"`
+}
+
+// LockStore provides named locks. An entry is created on first use and is
+// removed again once no goroutine holds or waits for it, so the store only
+// grows with the number of names in flight. The zero value is ready to use.
 type LockStore struct {
-	store map[string]*sync.Mutex
+	// Name identifies the store in observations, e.g. "volume"
+	Name string
+
+	// Observer is optional and is notified of wait and hold durations
+	Observer LockObserver
+
+	mux   sync.Mutex
+	store map[string]*lockEntry
 }
 
-// lockstore mutex
-var lockstoremux = sync.Mutex{}
+// lockEntry is a single named lock. All fields except wake are guarded by the
+// LockStore mutex, so a lock is taken and its holder recorded in one step.
+// Waiters block on wake, which Unlock signals, so that acquisition can be
+// abandoned when a context is done. refs counts holders plus waiters.
+type lockEntry struct {
+	wake       chan struct{}
+	refs       int
+	held       bool
+	holder     string
+	requestID  string
+	acquiredAt time.Time
+}
 
 func (s *LockStore) checkAndInitLockStore() {
 	if s.store == nil {
-		s.store = make(map[string]*sync.Mutex)
+		s.store = make(map[string]*lockEntry)
 	}
 }
 
-func (s *LockStore) getLock(name string) *sync.Mutex {
-	lockstoremux.Lock()
-	defer lockstoremux.Unlock()
-
+// acquireRef returns the entry for name, creating it if needed, and takes a
+// reference on it. The caller must hold s.mux and pair it with a releaseRef.
+func (s *LockStore) acquireRef(name string) *lockEntry {
 	//check and init lock storage
 	s.checkAndInitLockStore()
 
 	//Get the lock Object
-	if s.store[name] == nil {
-		s.store[name] = &sync.Mutex{}
+	entry := s.store[name]
+	if entry == nil {
+		entry = &lockEntry{wake: make(chan struct{}, 1)}
+		s.store[name] = entry
+	}
+	entry.refs++
+	return entry
+}
+
+// releaseRef drops a reference taken by acquireRef and evicts the entry once
+// nobody holds or waits for it. The caller must hold s.mux.
+func (s *LockStore) releaseRef(name string, entry *lockEntry) {
+	entry.refs--
+	if entry.refs == 0 && s.store[name] == entry {
+		delete(s.store, name)
 	}
-	return s.store[name]
 }
 
-// Lock ...
+// lock takes the named lock for holder. If the lock is held it returns false
+// straight away unless wait is set, in which case it blocks until the lock is
+// free or done is closed. It reports whether the lock was acquired.
+func (s *LockStore) lock(name, holder, requestID string, wait bool, done <-chan struct{}) bool {
+	start := time.Now()
+	s.mux.Lock()
+	entry := s.acquireRef(name)
+	for entry.held {
+		if !wait {
+			s.releaseRef(name, entry)
+			s.mux.Unlock()
+			return false
+		}
+		s.mux.Unlock()
+		select {
+		case <-entry.wake:
+		case <-done:
+			s.mux.Lock()
+			s.releaseRef(name, entry)
+			s.mux.Unlock()
+			return false
+		}
+		s.mux.Lock()
+	}
+	now := time.Now()
+	entry.held = true
+	entry.holder = holder
+	entry.requestID = requestID
+	entry.acquiredAt = now
+	s.mux.Unlock()
+
+	if s.Observer != nil {
+		s.Observer.ObserveLockWait(s.Name, now.Sub(start))
+	}
+	return true
+}
+
+// callerLocation returns the file:line skip frames above its caller, which
+// identifies the holder of a lock in Snapshot
+func callerLocation(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return "unknown"
+	}
+	return filepath.Base(file) + ":" + strconv.Itoa(line)
+}
+
+// Lock blocks until the named lock is acquired
 func (s *LockStore) Lock(name string) {
 	if *LockEnabled {
-		s.getLock(name).Lock()
+		s.lock(name, callerLocation(1), "", true, nil)
+	}
+}
+
+// LockWithContext blocks until the named lock is acquired or ctx is done.
+// It returns ctx.Err() if the lock was not acquired. The request ID carried
+// by ctx, if any, is reported along with the holder in Snapshot.
+func (s *LockStore) LockWithContext(ctx context.Context, name string) error {
+	if !*LockEnabled {
+		return nil
+	}
+	if !s.lock(name, callerLocation(1), RequestIDFromContext(ctx), true, ctx.Done()) {
+		return ctx.Err()
 	}
+	return nil
 }
 
-// Unlock ...
+// TryLock acquires the named lock only if it is free and reports whether it
+// did so. It never blocks.
+func (s *LockStore) TryLock(name string) bool {
+	if !*LockEnabled {
+		return true
+	}
+	return s.lock(name, callerLocation(1), "", false, nil)
+}
+
+// Unlock releases the named lock. Like sync.Mutex, it panics if the name is
+// not locked.
 func (s *LockStore) Unlock(name string) {
+	if !*LockEnabled {
+		return
+	}
+	s.mux.Lock()
+	entry := s.store[name]
+	if entry == nil || !entry.held {
+		s.mux.Unlock()
+		panic("utils: unlock of unlocked lock " + name)
+	}
+	hold := time.Since(entry.acquiredAt)
+	entry.held = false
+	entry.holder = ""
+	entry.requestID = ""
+	s.releaseRef(name, entry)
+	s.mux.Unlock()
+
+	// Wake up one waiter, if any. A pending wake up is never lost: waiters
+	// re-check held under s.mux before blocking again.
+	select {
+	case entry.wake <- struct{}{}:
+	default:
+	}
+	if s.Observer != nil {
+		s.Observer.ObserveLockHold(s.Name, hold)
+	}
+}
+
+// Snapshot lists the locks currently held, sorted by name
+func (s *LockStore) Snapshot() []LockInfo {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	now := time.Now()
+	locks := make([]LockInfo, 0, len(s.store))
+	for name, entry := range s.store {
+		if !entry.held {
+			continue
+		}
+		locks = append(locks, LockInfo{
+			Name:      name,
+			Holder:    entry.holder,
+			RequestID: entry.requestID,
+			HeldFor:   now.Sub(entry.acquiredAt),
+			Waiters:   entry.refs - 1,
+		})
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Name < locks[j].Name })
+	return locks
+}
+
+// LockAll acquires all the named locks and returns a function releasing them.
+// Names are locked in sorted order, so concurrent callers with overlapping
+// names (e.g. volume ID and node ID) cannot deadlock. Duplicates are ignored.
+func (s *LockStore) LockAll(names ...string) func() {
+	keys := CanonicalLockOrder(names)
 	if *LockEnabled {
-		s.getLock(name).Unlock()
+		holder := callerLocation(1)
+		for _, name := range keys {
+			s.lock(name, holder, "", true, nil)
+		}
+	}
+	return func() {
+		for i := len(keys) - 1; i >= 0; i-- {
+			s.Unlock(keys[i])
+		}
+	}
+}
+
+// CanonicalLockOrder returns names sorted and without duplicates, which is
+// the order Locker implementations acquire them in LockAll
+func CanonicalLockOrder(names []string) []string {
+	keys := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			keys = append(keys, name)
+		}
 	}
+	sort.Strings(keys)
+	return keys
 }
@@ -18,7 +18,10 @@
 package utils
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -40,3 +43,246 @@ func TestLockStoreFunctionalityCheck(t *testing.T) {
 	defer mutex.Unlock("TestLock2")
 	assert.Equal(t, 2, len(mutex.store)) // It should have only 2 lock now as well
 }
+
+func TestLockStoreEvictsUnusedLocks(t *testing.T) {
+	var mutex LockStore
+	mutex.Lock("TestLock1")
+	mutex.Lock("TestLock2")
+	assert.Equal(t, 2, len(mutex.store))
+
+	mutex.Unlock("TestLock1")
+	mutex.Unlock("TestLock2")
+	assert.Equal(t, 0, len(mutex.store)) // Released locks are removed
+}
+
+func TestLockStoreUnlockOfUnlockedLock(t *testing.T) {
+	var mutex LockStore
+	assert.Panics(t, func() { mutex.Unlock("TestLock1") })
+	assert.Equal(t, 0, len(mutex.store))
+
+	mutex.Lock("TestLock1")
+	mutex.Unlock("TestLock1")
+	assert.Panics(t, func() { mutex.Unlock("TestLock1") }) // Released twice
+	assert.Equal(t, 0, len(mutex.store))
+}
+
+func TestLockStoreTryLock(t *testing.T) {
+	var mutex LockStore
+	assert.True(t, mutex.TryLock("TestLock1"))
+	assert.False(t, mutex.TryLock("TestLock1")) // Already held
+	assert.Equal(t, 1, len(mutex.store))
+
+	mutex.Unlock("TestLock1")
+	assert.True(t, mutex.TryLock("TestLock1"))
+	mutex.Unlock("TestLock1")
+	assert.Equal(t, 0, len(mutex.store))
+}
+
+func TestLockStoreLockWithContext(t *testing.T) {
+	var mutex LockStore
+	assert.Nil(t, mutex.LockWithContext(context.Background(), "TestLock1"))
+
+	// Waiting for a held lock gives up once the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := mutex.LockWithContext(ctx, "TestLock1")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, len(mutex.store))
+
+	// A waiter acquires the lock as soon as it is released
+	acquired := make(chan error)
+	go func() {
+		acquired <- mutex.LockWithContext(context.Background(), "TestLock1")
+	}()
+	mutex.Unlock("TestLock1")
+	assert.Nil(t, <-acquired)
+	mutex.Unlock("TestLock1")
+	assert.Equal(t, 0, len(mutex.store))
+}
+
+func TestLockStoreConcurrentAccess(t *testing.T) {
+	var mutex LockStore
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mutex.Lock("TestLock1")
+			counter++
+			mutex.Unlock("TestLock1")
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 50, counter)
+	assert.Equal(t, 0, len(mutex.store))
+}
+
+func TestLockStoreLockAll(t *testing.T) {
+	var mutex LockStore
+	release := mutex.LockAll("vol-1", "node-1", "vol-1")
+	assert.Equal(t, 2, len(mutex.store)) // Duplicate names are locked once
+	assert.False(t, mutex.TryLock("vol-1"))
+	assert.False(t, mutex.TryLock("node-1"))
+
+	release()
+	assert.Equal(t, 0, len(mutex.store))
+
+	release = mutex.LockAll()
+	release()
+	assert.Equal(t, 0, len(mutex.store))
+}
+
+func TestLockStoreLockAllOverlappingKeys(t *testing.T) {
+	var mutex LockStore
+	keySets := [][]string{
+		{"vol-1", "node-1"},
+		{"node-1", "vol-1"},
+		{"vol-2", "vol-1", "snap-1"},
+		{"snap-1", "vol-2"},
+		{"node-1", "vol-2", "vol-1"},
+	}
+	held := map[string]bool{}
+	var heldMux sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		keys := keySets[i%len(keySets)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := mutex.LockAll(keys...)
+			heldMux.Lock()
+			for _, key := range keys {
+				assert.False(t, held[key], "lock %s held twice", key)
+				held[key] = true
+			}
+			heldMux.Unlock()
+
+			time.Sleep(time.Microsecond)
+
+			heldMux.Lock()
+			for _, key := range keys {
+				held[key] = false
+			}
+			heldMux.Unlock()
+			release()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("LockAll deadlocked")
+	}
+	assert.Equal(t, 0, len(mutex.store))
+}
+
+type fakeLockObserver struct {
+	mux   sync.Mutex
+	waits []string
+	holds []string
+}
+
+func (o *fakeLockObserver) ObserveLockWait(store string, wait time.Duration) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.waits = append(o.waits, store)
+}
+
+func (o *fakeLockObserver) ObserveLockHold(store string, hold time.Duration) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.holds = append(o.holds, store)
+}
+
+func TestLockStoreObserver(t *testing.T) {
+	observer := &fakeLockObserver{}
+	mutex := LockStore{Name: "volume", Observer: observer}
+	mutex.Lock("TestLock1")
+	assert.True(t, mutex.TryLock("TestLock2"))
+	assert.False(t, mutex.TryLock("TestLock2"))
+	mutex.Unlock("TestLock1")
+	mutex.Unlock("TestLock2")
+	assert.Panics(t, func() { mutex.Unlock("TestLock2") }) // not held, nothing observed
+
+	assert.Equal(t, []string{"volume", "volume"}, observer.waits)
+	assert.Equal(t, []string{"volume", "volume"}, observer.holds)
+}
+
+func TestLockStoreSnapshot(t *testing.T) {
+	var mutex LockStore
+	assert.Empty(t, mutex.Snapshot())
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	assert.Nil(t, mutex.LockWithContext(ctx, "vol-2"))
+	mutex.Lock("vol-1")
+
+	waiting := make(chan struct{})
+	go func() {
+		close(waiting)
+		mutex.Lock("vol-1")
+		mutex.Unlock("vol-1")
+	}()
+	<-waiting
+	assert.Eventually(t, func() bool {
+		locks := mutex.Snapshot()
+		return len(locks) == 2 && locks[0].Waiters == 1
+	}, time.Second, time.Millisecond)
+
+	locks := mutex.Snapshot()
+	assert.Equal(t, "vol-1", locks[0].Name)
+	assert.Contains(t, locks[0].Holder, "lock_store_test.go:")
+	assert.Equal(t, "", locks[0].RequestID)
+	assert.Equal(t, "vol-2", locks[1].Name)
+	assert.Contains(t, locks[1].Holder, "lock_store_test.go:")
+	assert.Equal(t, "req-1", locks[1].RequestID)
+	assert.Equal(t, 0, locks[1].Waiters)
+	assert.True(t, locks[1].HeldFor >= 0)
+
+	mutex.Unlock("vol-2")
+	mutex.Unlock("vol-1")
+	assert.Eventually(t, func() bool { return len(mutex.Snapshot()) == 0 }, time.Second, time.Millisecond)
+
+	// TryLock and LockAll record their callers as holders too
+	assert.True(t, mutex.TryLock("vol-1"))
+	release := mutex.LockAll("vol-2", "vol-3")
+	locks = mutex.Snapshot()
+	assert.Equal(t, 3, len(locks))
+	for _, lock := range locks {
+		assert.Contains(t, lock.Holder, "lock_store_test.go:")
+	}
+	release()
+	mutex.Unlock("vol-1")
+}
+
+func TestLockStoreCancelledWaiterDoesNotBlockOthers(t *testing.T) {
+	var mutex LockStore
+	mutex.Lock("TestLock1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		cancelled <- mutex.LockWithContext(ctx, "TestLock1")
+	}()
+	acquired := make(chan struct{})
+	go func() {
+		mutex.Lock("TestLock1")
+		close(acquired)
+	}()
+	assert.Eventually(t, func() bool {
+		locks := mutex.Snapshot()
+		return len(locks) == 1 && locks[0].Waiters == 2
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-cancelled)
+	mutex.Unlock("TestLock1")
+	<-acquired
+	mutex.Unlock("TestLock1")
+	assert.Equal(t, 0, len(mutex.store))
+}
@@ -18,50 +18,152 @@
 package utils
 
 import (
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
 
-	uid "github.com/gofrs/uuid"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"golang.org/x/net/context"
 )
 
-// GetContextLogger ...
-func GetContextLogger(ctx context.Context, isDebug bool) (*zap.Logger, string) {
-	return GetContextLoggerWithRequestID(ctx, isDebug, nil)
+// LoggerFactory builds the zap cores once and derives per-request loggers
+// from them. Info and debug go to stdout, errors and above to stderr, with
+// secrets masked by NewRedactingCore. The level can be changed at runtime
+// through Level, LevelHandler or HandleLevelSignal and applies to every
+// logger derived from the factory.
+type LoggerFactory struct {
+	level     zap.AtomicLevel
+	baseLevel zapcore.Level
+	logger    *zap.Logger
+	debug     *zap.Logger
 }
 
-// GetContextLoggerWithRequestID  adds existing requestID in the logger
-// The Existing requestID might be coming from ControllerPublishVolume etc
-func GetContextLoggerWithRequestID(ctx context.Context, isDebug bool, requestIDIn *string) (*zap.Logger, string) {
-	consoleDebugging := zapcore.Lock(os.Stdout)
-	consoleErrors := zapcore.Lock(os.Stderr)
+// defaultLoggerFactory backs GetContextLogger
+var defaultLoggerFactory = NewLoggerFactory(zap.InfoLevel)
+
+// DefaultLoggerFactory returns the factory used by GetContextLogger
+func DefaultLoggerFactory() *LoggerFactory {
+	return defaultLoggerFactory
+}
+
+// NewLoggerFactory creates a LoggerFactory logging at level to stdout/stderr
+func NewLoggerFactory(level zapcore.Level) *LoggerFactory {
+	return newLoggerFactory(level, zapcore.Lock(os.Stdout), zapcore.Lock(os.Stderr))
+}
+
+func newLoggerFactory(level zapcore.Level, consoleDebugging zapcore.WriteSyncer, consoleErrors zapcore.WriteSyncer) *LoggerFactory {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.TimeKey = "ts"
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	traceLevel := zap.NewAtomicLevel()
-	if isDebug {
-		traceLevel.SetLevel(zap.DebugLevel)
+	encoder := zapcore.NewJSONEncoder(encoderConfig)
+
+	f := &LoggerFactory{level: zap.NewAtomicLevelAt(level), baseLevel: level}
+	// Secrets are masked on each core, as a tee writes to all of its cores
+	newCore := func(minLevel zap.LevelEnablerFunc) zapcore.Core {
+		return zapcore.NewTee(
+			NewRedactingCore(zapcore.NewCore(encoder, consoleDebugging, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return minLevel(lvl) && (lvl < zapcore.ErrorLevel)
+			}))),
+			NewRedactingCore(zapcore.NewCore(encoder, consoleErrors, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
+				return lvl >= zapcore.ErrorLevel
+			}))),
+		)
+	}
+	f.logger = zap.New(newCore(f.level.Enabled), zap.AddCaller())
+	// Callers asking for debug output get it on top of the factory level, as
+	// long as that level is info or below
+	f.debug = zap.New(newCore(func(lvl zapcore.Level) bool {
+		return f.level.Enabled(lvl) || (lvl == zapcore.DebugLevel && f.level.Enabled(zapcore.InfoLevel))
+	}), zap.AddCaller())
+	return f
+}
+
+// Level returns the level shared by all loggers of the factory
+func (f *LoggerFactory) Level() zap.AtomicLevel {
+	return f.level
+}
+
+// LevelHandler returns an HTTP handler reporting the level on GET and changing
+// it on PUT, e.g. curl -X PUT -d '{"level":"debug"}'
+func (f *LoggerFactory) LevelHandler() http.Handler {
+	return f.level
+}
+
+// HandleLevelSignal toggles between debug and the initial level whenever one
+// of sigs (e.g. syscall.SIGUSR1) is received. The returned function stops it.
+func (f *LoggerFactory) HandleLevelSignal(sigs ...os.Signal) func() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	stop := f.watchLevelSignals(ch)
+	return func() {
+		signal.Stop(ch)
+		stop()
+	}
+}
+
+func (f *LoggerFactory) watchLevelSignals(ch <-chan os.Signal) func() {
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ch:
+				f.toggleDebug()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+func (f *LoggerFactory) toggleDebug() {
+	if f.level.Level() == zapcore.DebugLevel {
+		f.level.SetLevel(f.baseLevel)
 	} else {
-		traceLevel.SetLevel(zap.InfoLevel)
+		f.level.SetLevel(zapcore.DebugLevel)
 	}
+	f.logger.Warn("Log level changed", zap.Stringer("level", f.level.Level()))
+}
 
-	core := zapcore.NewTee(
-		zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), consoleDebugging, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-			return (lvl >= traceLevel.Level()) && (lvl < zapcore.ErrorLevel)
-		})),
-		zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), consoleErrors, zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
-			return lvl >= zapcore.ErrorLevel
-		})),
-	)
-	logger := zap.New(core, zap.AddCaller())
+// Logger returns the factory logger without request ID
+func (f *LoggerFactory) Logger() *zap.Logger {
+	return f.logger
+}
+
+// RequestLogger returns a child logger carrying the request ID, generating a
+// new one if requestIDIn is nil, along with the request ID
+func (f *LoggerFactory) RequestLogger(isDebug bool, requestIDIn *string) (*zap.Logger, string) {
+	logger := f.logger
+	if isDebug {
+		logger = f.debug
+	}
 	// generating a unique request ID so that logs can be filter
 	if requestIDIn == nil {
 		// Generate New RequestID if not provided
-		uuid, _ := uid.NewV4() // #nosec G104: Attempt to randomly generate uuid
-		requestID := uuid.String()
+		requestID := NewRequestID()
 		requestIDIn = &requestID
 	}
-	logger = logger.With(zap.String("RequestID", *requestIDIn))
-	return logger, *requestIDIn + " "
+	return logger.With(zap.String("RequestID", *requestIDIn)), *requestIDIn
+}
+
+// GetContextLogger returns a logger carrying the request ID of ctx (see
+// RequestIDFromContext), or a newly generated one if ctx has none
+func GetContextLogger(ctx context.Context, isDebug bool) (*zap.Logger, string) {
+	return GetContextLoggerWithRequestID(ctx, isDebug, nil)
+}
+
+// GetContextLoggerWithRequestID  adds existing requestID in the logger
+// The Existing requestID might be coming from ControllerPublishVolume etc
+// If requestIDIn is nil the request ID of ctx is used when present
+func GetContextLoggerWithRequestID(ctx context.Context, isDebug bool, requestIDIn *string) (*zap.Logger, string) {
+	if requestIDIn == nil {
+		if requestID := RequestIDFromContext(ctx); requestID != "" {
+			requestIDIn = &requestID
+		}
+	}
+	logger, requestID := defaultLoggerFactory.RequestLogger(isDebug, requestIDIn)
+	return logger, requestID + " "
 }
@@ -18,9 +18,17 @@
 package utils
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/net/context"
 )
 
@@ -44,3 +52,78 @@ func TestGetContextLoggerWithRequestID(t *testing.T) {
 	assert.NotNil(t, ctxLog)
 	assert.NotNil(t, reqID)
 }
+
+func newTestLoggerFactory(level zapcore.Level) (*LoggerFactory, *bytes.Buffer, *bytes.Buffer) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	return newLoggerFactory(level, zapcore.AddSync(stdout), zapcore.AddSync(stderr)), stdout, stderr
+}
+
+func TestLoggerFactoryRequestLogger(t *testing.T) {
+	factory, stdout, stderr := newTestLoggerFactory(zap.InfoLevel)
+	requestIDIn := "1000"
+	logger, requestID := factory.RequestLogger(false, &requestIDIn)
+	assert.Equal(t, "1000", requestID)
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Error("error message")
+	assert.NotContains(t, stdout.String(), "debug message")
+	assert.Contains(t, stdout.String(), "info message")
+	assert.Contains(t, stdout.String(), `"RequestID":"1000"`)
+	assert.NotContains(t, stdout.String(), "error message")
+	assert.Contains(t, stderr.String(), "error message")
+
+	// Debug loggers log debug messages at the info factory level
+	logger, requestID = factory.RequestLogger(true, nil)
+	assert.NotEmpty(t, requestID)
+	logger.Debug("forced debug message")
+	assert.Contains(t, stdout.String(), "forced debug message")
+	assert.Contains(t, stdout.String(), requestID)
+
+	// and follow the factory level once it is raised
+	factory.Level().SetLevel(zap.WarnLevel)
+	logger.Debug("silenced debug message")
+	logger.Info("silenced info message")
+	logger.Warn("warn message")
+	assert.NotContains(t, stdout.String(), "silenced")
+	assert.Contains(t, stdout.String(), "warn message")
+}
+
+func TestLoggerFactoryLevelChange(t *testing.T) {
+	factory, stdout, _ := newTestLoggerFactory(zap.InfoLevel)
+	logger, _ := factory.RequestLogger(false, nil)
+
+	factory.Level().SetLevel(zap.DebugLevel)
+	logger.Debug("first debug message")
+	assert.Contains(t, stdout.String(), "first debug message")
+
+	factory.Level().SetLevel(zap.WarnLevel)
+	logger.Info("info message")
+	assert.NotContains(t, stdout.String(), "info message")
+}
+
+func TestLoggerFactoryLevelHandler(t *testing.T) {
+	factory, _, _ := newTestLoggerFactory(zap.InfoLevel)
+	handler := factory.LevelHandler()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/log/level", strings.NewReader(`{"level":"debug"}`)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, zap.DebugLevel, factory.Level().Level())
+}
+
+func TestLoggerFactoryLevelSignal(t *testing.T) {
+	factory, _, _ := newTestLoggerFactory(zap.InfoLevel)
+	signals := make(chan os.Signal)
+	stop := factory.watchLevelSignals(signals)
+	defer stop()
+
+	signals <- os.Interrupt
+	assert.Eventually(t, func() bool { return factory.Level().Level() == zap.DebugLevel }, time.Second, time.Millisecond)
+	signals <- os.Interrupt
+	assert.Eventually(t, func() bool { return factory.Level().Level() == zap.InfoLevel }, time.Second, time.Millisecond)
+
+	stop = factory.HandleLevelSignal(os.Interrupt)
+	stop()
+}
@@ -0,0 +1,46 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package utils ...
+package utils
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// TopologyZoneLabel is the GA zone label of nodes and volumes
+const TopologyZoneLabel = "topology.kubernetes.io/zone"
+
+// IsPVProvisionedBy reports whether obj is a CSI persistent volume of the
+// driver named provisionerName
+func IsPVProvisionedBy(obj interface{}, provisionerName string) bool {
+	pv, _ := obj.(*v1.PersistentVolume)
+	return pv != nil && pv.Spec.CSI != nil && pv.Spec.CSI.Driver == provisionerName
+}
+
+// GetPVZone returns the zone of pv from its volume attributes or its zone
+// labels, or an empty string if it has none
+func GetPVZone(pv *v1.PersistentVolume) string {
+	if pv.Spec.CSI != nil {
+		if zone := pv.Spec.CSI.VolumeAttributes[ZoneLabel]; zone != "" {
+			return zone
+		}
+	}
+	if zone := pv.Labels[TopologyZoneLabel]; zone != "" {
+		return zone
+	}
+	return pv.Labels[NodeZoneLabel]
+}
@@ -0,0 +1,52 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package utils ...
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsPVProvisionedBy(t *testing.T) {
+	csiPV := &v1.PersistentVolume{Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+		CSI: &v1.CSIPersistentVolumeSource{Driver: "vpc.block.csi.ibm.io"},
+	}}}
+	assert.True(t, IsPVProvisionedBy(csiPV, "vpc.block.csi.ibm.io"))
+	assert.False(t, IsPVProvisionedBy(csiPV, "vpc.file.csi.ibm.io"))
+	assert.False(t, IsPVProvisionedBy(&v1.PersistentVolume{}, "vpc.block.csi.ibm.io"))
+	assert.False(t, IsPVProvisionedBy(&v1.Pod{}, "vpc.block.csi.ibm.io"))
+	assert.False(t, IsPVProvisionedBy(nil, "vpc.block.csi.ibm.io"))
+}
+
+func TestGetPVZone(t *testing.T) {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{NodeZoneLabel: "us-south-3"}},
+		Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{VolumeAttributes: map[string]string{ZoneLabel: "us-south-1"}},
+		}},
+	}
+	assert.Equal(t, "us-south-1", GetPVZone(pv))
+	pv.Spec.CSI.VolumeAttributes = nil
+	assert.Equal(t, "us-south-3", GetPVZone(pv))
+	pv.Labels[TopologyZoneLabel] = "us-south-2"
+	assert.Equal(t, "us-south-2", GetPVZone(pv))
+	assert.Equal(t, "", GetPVZone(&v1.PersistentVolume{}))
+}
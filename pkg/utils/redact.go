@@ -0,0 +1,210 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package utils ...
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RedactedValue replaces secret values in log output
+const RedactedValue = "[REDACTED]"
+
+// sensitiveKeySuffixes identify secret keys once lower cased and stripped of
+// separators, e.g. g2_api_key, iam_client_secret, refresh_token,
+// PassthroughSecret, encryptionKey or the secrets map of CSI requests
+var sensitiveKeySuffixes = []string{
+	"apikey",
+	"secret",
+	"secrets",
+	"token",
+	"password",
+	"encryptionkey",
+	"authorization",
+	"credentials",
+}
+
+// secretAssignment matches key/value pairs with a secret key inside free text,
+// such as TOML (g2_api_key = "..."), JSON ("g2_api_key":"...") or key=value
+var secretAssignment = regexp.MustCompile(`(?i)([a-z0-9_.-]*(?:api[_-]?key|secrets?|token|password|encryption[_-]?key)"?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,;}\]"']+)`)
+
+// bearerToken matches bearer credentials in free text
+var bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[a-z0-9._~+/=-]+`)
+
+// isSensitiveKey reports whether values logged under key must be redacted
+func isSensitiveKey(key string) bool {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case '_', '-', '.', ' ':
+			return -1
+		}
+		return r
+	}, strings.ToLower(key))
+	for _, suffix := range sensitiveKeySuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString masks secret values assigned to secret keys within s
+func RedactString(s string) string {
+	s = secretAssignment.ReplaceAllStringFunc(s, func(match string) string {
+		parts := secretAssignment.FindStringSubmatch(match)
+		value := parts[2]
+		if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, `'`) {
+			return parts[1] + value[:1] + RedactedValue + value[:1]
+		}
+		return parts[1] + RedactedValue
+	})
+	return bearerToken.ReplaceAllString(s, "${1}"+RedactedValue)
+}
+
+// redactValue masks secret keys of decoded JSON values recursively
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				v[key] = RedactedValue
+			} else {
+				v[key] = redactValue(item)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		return v
+	case string:
+		return RedactString(v)
+	}
+	return value
+}
+
+// redactReflected masks secrets of an arbitrary value by round tripping it
+// through JSON, the same encoding zap uses for reflected fields
+func redactReflected(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return RedactString(fmt.Sprintf("%+v", value))
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return RedactString(string(data))
+	}
+	return redactValue(decoded)
+}
+
+// RedactField returns field with secret values masked
+func RedactField(field zapcore.Field) zapcore.Field {
+	switch field.Type {
+	case zapcore.SkipType, zapcore.NamespaceType:
+		return field
+	}
+	if isSensitiveKey(field.Key) {
+		return zap.String(field.Key, RedactedValue)
+	}
+	switch field.Type {
+	case zapcore.StringType:
+		field.String = RedactString(field.String)
+	case zapcore.ByteStringType, zapcore.BinaryType:
+		if data, ok := field.Interface.([]byte); ok {
+			return zap.String(field.Key, RedactString(string(data)))
+		}
+	case zapcore.ErrorType:
+		if err, ok := field.Interface.(error); ok && err != nil {
+			return zap.String(field.Key, RedactString(err.Error()))
+		}
+	case zapcore.StringerType:
+		if stringer, ok := field.Interface.(fmt.Stringer); ok {
+			return zap.String(field.Key, RedactString(stringer.String()))
+		}
+	case zapcore.ReflectType:
+		return zap.Any(field.Key, redactReflected(field.Interface))
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.InlineMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+		if field.Type == zapcore.InlineMarshalerType {
+			inline, _ := redactReflected(enc.Fields).(map[string]interface{})
+			return zap.Inline(redactedObject(inline))
+		}
+		return zap.Any(field.Key, redactReflected(enc.Fields[field.Key]))
+	}
+	return field
+}
+
+// redactedObject marshals already redacted inline fields
+type redactedObject map[string]interface{}
+
+// MarshalLogObject ...
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for key, value := range o {
+		if err := enc.AddReflected(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactFields returns a copy of fields with secret values masked
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		redacted[i] = RedactField(field)
+	}
+	return redacted
+}
+
+// redactingCore masks secrets in messages and fields before they reach the
+// wrapped core
+type redactingCore struct {
+	zapcore.Core
+}
+
+// NewRedactingCore wraps core so that API keys, tokens, passwords,
+// encryption keys and CSI secrets maps never reach its output
+func NewRedactingCore(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+// With ...
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+// Check ...
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write ...
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = RedactString(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
@@ -0,0 +1,127 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package utils ...
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IBM/ibmcloud-volume-interface/config"
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestIsSensitiveKey(t *testing.T) {
+	for _, key := range []string{"g2_api_key", "iam_client_secret", "refresh_token", "PassthroughSecret", "encryptionKey", "containers_api_csrf_token", "secrets", "Authorization"} {
+		assert.True(t, isSensitiveKey(key), key)
+	}
+	for _, key := range []string{"g2_token_exchange_endpoint_url", "RequestID", "volumeId", "encryption", "provider_type"} {
+		assert.False(t, isSensitiveKey(key), key)
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	testCases := []struct {
+		testCaseName   string
+		input          string
+		expectedOutput string
+	}{
+		{
+			testCaseName:   "TOML assignment",
+			input:          `g2_api_key = "api-key"`,
+			expectedOutput: `g2_api_key = "[REDACTED]"`,
+		},
+		{
+			testCaseName:   "JSON member",
+			input:          `{"iam_client_secret":"s3cr3t","region":"us-south"}`,
+			expectedOutput: `{"iam_client_secret":"[REDACTED]","region":"us-south"}`,
+		},
+		{
+			testCaseName:   "Key value pair",
+			input:          "failed with refresh_token=abc123, retrying",
+			expectedOutput: "failed with refresh_token=[REDACTED], retrying",
+		},
+		{
+			testCaseName:   "Bearer token",
+			input:          "Authorization: Bearer eyJhbGciOi.abc",
+			expectedOutput: "Authorization: Bearer [REDACTED]",
+		},
+		{
+			testCaseName:   "No secret",
+			input:          `g2_token_exchange_endpoint_url = "https://iam.cloud.ibm.com"`,
+			expectedOutput: `g2_token_exchange_endpoint_url = "https://iam.cloud.ibm.com"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testCaseName, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOutput, RedactString(tc.input))
+		})
+	}
+}
+
+func TestRedactingLoggerHidesSlconfigSecrets(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "test-fixtures", "slconfig.toml"))
+	assert.Nil(t, err)
+	testLogger, teardown := GetTestLogger(t)
+	defer teardown()
+	conf, err := config.ParseConfig(testLogger, string(data))
+	assert.Nil(t, err)
+	apiKey := conf.VPC.G2APIKey
+	assert.NotEmpty(t, apiKey)
+
+	factory, stdout, stderr := newTestLoggerFactory(zap.DebugLevel)
+	logger, _ := factory.RequestLogger(false, nil)
+
+	logger.Info("Loaded config "+string(data), zap.String("raw", string(data)), zap.ByteString("bytes", data))
+	logger.Info("Config", zap.Reflect("config", conf), zap.Any("vpc", conf.VPC))
+	logger.Info("Credentials", zap.String("g2_api_key", apiKey), zap.Strings("keys", []string{"g2_api_key=" + apiKey}))
+	logger.With(zap.String("PassthroughSecret", apiKey)).Warn("Child logger")
+	logger.Error("Session failed", zap.Error(fmt.Errorf("invalid g2_api_key: %q", apiKey)))
+	logger.Info("PV", zap.Reflect("attributes", map[string]string{"encryptionKey": "crn:v1:key", "iops": "3000"}))
+	logger.Info("Request", zap.Reflect("request", &csi.CreateVolumeRequest{
+		Name:    "pvc-1",
+		Secrets: map[string]string{"apiKey": apiKey},
+	}))
+
+	output := stdout.String() + stderr.String()
+	assert.NotContains(t, output, apiKey)
+	assert.NotContains(t, output, "crn:v1:key")
+	assert.Contains(t, output, RedactedValue)
+	// Non secret data is still logged
+	assert.Contains(t, output, "us-south-stage01.iaasdev.cloud.ibm.com")
+	assert.Contains(t, output, `"iops":"3000"`)
+	assert.Contains(t, output, "pvc-1")
+	assert.Equal(t, 6, strings.Count(stdout.String(), "\n")) // the error goes to stderr
+}
+
+func TestRedactField(t *testing.T) {
+	field := RedactField(zap.Error(errors.New("token=abc")))
+	assert.Equal(t, "token=[REDACTED]", field.String)
+
+	field = RedactField(zap.Int("iops", 3000))
+	assert.Equal(t, int64(3000), field.Integer)
+
+	field = RedactField(zap.Namespace("volume"))
+	assert.Equal(t, "volume", field.Key)
+}
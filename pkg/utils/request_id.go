@@ -0,0 +1,130 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package utils ...
+package utils
+
+import (
+	"context"
+
+	uid "github.com/gofrs/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey is the gRPC metadata key carrying the request ID
+const RequestIDMetadataKey = "x-request-id"
+
+// requestIDKey is the context key under which the request ID is stored
+type requestIDKey struct{}
+
+// NewRequestID generates a new random request ID
+func NewRequestID() string {
+	uuid, _ := uid.NewV4() // #nosec G104: Attempt to randomly generate uuid
+	return uuid.String()
+}
+
+// ContextWithRequestID returns a copy of ctx carrying requestID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx or, failing that,
+// the one received in incoming gRPC metadata. It returns "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if requestID, _ := ctx.Value(requestIDKey{}).(string); requestID != "" {
+		return requestID
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// ensureRequestID returns ctx carrying a request ID, reusing an incoming one
+// when present, along with that request ID
+func ensureRequestID(ctx context.Context) (context.Context, string) {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	return ContextWithRequestID(ctx, requestID), requestID
+}
+
+// UnaryServerRequestIDInterceptor stores the request ID of incoming calls in
+// the handler context, generating one if the caller did not send it, and
+// returns it to the caller in the response header
+func UnaryServerRequestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, requestID := ensureRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, requestID))
+		return handler(ctx, req)
+	}
+}
+
+// requestIDServerStream overrides the context of a grpc.ServerStream
+type requestIDServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context ...
+func (s *requestIDServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerRequestIDInterceptor is the streaming counterpart of
+// UnaryServerRequestIDInterceptor
+func StreamServerRequestIDInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID := ensureRequestID(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(RequestIDMetadataKey, requestID))
+		return handler(srv, &requestIDServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// outgoingContext adds the request ID of ctx, if any, to outgoing metadata
+func outgoingContext(ctx context.Context) context.Context {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		return ctx
+	}
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(RequestIDMetadataKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, RequestIDMetadataKey, requestID)
+}
+
+// UnaryClientRequestIDInterceptor sends the request ID of the call context,
+// if any, to the server in gRPC metadata
+func UnaryClientRequestIDInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientRequestIDInterceptor is the streaming counterpart of
+// UnaryClientRequestIDInterceptor
+func StreamClientRequestIDInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingContext(ctx), desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,124 @@
+/**
+ * Copyright 2021 IBM Corp.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package utils ...
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDFromContext(t *testing.T) {
+	assert.Equal(t, "", RequestIDFromContext(context.Background()))
+
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "from-metadata"))
+	assert.Equal(t, "from-metadata", RequestIDFromContext(incoming))
+
+	// A request ID stored in the context wins over incoming metadata
+	assert.Equal(t, "from-context", RequestIDFromContext(ContextWithRequestID(incoming, "from-context")))
+}
+
+func TestGetContextLoggerUsesContextRequestID(t *testing.T) {
+	ctx := ContextWithRequestID(context.Background(), "1000")
+	ctxLog, reqID := GetContextLogger(ctx, false)
+	assert.NotNil(t, ctxLog)
+	assert.Equal(t, "1000 ", reqID)
+
+	// An explicit request ID is still honoured
+	requestIDIn := "2000"
+	_, reqID = GetContextLoggerWithRequestID(ctx, false, &requestIDIn)
+	assert.Equal(t, "2000 ", reqID)
+}
+
+func TestUnaryServerRequestIDInterceptor(t *testing.T) {
+	interceptor := UnaryServerRequestIDInterceptor()
+	var handlerRequestID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handlerRequestID = RequestIDFromContext(ctx)
+		return nil, nil
+	}
+
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "from-sidecar"))
+	_, err := interceptor(incoming, nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "from-sidecar", handlerRequestID)
+
+	// A request ID is generated when the caller sent none
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, handlerRequestID)
+	assert.NotEqual(t, "from-sidecar", handlerRequestID)
+}
+
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestStreamServerRequestIDInterceptor(t *testing.T) {
+	interceptor := StreamServerRequestIDInterceptor()
+	stream := &fakeServerStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDMetadataKey, "from-sidecar"))}
+	var handlerRequestID string
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+		handlerRequestID = RequestIDFromContext(ss.Context())
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "from-sidecar", handlerRequestID)
+	assert.Equal(t, []string{"from-sidecar"}, stream.header.Get(RequestIDMetadataKey))
+}
+
+func TestClientRequestIDInterceptors(t *testing.T) {
+	var sent []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get(RequestIDMetadataKey)
+		return nil
+	}
+
+	unary := UnaryClientRequestIDInterceptor()
+	assert.Nil(t, unary(ContextWithRequestID(context.Background(), "1000"), "/m", nil, nil, nil, invoker))
+	assert.Equal(t, []string{"1000"}, sent)
+
+	// Nothing is sent without a request ID
+	assert.Nil(t, unary(context.Background(), "/m", nil, nil, nil, invoker))
+	assert.Empty(t, sent)
+
+	stream := StreamClientRequestIDInterceptor()
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = md.Get(RequestIDMetadataKey)
+		return nil, nil
+	}
+	_, err := stream(ContextWithRequestID(context.Background(), "2000"), &grpc.StreamDesc{}, nil, "/m", streamer)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2000"}, sent)
+}
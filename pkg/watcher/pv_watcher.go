@@ -23,20 +23,19 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang/glog"
-
+	"github.com/IBM/ibm-csi-common/pkg/events"
 	cloudprovider "github.com/IBM/ibm-csi-common/pkg/ibmcloudprovider"
+	"github.com/IBM/ibm-csi-common/pkg/tracing"
 	"github.com/IBM/ibm-csi-common/pkg/utils"
 	"github.com/IBM/ibmcloud-volume-interface/config"
 	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
@@ -47,7 +46,6 @@ import (
 type PVWatcher struct {
 	logger          *zap.Logger
 	kclient         kubernetes.Interface
-	config          *config.Config
 	provisionerName string
 	recorder        record.EventRecorder
 	cloudProvider   cloudprovider.CloudProviderInterface
@@ -115,17 +113,12 @@ func New(logger *zap.Logger, provisionerName string, volumeType string, cloudPro
 	}
 	iksPodName := os.Getenv("POD_NAME")
 
-	broadcaster := record.NewBroadcaster()
-	broadcaster.StartLogging(glog.Infof)
-	eventInterface := clientset.CoreV1().Events("")
-	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: eventInterface})
 	pvw := &PVWatcher{
 		logger:          logger,
-		config:          cloudProvider.GetConfig(),
 		provisionerName: provisionerName,
 		kclient:         clientset,
 		cloudProvider:   cloudProvider,
-		recorder:        broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: iksPodName}),
+		recorder:        events.NewEventRecorder(clientset, iksPodName),
 	}
 	return pvw
 }
@@ -161,19 +154,30 @@ func (pvw *PVWatcher) updateVolume(oldobj, obj interface{}) {
 
 		ctxLogger.Info("Entry updateVolume()", zap.Reflect("obj", obj))
 		pv, _ := obj.(*v1.PersistentVolume)
-		session, err := pvw.cloudProvider.GetProviderSession(context.Background(), ctxLogger)
+		ctx := utils.ContextWithRequestID(context.Background(), strings.TrimSpace(requestID))
+		ctx = cloudprovider.ContextWithProviderSelector(ctx, providerSelector(pv))
+		ctx = cloudprovider.ContextWithAccount(ctx, cloudprovider.AccountFromParameters(pv.Spec.CSI.VolumeAttributes))
+		// The span covers opening the session too, which may have to fetch a token
+		ctx, span := tracing.StartSpan(ctx, "PVWatcher.UpdateVolume", attribute.String("pv", pv.Name))
+		session, err := pvw.cloudProvider.GetProviderSession(ctx, ctxLogger)
 		if session != nil {
 			volume := pvw.getVolume(pv, ctxLogger)
 			ctxLogger.Info("volume to update ", zap.Reflect("volume", volume))
-			err := session.UpdateVolume(volume)
+			span.SetAttributes(attribute.String("volumeID", volume.VolumeID))
+			err = session.UpdateVolume(volume)
 			if err != nil {
 				ctxLogger.Warn("Unable to update the volume", zap.Error(err))
+				// Do not reuse a cached session whose token was rejected
+				if invalidator, ok := pvw.cloudProvider.(cloudprovider.SessionInvalidator); ok {
+					invalidator.InvalidateSession(session, err)
+				}
 				pvw.recorder.Event(pv, v1.EventTypeWarning, VolumeUpdateEventReason, err.Error())
 			} else {
 				pvw.recorder.Event(pv, v1.EventTypeNormal, VolumeUpdateEventReason, VolumeUpdateEventSuccess)
 				ctxLogger.Warn("Volume Metadata saved successfully")
 			}
 		}
+		tracing.EndSpan(span, err)
 		ctxLogger.Info("Exit updateVolume()", zap.Error(err))
 	}()
 }
@@ -204,7 +208,7 @@ func (pvw *PVWatcher) getVolume(pv *v1.PersistentVolume, ctxLogger *zap.Logger)
 	crn, tags := pvw.getTags(pv, ctxLogger)
 	volume := provider.Volume{
 		VolumeID:   pv.Spec.CSI.VolumeHandle,
-		Provider:   provider.VolumeProvider(pvw.config.VPC.VPCBlockProviderType),
+		Provider:   provider.VolumeProvider(providerType(pvw.configFor(pv, ctxLogger))),
 		VolumeType: provider.VolumeType(VolumeTypeMap[pv.Spec.CSI.Driver]),
 	}
 	volume.CRN = crn
@@ -227,13 +231,44 @@ func (pvw *PVWatcher) getVolume(pv *v1.PersistentVolume, ctxLogger *zap.Logger)
 	return volume
 }
 
+// configFor returns the current config of the provider of the volume of pv,
+// which may have been reloaded since the watcher started
+func (pvw *PVWatcher) configFor(pv *v1.PersistentVolume, ctxLogger *zap.Logger) *config.Config {
+	registry, ok := pvw.cloudProvider.(cloudprovider.MultiCloudProviderInterface)
+	if !ok {
+		return pvw.cloudProvider.GetConfig()
+	}
+	name, cloudProvider, err := registry.SelectProvider(providerSelector(pv))
+	if err != nil {
+		ctxLogger.Warn("No cloud provider for the volume, using the default config", zap.Error(err))
+		return pvw.cloudProvider.GetConfig()
+	}
+	ctxLogger.Debug("Cloud provider of the volume", zap.String("provider", name))
+	return cloudProvider.GetConfig()
+}
+
+// providerSelector selects the provider of the volume of pv by driver,
+// provider volume attribute and volume ID
+func providerSelector(pv *v1.PersistentVolume) cloudprovider.ProviderSelector {
+	return cloudprovider.ProviderSelector{
+		Parameters: pv.Spec.CSI.VolumeAttributes,
+		Driver:     pv.Spec.CSI.Driver,
+		VolumeID:   pv.Spec.CSI.VolumeHandle,
+	}
+}
+
+// providerType returns the provider type of conf, empty for providers
+// without VPC config such as IKS classic
+func providerType(conf *config.Config) string {
+	if conf == nil || conf.VPC == nil {
+		return ""
+	}
+	return conf.VPC.VPCBlockProviderType
+}
+
 func (pvw *PVWatcher) filter(obj interface{}) bool {
 	pvw.logger.Debug("Entry filter()", zap.Reflect("obj", obj))
-	pv, _ := obj.(*v1.PersistentVolume)
-	var provisoinerMatch = false
-	if pv != nil && pv.Spec.CSI != nil {
-		provisoinerMatch = pv.Spec.CSI.Driver == pvw.provisionerName
-	}
+	provisoinerMatch := utils.IsPVProvisionedBy(obj, pvw.provisionerName)
 	pvw.logger.Debug("Exit filter()", zap.Bool("provisoinerMatch", provisoinerMatch))
 	return provisoinerMatch
 }
@@ -19,19 +19,29 @@ package watcher
 
 import (
 	"bytes"
+	"errors"
 	"net/http"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	cloudprovider "github.com/IBM/ibm-csi-common/pkg/ibmcloudprovider"
+	"github.com/IBM/ibm-csi-common/pkg/simulator"
 	"github.com/IBM/ibm-csi-common/pkg/utils"
 	"github.com/IBM/ibmcloud-volume-interface/config"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
 	"github.com/golang/glog"
 	"github.com/onsi/gomega/ghttp"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/net/context"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -78,7 +88,7 @@ func TestAddTags(t *testing.T) {
 		},
 	}
 	logger, _ := GetTestLogger(t)
-	fakeIBMCloudStorageProvider, _ := cloudprovider.NewFakeIBMCloudStorageProvider("configPath", logger)
+	fakeIBMCloudStorageProvider := cloudprovider.NewFakeProviderBuilder().WithConfig(conf).Build()
 
 	broadcaster := record.NewBroadcaster()
 	broadcaster.StartLogging(glog.Infof)
@@ -89,7 +99,6 @@ func TestAddTags(t *testing.T) {
 	pvw := &PVWatcher{
 		provisionerName: "ibm-csi-driver",
 		logger:          logger,
-		config:          conf,
 		cloudProvider:   fakeIBMCloudStorageProvider,
 		recorder:        broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "pod-name"}),
 	}
@@ -171,6 +180,233 @@ func TestAddTags(t *testing.T) {
 	}
 }
 
+func TestUpdateVolumeBackendOutage(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	outage := errors.New("backend unavailable")
+	fakeProvider := cloudprovider.NewFakeProviderBuilder().WithErrorOnCall(0, outage).Build()
+	fakeProvider.Session().UpdateVolumeReturnsOnCall(1, errors.New("update failed"))
+	recorder := record.NewFakeRecorder(10)
+
+	pvw := &PVWatcher{
+		provisionerName: "ibm-csi-driver",
+		logger:          logger,
+		cloudProvider:   fakeProvider,
+		recorder:        recorder,
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "test-namespace", Name: "test-pvc"},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: "vpc-csi-driver", VolumeHandle: "test-volumeid", VolumeAttributes: map[string]string{}},
+			},
+		},
+	}
+
+	// No session, so the volume is left alone
+	pvw.updateVolume(pv, pv)
+	assert.Eventually(t, func() bool {
+		return len(fakeProvider.Calls("GetProviderSession")) == 1
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, 0, fakeProvider.Session().UpdateVolumeCallCount())
+
+	pvw.updateVolume(pv, pv)
+	assert.Equal(t, "Normal "+VolumeUpdateEventReason+" "+VolumeUpdateEventSuccess, <-recorder.Events)
+	pvw.updateVolume(pv, pv)
+	assert.Equal(t, "Warning "+VolumeUpdateEventReason+" update failed", <-recorder.Events)
+	assert.Equal(t, 2, fakeProvider.Session().UpdateVolumeCallCount())
+	assert.Equal(t, "test-volumeid", fakeProvider.Session().UpdateVolumeArgsForCall(0).VolumeID)
+}
+
+func TestUpdateVolumeSpan(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	spans := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracerProvider)
+	defer otel.SetTracerProvider(previous)
+
+	fakeProvider := cloudprovider.NewFakeProviderBuilder().WithErrorOnCall(0, errors.New("token fetch failed")).Build()
+	recorder := record.NewFakeRecorder(10)
+	pvw := &PVWatcher{
+		provisionerName: "ibm-csi-driver",
+		logger:          logger,
+		cloudProvider:   fakeProvider,
+		recorder:        recorder,
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "test-namespace", Name: "test-pvc"},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: "vpc-csi-driver", VolumeHandle: "test-volumeid", VolumeAttributes: map[string]string{}},
+			},
+		},
+	}
+
+	// Failing to open the session is recorded on the span
+	pvw.updateVolume(pv, pv)
+	assert.Eventually(t, func() bool { return len(spans.Ended()) == 1 }, 5*time.Second, time.Millisecond)
+	span := spans.Ended()[0]
+	assert.Equal(t, "PVWatcher.UpdateVolume", span.Name())
+	assert.Equal(t, otelcodes.Error, span.Status().Code)
+	assert.Equal(t, "token fetch failed", span.Status().Description)
+
+	pvw.updateVolume(pv, pv)
+	<-recorder.Events
+	assert.Eventually(t, func() bool { return len(spans.Ended()) == 2 }, 5*time.Second, time.Millisecond)
+	span = spans.Ended()[1]
+	assert.Equal(t, otelcodes.Unset, span.Status().Code)
+	assert.Contains(t, span.Attributes(), attribute.String("volumeID", "test-volumeid"))
+}
+
+func TestUpdateVolumeLifecycle(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	backend := simulator.NewBackend("us-south-1")
+	cloudProvider := simulator.NewCloudProvider(backend)
+	session, _ := cloudProvider.GetProviderSession(context.Background(), logger)
+	name, capacity := "pvc-volume", 10
+	volume, err := session.CreateVolume(provider.Volume{Name: &name, Capacity: &capacity, Az: "us-south-1"})
+	assert.Nil(t, err)
+	recorder := record.NewFakeRecorder(10)
+
+	pvw := &PVWatcher{
+		provisionerName: "vpc.block.csi.ibm.io",
+		logger:          logger,
+		cloudProvider:   cloudProvider,
+		recorder:        recorder,
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+		Spec: v1.PersistentVolumeSpec{
+			StorageClassName:              "test-storage-class",
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimDelete,
+			ClaimRef:                      &v1.ObjectReference{Namespace: "test-namespace", Name: "test-pvc"},
+			Capacity: v1.ResourceList(map[v1.ResourceName]resource.Quantity{
+				v1.ResourceStorage: resource.MustParse("10Gi"),
+			}),
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:           "vpc.block.csi.ibm.io",
+					VolumeHandle:     volume.VolumeID,
+					VolumeAttributes: map[string]string{"tags": "team:storage", utils.ClusterIDLabel: "12345", VolumeCRN: volume.CRN},
+				},
+			},
+		},
+	}
+
+	pvw.updateVolume(pv, pv)
+	assert.Equal(t, "Normal "+VolumeUpdateEventReason+" "+VolumeUpdateEventSuccess, <-recorder.Events)
+	stored := backend.Volumes()[0]
+	assert.Contains(t, stored.Tags, "team:storage")
+	assert.Contains(t, stored.Tags, PVCNameTag+"test-pvc")
+	assert.Equal(t, VolumeStatusCreated, stored.Attributes[VolumeStatus])
+
+	released := pv.DeepCopy()
+	released.Status.Phase = v1.VolumeReleased
+	pvw.updateVolume(released, released)
+	assert.Equal(t, "Normal "+VolumeUpdateEventReason+" "+VolumeUpdateEventSuccess, <-recorder.Events)
+	assert.Equal(t, VolumeStatusDeleted, backend.Volumes()[0].Attributes[VolumeStatus])
+
+	// The volume is gone from the backend
+	assert.Nil(t, session.DeleteVolume(volume))
+	pvw.updateVolume(pv, pv)
+	assert.Contains(t, <-recorder.Events, "Warning "+VolumeUpdateEventReason)
+}
+
+func TestUpdateVolumeProviderRegistry(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	vpcBackend := simulator.NewBackend("us-south-1")
+	vpc := simulator.NewCloudProvider(vpcBackend)
+	satelliteBackend := simulator.NewBackend("us-south-1")
+	satellite := simulator.NewCloudProvider(satelliteBackend)
+	satellite.ProviderConfig = &config.Config{VPC: &config.VPCProviderConfig{VPCBlockProviderType: "satellite"}}
+	registry := cloudprovider.NewProviderRegistry()
+	assert.Nil(t, registry.Register("vpc", vpc))
+	assert.Nil(t, registry.Register("satellite", satellite))
+	assert.Nil(t, registry.MapDriver("satellite.block.csi.ibm.io", "satellite"))
+
+	session, _ := satellite.GetProviderSession(context.Background(), logger)
+	name, capacity := "pvc-volume", 10
+	volume, err := session.CreateVolume(provider.Volume{Name: &name, Capacity: &capacity, Az: "us-south-1"})
+	assert.Nil(t, err)
+	recorder := record.NewFakeRecorder(10)
+
+	pvw := &PVWatcher{
+		provisionerName: "satellite.block.csi.ibm.io",
+		logger:          logger,
+		cloudProvider:   registry,
+		recorder:        recorder,
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "test-namespace", Name: "test-pvc"},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: "satellite.block.csi.ibm.io", VolumeHandle: volume.VolumeID, VolumeAttributes: map[string]string{}},
+			},
+		},
+	}
+
+	assert.Equal(t, provider.VolumeProvider("satellite"), pvw.getVolume(pv, logger).Provider)
+	pvw.updateVolume(pv, pv)
+	assert.Equal(t, "Normal "+VolumeUpdateEventReason+" "+VolumeUpdateEventSuccess, <-recorder.Events)
+	assert.Equal(t, VolumeStatusCreated, satelliteBackend.Volumes()[0].Attributes[VolumeStatus])
+	assert.Empty(t, vpcBackend.Volumes())
+
+	// The provider attribute of the volume takes precedence over the driver
+	pv.Spec.CSI.VolumeAttributes[cloudprovider.ProviderParameter] = "vpc"
+	assert.Equal(t, provider.VolumeProvider(string(simulator.ProviderName)), pvw.getVolume(pv, logger).Provider)
+	pvw.updateVolume(pv, pv)
+	assert.Contains(t, <-recorder.Events, "Warning "+VolumeUpdateEventReason)
+}
+
+func TestUpdateVolumeOtherAccount(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	homeBackend := simulator.NewBackend("us-south-1")
+	accountBackends := map[string]*simulator.Backend{"account-a": simulator.NewBackend("us-south-1")}
+	newSession := func(ctx context.Context, logger *zap.Logger, conf *config.Config, credentials provider.ContextCredentials) (provider.Session, error) {
+		return simulator.NewSession(accountBackends[credentials.IAMAccountID]), nil
+	}
+	cloudProvider := cloudprovider.NewAccountCloudProvider(logger, simulator.NewCloudProvider(homeBackend), cloudprovider.Account{ID: "home-account"},
+		cloudprovider.StaticCredentialSource{"account-a": "key-a"}, newSession)
+	defer cloudProvider.Close()
+
+	name, capacity := "pvc-volume", 10
+	volume, err := simulator.NewSession(accountBackends["account-a"]).CreateVolume(provider.Volume{Name: &name, Capacity: &capacity, Az: "us-south-1"})
+	assert.Nil(t, err)
+	recorder := record.NewFakeRecorder(10)
+	pvw := &PVWatcher{
+		provisionerName: "vpc.block.csi.ibm.io",
+		logger:          logger,
+		cloudProvider:   cloudProvider,
+		recorder:        recorder,
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+		Spec: v1.PersistentVolumeSpec{
+			ClaimRef: &v1.ObjectReference{Namespace: "test-namespace", Name: "test-pvc"},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:           "vpc.block.csi.ibm.io",
+					VolumeHandle:     volume.VolumeID,
+					VolumeAttributes: map[string]string{cloudprovider.AccountIDParameter: "account-a"},
+				},
+			},
+		},
+	}
+
+	pvw.updateVolume(pv, pv)
+	assert.Equal(t, "Normal "+VolumeUpdateEventReason+" "+VolumeUpdateEventSuccess, <-recorder.Events)
+	assert.Equal(t, VolumeStatusCreated, accountBackends["account-a"].Volumes()[0].Attributes[VolumeStatus])
+	assert.Empty(t, homeBackend.Volumes())
+}
+
 // GetTestLogger ...
 func GetTestLogger(t *testing.T) (logger *zap.Logger, teardown func()) {
 	atom := zap.NewAtomicLevel()
@@ -199,3 +435,29 @@ func GetTestLogger(t *testing.T) (logger *zap.Logger, teardown func()) {
 	}
 	return
 }
+
+func TestConfigForFollowsReloadedConfig(t *testing.T) {
+	logger, teardown := GetTestLogger(t)
+	defer teardown()
+	fakeProvider := cloudprovider.NewFakeProviderBuilder().
+		WithConfig(&config.Config{VPC: &config.VPCProviderConfig{VPCBlockProviderType: "gc"}}).
+		Build()
+	pvw := &PVWatcher{
+		provisionerName: "ibm-csi-driver",
+		logger:          logger,
+		cloudProvider:   fakeProvider,
+		recorder:        record.NewFakeRecorder(10),
+	}
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pv"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{Driver: "ibm-csi-driver", VolumeHandle: "vol-1"},
+			},
+		},
+	}
+	assert.Equal(t, "gc", providerType(pvw.configFor(pv, logger)))
+
+	fakeProvider.UpdateConfig(&config.Config{VPC: &config.VPCProviderConfig{VPCBlockProviderType: "g2"}})
+	assert.Equal(t, "g2", providerType(pvw.configFor(pv, logger)))
+}